@@ -5,6 +5,7 @@ import (
 
 	cmd2 "github.com/sandstorm/synco/v2/pkg/receive/cmd"
 	"github.com/sandstorm/synco/v2/pkg/serve/cmd"
+	cmd3 "github.com/sandstorm/synco/v2/pkg/verify/cmd"
 	"github.com/spf13/cobra"
 	"os"
 )
@@ -55,6 +56,8 @@ func SetVersionInfo(version, commit, date, builtBy string) {
 func Execute() {
 	rootCmd.AddCommand(cmd.ServeCmd)
 	rootCmd.AddCommand(cmd2.ReceiveCmd)
+	rootCmd.AddCommand(cmd2.InspectCmd)
+	rootCmd.AddCommand(cmd3.VerifyDumpCmd)
 
 	// Execute cobra
 	if err := rootCmd.Execute(); err != nil {
@@ -0,0 +1,24 @@
+package commonServe
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// ComposerRequires returns true if composer.json in the working directory requires the given package.
+func ComposerRequires(packageName string) bool {
+	contents, err := os.ReadFile("composer.json")
+	if err != nil {
+		return false
+	}
+	var composerJson struct {
+		Require    map[string]string `json:"require"`
+		RequireDev map[string]string `json:"require-dev"`
+	}
+	if err := json.Unmarshal(contents, &composerJson); err != nil {
+		return false
+	}
+	_, required := composerJson.Require[packageName]
+	_, requiredDev := composerJson.RequireDev[packageName]
+	return required || requiredDev
+}
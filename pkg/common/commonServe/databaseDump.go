@@ -1,15 +1,42 @@
 package commonServe
 
 import (
+	"crypto/sha1"
 	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/pterm/pterm"
 	"github.com/sandstorm/synco/v2/pkg/common"
+	"github.com/sandstorm/synco/v2/pkg/common/config"
 	"github.com/sandstorm/synco/v2/pkg/common/dto"
 	"github.com/sandstorm/synco/v2/pkg/serve"
 	"github.com/sandstorm/synco/v2/pkg/util/mysql"
 )
 
-func DatabaseDump(transferSession *serve.TransferSession, dbCredentials *common.DbCredentials, whereClauseForTables map[string]string) *sql.DB {
+// DatabaseDump dumps the database into the file set with the given name, and exits if this does not work.
+//
+// The returned read only transaction is still open, so that further queries (f.e. for building a resource index)
+// see exactly the same state as the dump. The caller must roll it back when done.
+//
+// tableModes and tableLimits are the defaults of the framework (f.e. "schema-only" for an event log, or only the newest
+// rows of a log table); the dump configuration of the transfer session takes precedence.
+func DatabaseDump(transferSession *serve.TransferSession, name string, dbCredentials *common.DbCredentials, whereClauseForTables map[string]string, tableModes map[string]string, tableLimits map[string]config.SyncoServeDumpTableLimit) *sql.Tx {
+	tx, err := TryDatabaseDump(transferSession, name, dbCredentials, whereClauseForTables, tableModes, tableLimits)
+	if err != nil {
+		pterm.Fatal.Printfln("could not create SQL dump: %s", err)
+	}
+	return tx
+}
+
+// TryDatabaseDump dumps the database into the file set with the given name. In case of errors, the
+// partially written dump is removed and no file set is added.
+func TryDatabaseDump(transferSession *serve.TransferSession, name string, dbCredentials *common.DbCredentials, whereClauseForTables map[string]string, tableModes map[string]string, tableLimits map[string]config.SyncoServeDumpTableLimit) (*sql.Tx, error) {
+	defer transferSession.RecordPhase("database dump "+name, time.Now())
 	// 2) DATABASE DUMP
 	// basically the way it works is:
 	// mysql.CreateDump --> age.Encrypt --> write to file.
@@ -17,27 +44,207 @@ func DatabaseDump(transferSession *serve.TransferSession, dbCredentials *common.
 	// 1st: open the target file
 	// 2nd: init age.Encrypt
 	// 3rd: do mysql dump (which feeds the Writer)
-	wc, err := transferSession.EncryptToFile("dump.sql.enc")
+	fileName := name + ".sql.enc"
+	wc, err := transferSession.CompressAndEncryptToFile(fileName)
+	if err != nil {
+		return nil, err
+	}
 	fileSet := &dto.FileSet{
-		Name: "dbDump",
-		Type: dto.TYPE_MYSQLDUMP,
+		Name:        name,
+		Type:        dto.TYPE_MYSQLDUMP.WithCompression(transferSession.Compression),
+		Compression: transferSession.Compression,
 		MysqlDump: &dto.FileSetMysqlDump{
-			FileName: "dump.sql.enc",
+			FileName: fileName,
 		},
 	}
 
 	// 2b) the actual DB dump. also finishes writing.
-	db, err := mysql.CreateDump(dbCredentials, wc, whereClauseForTables)
+	tableChunks := newTableChunks(transferSession, name)
+	var openTableOut func(tableName string) (io.WriteCloser, error)
+	if transferSession.DumpConfig.Parallelism > 1 {
+		pterm.Info.Printfln("Dumping tables with %d connections in parallel", transferSession.DumpConfig.Parallelism)
+		openTableOut = tableChunks.open
+	}
+	if len(tableLimits)+len(transferSession.DumpConfig.Limits) > 0 {
+		pterm.Info.Printfln("Row limits / sampling are configured for some tables - the limited tables are listed at the top of the SQL dump.")
+	}
+	for _, root := range transferSession.DumpConfig.Subset {
+		pterm.Info.Printfln("Dumping only a subset of the database, starting at table %s (where: %q, order by: %q, limit: %d)", root.Table, root.Where, root.OrderBy, root.Limit)
+	}
+	result, err := mysql.CreateDump(dbCredentials, wc, whereClauseForTables, tableModes, mysqlTableLimits(tableLimits), MysqlDumpOptions(transferSession.DumpConfig), openTableOut)
 	if err != nil {
-		pterm.Fatal.Printfln("could not create SQL dump: %s", err)
+		_ = wc.Close()
+		_ = transferSession.RemoveFile(fileName)
+		tableChunks.remove()
+		return nil, err
 	}
 	fileSet.MysqlDump.SizeBytes = wc.Size()
+	fileSet.MysqlDump.Chunks = tableChunks.list()
+	if len(fileSet.MysqlDump.Chunks) > 0 {
+		fileSet.Type = dto.TYPE_MYSQLDUMP_CHUNKED.WithCompression(transferSession.Compression)
+	}
+	for _, chunk := range fileSet.MysqlDump.Chunks {
+		fileSet.MysqlDump.SizeBytes += chunk.SizeBytes
+	}
+	fileSet.MysqlDump.Checksums = result.TableChecksums
+	fileSet.MysqlDump.UnchangedTables = result.UnchangedTables
+	fileSet.MysqlDump.ServerVersion = result.ServerVersion
+	for _, warning := range result.Warnings {
+		transferSession.AddWarning(warning)
+	}
+	for _, stats := range result.TableStats {
+		fileSet.MysqlDump.Tables = append(fileSet.MysqlDump.Tables, dto.FileSetMysqlDumpTable{
+			Name:      stats.Name,
+			Mode:      string(stats.Mode),
+			Rows:      stats.Rows,
+			SizeBytes: uint64(stats.Bytes),
+			Filter:    stats.Filter,
+			RowHash:   stats.RowHash,
+		})
+	}
+	if len(result.UnchangedTables) > 0 {
+		pterm.Info.Printfln("%d of %d tables are unchanged since the known dump, and were skipped.", len(result.UnchangedTables), len(result.TableChecksums))
+	}
 	transferSession.Meta.FileSets = append(transferSession.Meta.FileSets, fileSet)
 	err = transferSession.UpdateMetadata()
 	if err != nil {
 		pterm.Fatal.Printfln("could not update SQL dump metadata: %s", err)
 	}
 
-	pterm.Info.Printfln("Stored Database Dump in %s", "dump.sql.enc")
-	return db
+	pterm.Info.Printfln("Stored Database Dump in %s", fileName)
+	return result.Tx, nil
+}
+
+// MysqlDumpOptions maps the dump configuration of .synco-serve.yml (and the flags) to the options of the MySQL dump
+func MysqlDumpOptions(dumpConfig config.SyncoServeDumpConfig) mysql.DumpOptions {
+	subset := make([]mysql.SubsetRoot, 0, len(dumpConfig.Subset))
+	for _, root := range dumpConfig.Subset {
+		subset = append(subset, mysql.SubsetRoot{
+			Table:   root.Table,
+			Where:   root.Where,
+			OrderBy: root.OrderBy,
+			Limit:   root.Limit,
+		})
+	}
+	return mysql.DumpOptions{
+		Views:                        dumpConfig.Views,
+		Triggers:                     dumpConfig.Triggers,
+		Routines:                     dumpConfig.Routines,
+		Events:                       dumpConfig.Events,
+		Parallelism:                  dumpConfig.Parallelism,
+		RequireSynchronizedSnapshots: dumpConfig.RequireSynchronizedSnapshots,
+		Tables:                       dumpConfig.Tables,
+		Limits:                       mysqlTableLimits(dumpConfig.Limits),
+		Checksums:                    dumpConfig.Checksums,
+		KnownChecksums:               dumpConfig.KnownChecksums,
+		Subset:                       subset,
+		MaxAllowedPacket:             dumpConfig.MaxAllowedPacket,
+		ChunkBlobs:                   dumpConfig.ChunkBlobs,
+		TLS: mysql.TlsOptions{
+			Mode:       dumpConfig.TLS.Mode,
+			Ca:         dumpConfig.TLS.Ca,
+			ServerName: dumpConfig.TLS.ServerName,
+			Cert:       dumpConfig.TLS.Cert,
+			Key:        dumpConfig.TLS.Key,
+		},
+		SSH: mysql.SshOptions{
+			Host:       dumpConfig.SSH.Host,
+			Key:        dumpConfig.SSH.Key,
+			KnownHosts: dumpConfig.SSH.KnownHosts,
+		},
+	}
+}
+
+func mysqlTableLimits(limits map[string]config.SyncoServeDumpTableLimit) map[string]mysql.TableLimit {
+	result := make(map[string]mysql.TableLimit, len(limits))
+	for table, limit := range limits {
+		result[table] = mysql.TableLimit{
+			OrderBy: limit.OrderBy,
+			Limit:   limit.Limit,
+			Sample:  limit.Sample,
+		}
+	}
+	return result
+}
+
+// tableChunks collects the per-table files of a parallel dump; open is called concurrently by the dump workers.
+type tableChunks struct {
+	transferSession *serve.TransferSession
+	name            string
+	mutex           sync.Mutex
+	chunksByTable   map[string]dto.FileSetMysqlDumpChunk
+}
+
+func newTableChunks(transferSession *serve.TransferSession, name string) *tableChunks {
+	return &tableChunks{
+		transferSession: transferSession,
+		name:            name,
+		chunksByTable:   make(map[string]dto.FileSetMysqlDumpChunk),
+	}
+}
+
+var unsafeFileNameCharacters = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// fileName is deterministic, so that a retried dump (f.e. without TLS) overwrites the files of the first try.
+func (c *tableChunks) fileName(tableName string) string {
+	safeTableName := unsafeFileNameCharacters.ReplaceAllString(tableName, "_")
+	if safeTableName != tableName {
+		// prevent collisions of f.e. "a-b" and "a_b"
+		safeTableName += fmt.Sprintf("-%x", sha1.Sum([]byte(tableName)))[:9]
+	}
+	return c.name + "-" + safeTableName + ".sql.enc"
+}
+
+func (c *tableChunks) open(tableName string) (io.WriteCloser, error) {
+	fileName := c.fileName(tableName)
+	wc, err := c.transferSession.CompressAndEncryptToFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	// registered right away, so that remove() also finds unfinished files
+	c.chunksByTable[tableName] = dto.FileSetMysqlDumpChunk{FileName: fileName, Table: tableName}
+	c.mutex.Unlock()
+	return &tableChunkWriter{WriteCloserWithSize: wc, onClose: func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.chunksByTable[tableName] = dto.FileSetMysqlDumpChunk{
+			FileName:  fileName,
+			Table:     tableName,
+			SizeBytes: wc.Size(),
+		}
+	}}, nil
+}
+
+// list returns the chunks sorted by table name
+func (c *tableChunks) list() []dto.FileSetMysqlDumpChunk {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	chunks := make([]dto.FileSetMysqlDumpChunk, 0, len(c.chunksByTable))
+	for _, chunk := range c.chunksByTable {
+		chunks = append(chunks, chunk)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].Table < chunks[j].Table
+	})
+	return chunks
+}
+
+func (c *tableChunks) remove() {
+	for _, chunk := range c.list() {
+		_ = c.transferSession.RemoveFile(chunk.FileName)
+	}
+}
+
+type tableChunkWriter struct {
+	serve.WriteCloserWithSize
+	onClose func()
+}
+
+func (w *tableChunkWriter) Close() error {
+	if err := w.WriteCloserWithSize.Close(); err != nil {
+		return err
+	}
+	w.onClose()
+	return nil
 }
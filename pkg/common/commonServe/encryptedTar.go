@@ -0,0 +1,89 @@
+package commonServe
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/serve"
+)
+
+// EncryptedTarWriter builds a PrivateEncryptedFiles file set file by file - for sources which are no plain
+// folder on disk (f.e. selected files from a storage, or objects from a private S3 bucket).
+type EncryptedTarWriter struct {
+	transferSession  *serve.TransferSession
+	name             string
+	relativeBasePath string
+	wc               serve.WriteCloserWithSize
+	tw               *tar.Writer
+	fileCount        int
+}
+
+// NewEncryptedTarWriter starts the file set with the given name; on the receiving side, the files are
+// extracted to dump/<relativeBasePath>.
+func NewEncryptedTarWriter(transferSession *serve.TransferSession, name string, relativeBasePath string) (*EncryptedTarWriter, error) {
+	wc, err := transferSession.CompressAndEncryptToFile("encrypted-resources-" + name)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedTarWriter{
+		transferSession:  transferSession,
+		name:             name,
+		relativeBasePath: relativeBasePath,
+		wc:               wc,
+		tw:               tar.NewWriter(wc),
+	}, nil
+}
+
+// AddFile adds a file with the given (slash separated) name to the archive; exactly size bytes are read from r.
+func (e *EncryptedTarWriter) AddFile(fileName string, size int64, modTime time.Time, r io.Reader) error {
+	err := e.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     fileName,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(e.tw, r)
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("%s: expected %d bytes, but got %d", fileName, size, written)
+	}
+	e.fileCount++
+	return nil
+}
+
+// Finish closes the archive and registers the file set in the metadata.
+func (e *EncryptedTarWriter) Finish() error {
+	if err := e.tw.Close(); err != nil {
+		return err
+	}
+	if err := e.wc.Close(); err != nil {
+		return err
+	}
+
+	e.transferSession.Meta.FileSets = append(e.transferSession.Meta.FileSets, &dto.FileSet{
+		Name:        e.name,
+		Type:        dto.TYPE_PRIVATE_ENCRYPTED_FILES.WithCompression(e.transferSession.Compression),
+		Compression: e.transferSession.Compression,
+		PrivateEncryptedFiles: &dto.FileSetPrivateEncryptedFiles{
+			TarUri:           "encrypted-resources-" + e.name,
+			SizeBytes:        e.wc.Size(),
+			RelativeBasePath: e.relativeBasePath,
+			FileCount:        e.fileCount,
+		},
+	})
+	err := e.transferSession.UpdateMetadata()
+	if err != nil {
+		pterm.Fatal.Printfln("could not update Resource dump metadata: %s", err)
+	}
+	return nil
+}
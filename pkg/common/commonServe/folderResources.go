@@ -0,0 +1,196 @@
+package commonServe
+
+import (
+	"archive/tar"
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/serve"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExtractAllResourcesFromFolder builds a public files index of all files below persistentResourcesBasePath, which
+// are reachable below baseUri on the web server.
+func ExtractAllResourcesFromFolder(transferSession *serve.TransferSession, name, persistentResourcesBasePath string, baseUri string) {
+	defer transferSession.RecordPhase("resources "+name, time.Now())
+	resourceFilesIndex := make(dto.PublicFilesIndex)
+	totalSizeBytes := uint64(0)
+	err := filepath.Walk(persistentResourcesBasePath,
+		func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				// skip directories on traversal
+				return nil
+			}
+
+			realPath, err := filepath.EvalSymlinks(filePath)
+			if err != nil {
+				transferSession.Warn("Could NOT evaluate symlinks (skipping): %s: %s", filePath, err)
+				return nil
+			}
+			realFileInfo, err := os.Lstat(realPath)
+			if err != nil {
+				transferSession.Warn("Could NOT read file info (skipping): %s: %s", realPath, err)
+				return nil
+			}
+
+			filePath = strings.TrimPrefix(filePath, persistentResourcesBasePath)
+
+			publicUri, err := url.JoinPath(baseUri, filePath)
+			if err != nil {
+				return err
+			}
+
+			totalSizeBytes += uint64(realFileInfo.Size())
+			resourceFilesIndex[persistentResourcesBasePath+filePath] = dto.PublicFilesIndexEntry{
+				SizeBytes: int64(realFileInfo.Size()),
+				MTime:     realFileInfo.ModTime().Unix(),
+				PublicUri: publicUri,
+			}
+			return nil
+		})
+	if err != nil {
+		log.Println(err)
+	}
+
+	WriteResourcesIndex(transferSession, dto.TYPE_PUBLICFILES, name, resourceFilesIndex, totalSizeBytes)
+}
+
+// EncryptAndExtractAllResourcesFromFolder writes all files below persistentResourcesBasePath (except skipDirs) into an
+// encrypted tar file.
+//
+// For encrypting, encrypting every single file individually with AGE is rather slow (no clue yet why).
+// That's why we TAR the folder first and then encrypt the result.
+func EncryptAndExtractAllResourcesFromFolder(transferSession *serve.TransferSession, name string, persistentResourcesBasePath string, skipDirs map[string]bool) {
+	defer transferSession.RecordPhase("resources "+name, time.Now())
+	persistentResourcesBasePath = strings.TrimSuffix(persistentResourcesBasePath, "/")
+
+	wc, err := transferSession.CompressAndEncryptToFile("encrypted-resources-" + name)
+	tw := tar.NewWriter(wc)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		pterm.Error.Printfln("Could NOT find working directory: %v", err)
+		return
+	}
+
+	relativeBasePath := ""
+	if !filepath.IsAbs(persistentResourcesBasePath) {
+		relativeBasePath = persistentResourcesBasePath
+	} else if strings.HasPrefix(persistentResourcesBasePath, wd) {
+		relativeBasePath = persistentResourcesBasePath[len(wd):]
+	}
+	relativeBasePath = strings.TrimPrefix(relativeBasePath, "/")
+
+	pterm.Debug.Printfln("  Relative base path: %s", persistentResourcesBasePath)
+
+	lastModificationTime := int64(0)
+	fileCount := 0
+	err = filepath.Walk(persistentResourcesBasePath,
+		func(filePath string, info os.FileInfo, err error) error {
+			// Skip root dir
+			if len(filePath) <= len(persistentResourcesBasePath) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			// Check if the current directory should be skipped
+			if info.IsDir() {
+				if skipDirs[filePath] {
+					pterm.Debug.Printfln("  Skipping directory (because included in other export): %s", filePath)
+					return filepath.SkipDir
+				}
+			}
+
+			// Skip directories but preserve the folder structure
+			header, err := tar.FileInfoHeader(info, info.Name())
+			if err != nil {
+				return err
+			}
+
+			// Ensure the correct file path in the tar header
+			header.Name = filepath.ToSlash(filePath[len(persistentResourcesBasePath)+1:])
+			pterm.Debug.Printfln("  File Name: %s", header.Name)
+
+			// Write the header
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+
+			// If it's a directory, no need to proceed further
+			if info.IsDir() {
+				return nil
+			}
+
+			realPath, err := filepath.EvalSymlinks(filePath)
+			if err != nil {
+				transferSession.Warn("Could NOT evaluate symlinks (skipping): %s: %s", filePath, err)
+				return nil
+			}
+			realFileInfo, err := os.Lstat(realPath)
+			if err != nil {
+				transferSession.Warn("Could NOT read file info (skipping): %s: %s", realPath, err)
+				return nil
+			}
+			if lastModificationTime < realFileInfo.ModTime().Unix() {
+				lastModificationTime = realFileInfo.ModTime().Unix()
+			}
+
+			// Open the file to copy its content
+			f, err := os.Open(filePath)
+			if err != nil {
+				return err
+			}
+			defer func(f *os.File) {
+				_ = f.Close()
+			}(f)
+
+			// Copy the file content to the tar writer
+			if _, err := io.Copy(tw, f); err != nil {
+				return err
+			}
+			fileCount++
+
+			return nil
+		})
+	if err != nil {
+		log.Println(err)
+	}
+
+	err = tw.Close()
+	if err != nil {
+		log.Println(err)
+	}
+
+	err = wc.Close()
+	if err != nil {
+		log.Println(err)
+	}
+
+	fileSet := &dto.FileSet{
+		Name:        name,
+		Type:        dto.TYPE_PRIVATE_ENCRYPTED_FILES.WithCompression(transferSession.Compression),
+		Compression: transferSession.Compression,
+		PrivateEncryptedFiles: &dto.FileSetPrivateEncryptedFiles{
+			TarUri:           "encrypted-resources-" + name,
+			SizeBytes:        wc.Size(),
+			RelativeBasePath: relativeBasePath,
+			FileCount:        fileCount,
+		},
+	}
+	transferSession.Meta.FileSets = append(transferSession.Meta.FileSets, fileSet)
+	err = transferSession.UpdateMetadata()
+	if err != nil {
+		pterm.Fatal.Printfln("could not update Resource dump metadata: %s", err)
+	}
+}
@@ -0,0 +1,75 @@
+package commonServe
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/serve"
+	"github.com/sandstorm/synco/v2/pkg/util/sqlite"
+)
+
+// SqliteDump stores a consistent copy of the SQLite database at dbPath into the file set with the given name.
+//
+// The copy is created via VACUUM INTO in a temporary directory (outside the web root, as it is unencrypted),
+// and encrypted into the transfer session directly afterwards.
+func SqliteDump(transferSession *serve.TransferSession, name string, dbPath string) error {
+	defer transferSession.RecordPhase("database dump "+name, time.Now())
+	tmpDir, err := os.MkdirTemp("", "synco-sqlite-")
+	if err != nil {
+		return fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	backupPath := filepath.Join(tmpDir, "backup.sqlite")
+	if err := sqlite.Backup(dbPath, backupPath); err != nil {
+		return err
+	}
+
+	fileName := name + ".sqlite.enc"
+	sizeBytes, err := transferSession.EncryptFileToFile(backupPath, fileName)
+	if err != nil {
+		_ = transferSession.RemoveFile(fileName)
+		return err
+	}
+
+	transferSession.Meta.FileSets = append(transferSession.Meta.FileSets, &dto.FileSet{
+		Name:        name,
+		Type:        dto.TYPE_SQLITE.WithCompression(transferSession.Compression),
+		Compression: transferSession.Compression,
+		Sqlite: &dto.FileSetSqlite{
+			FileName:     fileName,
+			SizeBytes:    sizeBytes,
+			RelativePath: sqliteRelativePath(dbPath),
+		},
+	})
+	err = transferSession.UpdateMetadata()
+	if err != nil {
+		pterm.Fatal.Printfln("could not update SQLite dump metadata: %s", err)
+	}
+
+	pterm.Info.Printfln("Stored SQLite Database %s in %s", dbPath, fileName)
+	return nil
+}
+
+// sqliteRelativePath returns the path where the database should be restored on the receiving side, relative
+// to the application root. Databases outside the application root end up directly in dump/.
+func sqliteRelativePath(dbPath string) string {
+	if !filepath.IsAbs(dbPath) {
+		return filepath.ToSlash(filepath.Clean(dbPath))
+	}
+	cwd, err := os.Getwd()
+	if err == nil {
+		relativePath, err := filepath.Rel(cwd, dbPath)
+		if err == nil && !strings.HasPrefix(relativePath, "..") {
+			return filepath.ToSlash(relativePath)
+		}
+	}
+	return filepath.Base(dbPath)
+}
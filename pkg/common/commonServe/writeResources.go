@@ -26,6 +26,7 @@ func WriteResourcesIndex(transferSession *serve.TransferSession, fileSetType dto
 		PublicFiles: &dto.FileSetPublicFiles{
 			IndexFileName: indexFileName,
 			SizeBytes:     totalSizeBytes,
+			FileCount:     len(resourceFilesIndex),
 		},
 	}
 	transferSession.Meta.FileSets = append(transferSession.Meta.FileSets, fileSet)
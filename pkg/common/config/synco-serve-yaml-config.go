@@ -0,0 +1,239 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+const SyncoServeYamlFile = ".synco-serve.yml"
+
+// SyncoServeConfig is the main structure which is serialized from .synco-serve.yml files. It contains
+// server-side config (for `synco serve`), and is placed in the work directory of the application.
+//
+// If the Database section is filled, the config file is used as a framework on its own; so that
+// any application can be dumped without framework specific code.
+type SyncoServeConfig struct {
+	Database *SyncoServeDatabaseConfig `yaml:"database"`
+	// WebDirectory is the folder which is publicly reachable via HTTP; the dump is placed inside it.
+	WebDirectory       string                       `yaml:"webDirectory"`
+	PublicDirectories  []SyncoServePublicDirectory  `yaml:"publicDirectories"`
+	PrivateDirectories []SyncoServePrivateDirectory `yaml:"privateDirectories"`
+
+	// Flow contains additional settings for Neos/Flow applications.
+	Flow *SyncoServeFlowConfig `yaml:"flow"`
+
+	// Dump controls what is contained in database dumps (for all frameworks).
+	Dump SyncoServeDumpConfig `yaml:"dump"`
+
+	// Hooks are shell commands, which are run when the transfer session enters the given state.
+	Hooks SyncoServeHooks `yaml:"hooks"`
+
+	// Compression is the codec (gzip or zstd) for compressing database dumps and private files before encrypting;
+	// can be overridden with --compression.
+	Compression string `yaml:"compression"`
+}
+
+type SyncoServeHooks struct {
+	// Created runs before anything is dumped (f.e. enabling a maintenance / read-only mode)
+	Created []string `yaml:"created"`
+	// Initializing runs after the work directory was created, before the framework starts dumping
+	Initializing []string `yaml:"initializing"`
+	// Ready runs after everything was dumped, before the receiving side can start downloading
+	Ready []string `yaml:"ready"`
+}
+
+// ByState returns the hooks in the structure expected by TransferSession.WithHooks
+func (h SyncoServeHooks) ByState() map[dto.State][]string {
+	return map[dto.State][]string{
+		dto.STATE_CREATED:      h.Created,
+		dto.STATE_INITIALIZING: h.Initializing,
+		dto.STATE_READY:        h.Ready,
+	}
+}
+
+// SyncoServeDumpConfig controls how database dumps are created; all settings can also be set via the corresponding
+// flags of `synco serve` (--dump-views, ..., --parallel).
+type SyncoServeDumpConfig struct {
+	// which schema objects are dumped in addition to the tables
+	Views    bool `yaml:"views"`
+	Triggers bool `yaml:"triggers"`
+	Routines bool `yaml:"routines"`
+	Events   bool `yaml:"events"`
+	// Parallelism > 1 dumps the tables concurrently with this many connections, into one file per table.
+	Parallelism int `yaml:"parallelism"`
+	// RequireSynchronizedSnapshots fails a parallel dump if the connections can not share the same snapshot
+	// (FLUSH TABLES WITH READ LOCK needs the RELOAD privilege), instead of only warning.
+	RequireSynchronizedSnapshots bool `yaml:"requireSynchronizedSnapshots"`
+	// Tables maps table names (or patterns like cache_*) to what is dumped of them: full, schema-only, data-only
+	// or skip. Overrides the defaults of the framework.
+	Tables map[string]string `yaml:"tables"`
+	// Limits only dump some rows of the given tables (or patterns), f.e. the newest 10000 rows of a log table.
+	// Overrides the defaults of the framework.
+	Limits map[string]SyncoServeDumpTableLimit `yaml:"limits"`
+	// Checksums computes a checksum of every table, for incremental refreshes (see KnownChecksums)
+	Checksums bool `yaml:"checksums"`
+	// KnownChecksums are the table checksums of the last dump the receiving side imported (--known-checksums);
+	// tables with the same checksum are not dumped.
+	KnownChecksums map[string]string `yaml:"-"`
+	// Subset only dumps the rows selected by these roots, plus the rows connected to them via foreign keys.
+	Subset []SyncoServeDumpSubsetRoot `yaml:"subset"`
+	// MaxAllowedPacket is the max_allowed_packet of the target database (f.e. 16M); empty for the value of the
+	// source database.
+	MaxAllowedPacket string `yaml:"maxAllowedPacket"`
+	// ChunkBlobs splits rows bigger than MaxAllowedPacket, by appending their BLOBs in chunks.
+	ChunkBlobs bool `yaml:"chunkBlobs"`
+	// TLS of the database connection
+	TLS SyncoServeDumpTlsConfig `yaml:"tls"`
+	// SSH connects to the database through an SSH jump host
+	SSH SyncoServeDumpSshConfig `yaml:"ssh"`
+}
+
+// SyncoServeDumpSshConfig tunnels the database connection through an SSH jump host, f.e.
+// {host: deploy@bastion.example.com:22}. The database host (or socket) is then resolved on the jump host.
+type SyncoServeDumpSshConfig struct {
+	// Host is [user@]host[:port]
+	Host string `yaml:"host"`
+	// Key is a private key file; if empty, the SSH agent (SSH_AUTH_SOCK) is used
+	Key string `yaml:"key"`
+	// KnownHosts is the known_hosts file to verify the jump host against (default: ~/.ssh/known_hosts)
+	KnownHosts string `yaml:"knownHosts"`
+}
+
+// SyncoServeDumpTlsConfig configures TLS for the database connection, f.e. {mode: verify-full, ca: /etc/mysql/ca.pem}
+type SyncoServeDumpTlsConfig struct {
+	// Mode is disabled, preferred (default), required, verify-ca or verify-full
+	Mode string `yaml:"mode"`
+	// Ca is the CA certificate file (PEM) to verify the server certificate against; the system CAs if empty
+	Ca string `yaml:"ca"`
+	// ServerName is the host name to verify the server certificate against (verify-full); the database host if empty
+	ServerName string `yaml:"serverName"`
+	// Cert and Key are the client certificate files (PEM), if the server requires them
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+// SyncoServeDumpTableLimit restricts the rows of a table, f.e. {orderBy: "id DESC", limit: 10000}
+type SyncoServeDumpTableLimit struct {
+	OrderBy string `yaml:"orderBy"`
+	Limit   int    `yaml:"limit"`
+	// Sample selects a random sample of roughly this percentage of the rows (0-100)
+	Sample float64 `yaml:"sample"`
+}
+
+// SyncoServeDumpSubsetRoot selects the rows a database subset starts with, f.e. the last 1000 orders:
+// {table: orders, orderBy: "id DESC", limit: 1000}
+type SyncoServeDumpSubsetRoot struct {
+	Table string `yaml:"table"`
+	// Where is a SQL condition, f.e. "tenant_id = 42"
+	Where   string `yaml:"where"`
+	OrderBy string `yaml:"orderBy"`
+	Limit   int    `yaml:"limit"`
+}
+
+type SyncoServeFlowConfig struct {
+	// TargetUriPatterns maps resource target classes to the pattern of their public resource URIs, f.e.
+	// "Vendor\\Cdn\\CdnTarget": "https://cdn.example.com/{keyPrefix}{sha1}/{filename}"
+	TargetUriPatterns map[string]string `yaml:"targetUriPatterns"`
+}
+
+type SyncoServeDatabaseConfig struct {
+	// Driver is f.e. "mysql", "mariadb" or "sqlite"
+	Driver string `yaml:"driver"`
+	Host   Value  `yaml:"host"`
+	Port   Value  `yaml:"port"`
+	// Socket is the path to a unix socket; if set, Host and Port are ignored
+	Socket   Value `yaml:"socket"`
+	User     Value `yaml:"user"`
+	Password Value `yaml:"password"`
+	DbName   Value `yaml:"dbName"`
+	// Path is the database file for the sqlite driver
+	Path Value `yaml:"path"`
+}
+
+type SyncoServePublicDirectory struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	// BaseUri is the URI under which Path is reachable on the web server - either relative to the web directory,
+	// or an absolute URL. If empty, Path relative to WebDirectory is used.
+	BaseUri string `yaml:"baseUri"`
+}
+
+type SyncoServePrivateDirectory struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+}
+
+// Value is a single config value, which can be specified in the following ways:
+//
+//	host: 127.0.0.1            # literal value
+//	host: {env: DB_HOST}       # read from the environment variable DB_HOST
+//	host: {dotenv: DB_HOST}    # read DB_HOST from the .env file
+//	host: {dotenv: DB_HOST, file: .env.production}
+type Value struct {
+	Literal string
+	Env     string `yaml:"env"`
+	Dotenv  string `yaml:"dotenv"`
+	File    string `yaml:"file"`
+}
+
+func (v *Value) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		v.Literal = node.Value
+		return nil
+	}
+	// we need a separate type here, otherwise we would recurse endlessly into UnmarshalYAML.
+	type valueReference Value
+	return node.Decode((*valueReference)(v))
+}
+
+// Resolve returns the actual value, by reading the referenced environment variable or dotenv file if needed.
+func (v Value) Resolve() (string, error) {
+	if len(v.Env) > 0 {
+		value, found := os.LookupEnv(v.Env)
+		if !found {
+			return "", fmt.Errorf("environment variable %s is not set", v.Env)
+		}
+		return value, nil
+	}
+
+	if len(v.Dotenv) > 0 {
+		file := v.File
+		if len(file) == 0 {
+			file = ".env"
+		}
+		values, err := util.ReadDotenvFile(file)
+		if err != nil {
+			return "", err
+		}
+		value, found := values[v.Dotenv]
+		if !found {
+			return "", fmt.Errorf("key %s not found in %s", v.Dotenv, file)
+		}
+		return value, nil
+	}
+
+	return v.Literal, nil
+}
+
+// ReadServeConfigFromYaml reads .synco-serve.yml from the current directory. The returned config is nil if the
+// file does not exist.
+func ReadServeConfigFromYaml() (*SyncoServeConfig, error) {
+	file, err := os.ReadFile(SyncoServeYamlFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var syncoServeConfig SyncoServeConfig
+	err = yaml.Unmarshal(file, &syncoServeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("malformed YAML in %s: %w", SyncoServeYamlFile, err)
+	}
+	return &syncoServeConfig, nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const serveConfigYaml = `
+database:
+  driver: mysql
+  host: 127.0.0.1
+  port: {env: SYNCO_TEST_DB_PORT}
+  user: {dotenv: DB_USERNAME, file: %DOTENV%}
+  password: {dotenv: DB_PASSWORD, file: %DOTENV%}
+  dbName: app
+webDirectory: web
+publicDirectories:
+  - name: uploads
+    path: web/uploads
+privateDirectories:
+  - name: storage
+    path: storage
+`
+
+const dotenv = `
+# comment
+DB_USERNAME=app_user
+export DB_PASSWORD="se#cret \"quoted\""
+UNQUOTED=value # trailing comment
+`
+
+func TestServeConfigValuesAreResolved(t *testing.T) {
+	dotenvFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(dotenvFile, []byte(dotenv), 0644); err != nil {
+		t.Fatalf("writing dotenv file: %v", err)
+	}
+	t.Setenv("SYNCO_TEST_DB_PORT", "3307")
+
+	var serveConfig SyncoServeConfig
+	yamlString := []byte(strings.ReplaceAll(serveConfigYaml, "%DOTENV%", dotenvFile))
+	if err := yaml.Unmarshal(yamlString, &serveConfig); err != nil {
+		t.Fatalf("unmarshalling YAML: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value Value
+		want  string
+	}{
+		{"literal", serveConfig.Database.Host, "127.0.0.1"},
+		{"env", serveConfig.Database.Port, "3307"},
+		{"dotenv", serveConfig.Database.User, "app_user"},
+		{"quoted dotenv", serveConfig.Database.Password, `se#cret "quoted"`},
+		{"literal", serveConfig.Database.DbName, "app"},
+	}
+	for _, tt := range tests {
+		got, err := tt.value.Resolve()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if len(serveConfig.PublicDirectories) != 1 || serveConfig.PublicDirectories[0].Path != "web/uploads" {
+		t.Errorf("unexpected public directories: %v", serveConfig.PublicDirectories)
+	}
+}
+
+func TestServeConfigValueFailsOnMissingEnv(t *testing.T) {
+	_, err := Value{Env: "SYNCO_TEST_DOES_NOT_EXIST"}.Resolve()
+	if err == nil {
+		t.Errorf("expected error for missing environment variable")
+	}
+}
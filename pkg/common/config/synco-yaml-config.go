@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
+	"path"
 )
 
 const SyncoYamlFile = ".synco.yml"
@@ -13,6 +14,49 @@ const SyncoYamlFile = ".synco.yml"
 // client-side config (for `synco receive`).
 type SyncoConfig struct {
 	Hosts []SyncoHostConfig `yaml:"hosts"`
+	Hooks SyncoReceiveHooks `yaml:"hooks,omitempty"`
+}
+
+// SyncoReceiveHooks are shell commands run by `synco receive`. The commands get SYNCO_FILESET_NAME,
+// SYNCO_FILESET_TYPE and SYNCO_DUMP_DIR as environment variables.
+type SyncoReceiveHooks struct {
+	BeforeFileSet []SyncoReceiveHook `yaml:"beforeFileSet,omitempty"`
+	AfterFileSet  []SyncoReceiveHook `yaml:"afterFileSet,omitempty"`
+	// AfterReceive runs once all file sets were downloaded (f.e. migrations or cache warmup)
+	AfterReceive []SyncoReceiveHook `yaml:"afterReceive,omitempty"`
+}
+
+// SyncoReceiveHook is either a plain command, or a command restricted to file sets matching a glob pattern:
+//
+//   - ./reset-local-users.sh
+//   - {command: "./flow doctrine:migrate", fileSet: "dbDump*"}
+type SyncoReceiveHook struct {
+	Command string `yaml:"command"`
+	FileSet string `yaml:"fileSet,omitempty"`
+}
+
+func (h *SyncoReceiveHook) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		h.Command = node.Value
+		return nil
+	}
+	// we need a separate type here, otherwise we would recurse endlessly into UnmarshalYAML.
+	type hookReference SyncoReceiveHook
+	return node.Decode((*hookReference)(h))
+}
+
+// CommandsForFileSet returns the commands of all hooks applying to the file set with the given name.
+func CommandsForFileSet(hooks []SyncoReceiveHook, fileSetName string) []string {
+	commands := make([]string, 0, len(hooks))
+	for _, hook := range hooks {
+		if len(hook.FileSet) > 0 {
+			if matched, _ := path.Match(hook.FileSet, fileSetName); !matched {
+				continue
+			}
+		}
+		commands = append(commands, hook.Command)
+	}
+	return commands
 }
 
 type SyncoHostConfig struct {
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const syncoConfigYaml = `
+hosts:
+  - baseUrl: https://www.example.com
+hooks:
+  beforeFileSet:
+    - {command: "./flow flow:cache:flush", fileSet: "dbDump*"}
+  afterFileSet:
+    - ./import.sh
+    - {command: "./flow doctrine:migrate", fileSet: "dbDump*"}
+  afterReceive:
+    - ./warmup.sh
+`
+
+func TestReceiveHooksForFileSet(t *testing.T) {
+	var syncoConfig SyncoConfig
+	if err := yaml.Unmarshal([]byte(syncoConfigYaml), &syncoConfig); err != nil {
+		t.Fatalf("unmarshalling: %v", err)
+	}
+
+	tests := []struct {
+		hooks       []SyncoReceiveHook
+		fileSetName string
+		want        []string
+	}{
+		{syncoConfig.Hooks.BeforeFileSet, "dbDump", []string{"./flow flow:cache:flush"}},
+		{syncoConfig.Hooks.BeforeFileSet, "Resources", []string{}},
+		{syncoConfig.Hooks.AfterFileSet, "dbDump-tenant", []string{"./import.sh", "./flow doctrine:migrate"}},
+		{syncoConfig.Hooks.AfterFileSet, "Resources", []string{"./import.sh"}},
+		{syncoConfig.Hooks.AfterReceive, "", []string{"./warmup.sh"}},
+	}
+	for _, tt := range tests {
+		got := CommandsForFileSet(tt.hooks, tt.fileSetName)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.fileSetName, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package dto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// EncodeChecksums encodes the table checksums of a database dump into a short string, which can be passed to
+// `synco serve --known-checksums` for an incremental refresh.
+func EncodeChecksums(checksums map[string]string) (string, error) {
+	tables := make([]string, 0, len(checksums))
+	for table := range checksums {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	for _, table := range tables {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", table, checksums[table]); err != nil {
+			return "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeChecksums is the inverse of EncodeChecksums
+func DecodeChecksums(encoded string) (map[string]string, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksums: %w", err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksums: %w", err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksums: %w", err)
+	}
+
+	result := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		table, checksum, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid checksums: line %q", line)
+		}
+		result[table] = checksum
+	}
+	return result, nil
+}
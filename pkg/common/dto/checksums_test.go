@@ -0,0 +1,24 @@
+package dto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChecksumsRoundTrip(t *testing.T) {
+	checksums := map[string]string{"orders": "0123456789abcdef", "users": "fedcba9876543210"}
+	encoded, err := EncodeChecksums(checksums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeChecksums(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(checksums, decoded) {
+		t.Errorf("got %v, want %v", decoded, checksums)
+	}
+	if _, err := DecodeChecksums("not-valid"); err == nil {
+		t.Errorf("expected an error for invalid checksums")
+	}
+}
@@ -25,6 +25,9 @@ type Meta struct {
 	State         State      `json:"state"`
 	FrameworkName string     `json:"frameworkName"`
 	FileSets      []*FileSet `json:"fileSets"`
+	// PostReceiveHints are shown to the user after all file sets have been downloaded (f.e. commands to run
+	// for rebuilding data which was not transferred).
+	PostReceiveHints []string `json:"postReceiveHints,omitempty"`
 }
 
 func (m Meta) FileSetByLabel(label string) *FileSet {
@@ -40,12 +43,32 @@ func (m Meta) FileSetByLabel(label string) *FileSet {
 type FileSetType string
 
 const (
-	TYPE_MYSQLDUMP               FileSetType = "MysqlDump"
+	TYPE_MYSQLDUMP FileSetType = "MysqlDump"
+	// TYPE_MYSQLDUMP_CHUNKED is a MysqlDump with Chunks (dumped in parallel); it has its own type, so that older
+	// synco versions (which would only download the schema objects of FileName) refuse to download it.
+	TYPE_MYSQLDUMP_CHUNKED       FileSetType = "MysqlDumpChunked"
 	TYPE_POSTGRESDUMP            FileSetType = "PostgresDump"
 	TYPE_PUBLICFILES             FileSetType = "PublicFiles"
 	TYPE_PRIVATE_ENCRYPTED_FILES FileSetType = "PrivateEncryptedFiles"
+	TYPE_SQLITE                  FileSetType = "Sqlite"
 )
 
+// WithCompression appends the codec to the type of a compressed file set (f.e. "MysqlDump+zstd"), so that older
+// synco versions (which do not know Compression, and would store the compressed bytes as if they were plain)
+// refuse to download it.
+func (t FileSetType) WithCompression(codec string) FileSetType {
+	if len(codec) == 0 {
+		return t
+	}
+	return t + "+" + FileSetType(codec)
+}
+
+// Base returns the type without the compression codec (see WithCompression)
+func (t FileSetType) Base() FileSetType {
+	base, _, _ := strings.Cut(string(t), "+")
+	return FileSetType(base)
+}
+
 type FileSet struct {
 	Name                  string                        `json:"name"`
 	Type                  FileSetType                   `json:"type"`
@@ -53,12 +76,17 @@ type FileSet struct {
 	PostgresDump          *FileSetPostgresDump          `json:"postgresDump"`
 	PublicFiles           *FileSetPublicFiles           `json:"publicFiles"`
 	PrivateEncryptedFiles *FileSetPrivateEncryptedFiles `json:"privateEncryptedFiles"`
+	Sqlite                *FileSetSqlite                `json:"sqlite,omitempty"`
+	// Compression is the codec (f.e. gzip or zstd) the files of the file set were compressed with before encrypting;
+	// empty if uncompressed. Clients not knowing the codec must refuse to download the file set. The codec is also
+	// part of the Type (see FileSetType.WithCompression).
+	Compression string `json:"compression,omitempty"`
 }
 
 func (fileSet *FileSet) Label() string {
 
-	switch fileSet.Type {
-	case TYPE_MYSQLDUMP:
+	switch fileSet.Type.Base() {
+	case TYPE_MYSQLDUMP, TYPE_MYSQLDUMP_CHUNKED:
 		return fmt.Sprintf("%s (%s: %s)", fileSet.Name, fileSet.Type, humanize.IBytes(fileSet.MysqlDump.SizeBytes))
 	case TYPE_POSTGRESDUMP:
 		return fmt.Sprintf("%s (%s: %s)", fileSet.Name, fileSet.Type, humanize.IBytes(fileSet.PostgresDump.SizeBytes))
@@ -66,18 +94,58 @@ func (fileSet *FileSet) Label() string {
 		return fmt.Sprintf("%s (%s: %s)", fileSet.Name, fileSet.Type, humanize.IBytes(fileSet.PublicFiles.SizeBytes))
 	case TYPE_PRIVATE_ENCRYPTED_FILES:
 		return fmt.Sprintf("%s (%s: %s)", fileSet.Name, fileSet.Type, humanize.IBytes(fileSet.PrivateEncryptedFiles.SizeBytes))
+	case TYPE_SQLITE:
+		return fmt.Sprintf("%s (%s: %s)", fileSet.Name, fileSet.Type, humanize.IBytes(fileSet.Sqlite.SizeBytes))
 	default:
 		return fmt.Sprintf("%s (%s)", fileSet.Name, fileSet.Type)
 	}
 }
 
+// IsMysqlDump is true for all types of MySQL dumps (see FileSet.MysqlDump)
+func (fileSet *FileSet) IsMysqlDump() bool {
+	return fileSet.Type.Base() == TYPE_MYSQLDUMP || fileSet.Type.Base() == TYPE_MYSQLDUMP_CHUNKED
+}
+
 func extractNameFromLabel(label string) string {
 	tmp := strings.SplitN(label, " (", 2)
 	return tmp[0]
 }
 
 type FileSetMysqlDump struct {
+	FileName string `json:"fileName"`
+	// SizeBytes is the size of FileName and all Chunks
+	SizeBytes uint64 `json:"sizeBytes"`
+	// Chunks are set if the tables were dumped in parallel: every table is a self-contained SQL file, which can be
+	// imported in parallel. FileName then only contains the schema objects (triggers, views, ...) and has to be
+	// imported after all chunks.
+	Chunks []FileSetMysqlDumpChunk `json:"chunks,omitempty"`
+	// Checksums of all tables (by table name), if the dump was created with checksums; see EncodeChecksums.
+	Checksums map[string]string `json:"checksums,omitempty"`
+	// UnchangedTables were not dumped, as their checksum matched the known checksums of the receiving side.
+	UnchangedTables []string `json:"unchangedTables,omitempty"`
+	// Tables describes the dumped tables; empty for dumps of older synco versions.
+	Tables []FileSetMysqlDumpTable `json:"tables,omitempty"`
+	// ServerVersion of the dumped database, f.e. "8.4.2"
+	ServerVersion string `json:"serverVersion,omitempty"`
+}
+
+// FileSetMysqlDumpTable describes what was dumped of a table
+type FileSetMysqlDumpTable struct {
+	Name string `json:"name"`
+	// Mode is full, schema-only or data-only
+	Mode string `json:"mode"`
+	Rows int64  `json:"rows"`
+	// SizeBytes is the size of the SQL of the table, before compression and encryption
+	SizeBytes uint64 `json:"sizeBytes"`
+	// Filter describes the where clause / row limit of the table; empty if all rows were dumped
+	Filter string `json:"filter,omitempty"`
+	// RowHash is the hash of all dumped rows, for verifying an import; only set for dumps with checksums
+	RowHash string `json:"rowHash,omitempty"`
+}
+
+type FileSetMysqlDumpChunk struct {
 	FileName  string `json:"fileName"`
+	Table     string `json:"table"`
 	SizeBytes uint64 `json:"sizeBytes"`
 }
 
@@ -89,12 +157,23 @@ type FileSetPostgresDump struct {
 type FileSetPublicFiles struct {
 	IndexFileName string `json:"indexFileName"`
 	SizeBytes     uint64 `json:"sizeBytes"`
+	FileCount     int    `json:"fileCount,omitempty"`
 }
 
 type FileSetPrivateEncryptedFiles struct {
 	SizeBytes        uint64 `json:"sizeBytes"`
 	TarUri           string `json:"tarUri"`
 	RelativeBasePath string `json:"relativeBasePath"`
+	FileCount        int    `json:"fileCount,omitempty"`
+}
+
+// FileSetSqlite is a consistent copy of a SQLite database file.
+type FileSetSqlite struct {
+	FileName  string `json:"fileName"`
+	SizeBytes uint64 `json:"sizeBytes"`
+	// RelativePath is the location of the database file relative to the application root (f.e. database/database.sqlite);
+	// the file is restored to the same path inside dump/.
+	RelativePath string `json:"relativePath"`
 }
 
 // PublicFilesIndex is the structure of the "index" file for FileSetPublicFiles.
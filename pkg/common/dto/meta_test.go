@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// oldClientCanDownload is the type switch of `synco receive` before compression and parallel dumps existed; it
+// fails with "File Set type ... was unimplemented" for all other types.
+func oldClientCanDownload(fileSetType FileSetType) bool {
+	switch fileSetType {
+	case "MysqlDump", "PublicFiles", "PrivateEncryptedFiles", "Sqlite":
+		return true
+	default:
+		return false
+	}
+}
+
+func TestOldClientsRejectFileSetsTheyWouldCorrupt(t *testing.T) {
+	fileSets := []*FileSet{
+		{Name: "dbDump", Type: TYPE_MYSQLDUMP.WithCompression("zstd"), Compression: "zstd", MysqlDump: &FileSetMysqlDump{}},
+		{Name: "dbDump", Type: TYPE_MYSQLDUMP_CHUNKED, MysqlDump: &FileSetMysqlDump{Chunks: []FileSetMysqlDumpChunk{{Table: "a"}}}},
+		{Name: "private", Type: TYPE_PRIVATE_ENCRYPTED_FILES.WithCompression("gzip"), Compression: "gzip", PrivateEncryptedFiles: &FileSetPrivateEncryptedFiles{}},
+		{Name: "db.sqlite", Type: TYPE_SQLITE.WithCompression("gzip"), Compression: "gzip", Sqlite: &FileSetSqlite{}},
+	}
+	for _, fileSet := range fileSets {
+		encoded, err := json.Marshal(fileSet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// old clients only know these fields
+		var old struct {
+			Name string      `json:"name"`
+			Type FileSetType `json:"type"`
+		}
+		if err := json.Unmarshal(encoded, &old); err != nil {
+			t.Fatal(err)
+		}
+		if oldClientCanDownload(old.Type) {
+			t.Errorf("%s: an old client would download the file set of type %s", fileSet.Name, old.Type)
+		}
+		if !oldClientCanDownload(fileSet.Type.Base()) && fileSet.Type.Base() != TYPE_MYSQLDUMP_CHUNKED {
+			t.Errorf("%s: unexpected base type %s", fileSet.Name, fileSet.Type.Base())
+		}
+	}
+
+	// uncompressed file sets stay readable for old clients
+	if !oldClientCanDownload(TYPE_MYSQLDUMP.WithCompression("")) {
+		t.Errorf("uncompressed dumps must keep their type")
+	}
+	if !(&FileSet{Type: TYPE_MYSQLDUMP_CHUNKED.WithCompression("zstd")}).IsMysqlDump() {
+		t.Errorf("compressed chunked dumps are MySQL dumps")
+	}
+}
@@ -0,0 +1,128 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	FILENAME_REPORT_JSON     = "report.json.enc"
+	FILENAME_REPORT_MARKDOWN = "report.md.enc"
+)
+
+// Report describes a serve session for audits: where the data came from, what was transferred and how long it
+// took. It is stored encrypted next to meta.json.enc, as JSON and as Markdown.
+type Report struct {
+	Identifier string `json:"identifier"`
+	Framework  string `json:"framework"`
+	// ConfigSources are the places the configuration was read from, f.e. "./flow configuration:show" or
+	// ".synco-serve.yml"
+	ConfigSources []string        `json:"configSources"`
+	StartedAt     time.Time       `json:"startedAt"`
+	FinishedAt    time.Time       `json:"finishedAt"`
+	FileSets      []ReportFileSet `json:"fileSets"`
+	Warnings      []string        `json:"warnings"`
+	Phases        []ReportPhase   `json:"phases"`
+}
+
+type ReportFileSet struct {
+	Name string      `json:"name"`
+	Type FileSetType `json:"type"`
+	// Files is the number of files - or tables, for database dumps
+	Files       int    `json:"files"`
+	SizeBytes   uint64 `json:"sizeBytes"`
+	Compression string `json:"compression,omitempty"`
+	// ServerVersion of the database, for database dumps
+	ServerVersion string                  `json:"serverVersion,omitempty"`
+	Tables        []FileSetMysqlDumpTable `json:"tables,omitempty"`
+}
+
+// ReportPhase is a step of the serve session, f.e. the dump of a database
+type ReportPhase struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// NewReportFileSet summarizes a file set of the meta data
+func NewReportFileSet(fileSet *FileSet) ReportFileSet {
+	result := ReportFileSet{
+		Name:        fileSet.Name,
+		Type:        fileSet.Type.Base(),
+		Compression: fileSet.Compression,
+	}
+	switch {
+	case fileSet.MysqlDump != nil:
+		result.Files = len(fileSet.MysqlDump.Tables)
+		result.SizeBytes = fileSet.MysqlDump.SizeBytes
+		result.ServerVersion = fileSet.MysqlDump.ServerVersion
+		result.Tables = fileSet.MysqlDump.Tables
+	case fileSet.PostgresDump != nil:
+		result.Files = 1
+		result.SizeBytes = fileSet.PostgresDump.SizeBytes
+	case fileSet.PublicFiles != nil:
+		result.Files = fileSet.PublicFiles.FileCount
+		result.SizeBytes = fileSet.PublicFiles.SizeBytes
+	case fileSet.PrivateEncryptedFiles != nil:
+		result.Files = fileSet.PrivateEncryptedFiles.FileCount
+		result.SizeBytes = fileSet.PrivateEncryptedFiles.SizeBytes
+	case fileSet.Sqlite != nil:
+		result.Files = 1
+		result.SizeBytes = fileSet.Sqlite.SizeBytes
+	}
+	return result
+}
+
+// Markdown renders the report for humans
+func (r Report) Markdown() string {
+	var md strings.Builder
+	fmt.Fprintf(&md, "# synco report %s\n\n", r.Identifier)
+	fmt.Fprintf(&md, "- Framework: %s\n", r.Framework)
+	fmt.Fprintf(&md, "- Configuration read from: %s\n", strings.Join(r.ConfigSources, ", "))
+	fmt.Fprintf(&md, "- Started: %s\n", r.StartedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&md, "- Finished: %s (%s)\n", r.FinishedAt.UTC().Format(time.RFC3339), r.FinishedAt.Sub(r.StartedAt).Round(time.Second))
+
+	md.WriteString("\n## File Sets\n\n")
+	md.WriteString("| Name | Type | Files / Tables | Size | Compression |\n")
+	md.WriteString("|---|---|---:|---:|---|\n")
+	for _, fileSet := range r.FileSets {
+		fmt.Fprintf(&md, "| %s | %s | %d | %s | %s |\n", markdownCell(fileSet.Name), fileSet.Type, fileSet.Files, humanize.IBytes(fileSet.SizeBytes), fileSet.Compression)
+	}
+
+	for _, fileSet := range r.FileSets {
+		if len(fileSet.Tables) == 0 {
+			continue
+		}
+		fmt.Fprintf(&md, "\n### %s\n\n", fileSet.Name)
+		if len(fileSet.ServerVersion) > 0 {
+			fmt.Fprintf(&md, "Server version: %s\n\n", fileSet.ServerVersion)
+		}
+		md.WriteString("| Table | Mode | Rows | Size | Filter |\n")
+		md.WriteString("|---|---|---:|---:|---|\n")
+		for _, table := range fileSet.Tables {
+			fmt.Fprintf(&md, "| %s | %s | %d | %s | %s |\n", markdownCell(table.Name), table.Mode, table.Rows, humanize.IBytes(table.SizeBytes), markdownCell(table.Filter))
+		}
+	}
+
+	md.WriteString("\n## Warnings\n\n")
+	if len(r.Warnings) == 0 {
+		md.WriteString("None.\n")
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(&md, "- %s\n", strings.ReplaceAll(warning, "\n", " "))
+	}
+
+	md.WriteString("\n## Phases\n\n")
+	md.WriteString("| Phase | Duration |\n")
+	md.WriteString("|---|---:|\n")
+	for _, phase := range r.Phases {
+		fmt.Fprintf(&md, "| %s | %s |\n", markdownCell(phase.Name), time.Duration(phase.DurationSeconds*float64(time.Second)).Round(time.Millisecond))
+	}
+	return md.String()
+}
+
+func markdownCell(value string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(value), " "), "|", "\\|")
+}
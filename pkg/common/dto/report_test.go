@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportMarkdown(t *testing.T) {
+	startedAt := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	report := Report{
+		Identifier:    "synco-abc",
+		Framework:     "Laravel",
+		ConfigSources: []string{".synco-serve.yml"},
+		StartedAt:     startedAt,
+		FinishedAt:    startedAt.Add(90 * time.Second),
+		FileSets: []ReportFileSet{NewReportFileSet(&FileSet{
+			Name: "dbDump-mysql",
+			Type: TYPE_MYSQLDUMP,
+			MysqlDump: &FileSetMysqlDump{
+				SizeBytes:     4096,
+				ServerVersion: "8.4.2",
+				Tables: []FileSetMysqlDumpTable{
+					{Name: "logs", Mode: "full", Rows: 10, SizeBytes: 1024, Filter: "WHERE level = 'a|b'\n AND id > 5"},
+				},
+			},
+		})},
+		Phases: []ReportPhase{{Name: "database dump dbDump-mysql", DurationSeconds: 1.5}},
+	}
+
+	markdown := report.Markdown()
+	for _, want := range []string{
+		"- Finished: 2026-10-19T10:01:30Z (1m30s)",
+		"| dbDump-mysql | MysqlDump | 1 | 4.0 KiB |  |",
+		"Server version: 8.4.2",
+		"| logs | full | 10 | 1.0 KiB | WHERE level = 'a\\|b' AND id > 5 |",
+		"None.",
+		"| database dump dbDump-mysql | 1.5s |",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("expected %q in:\n%s", want, markdown)
+		}
+	}
+}
@@ -11,14 +11,38 @@ type ReceiveFramework interface {
 }
 
 type ServeFramework interface {
+	// Id is the short identifier used for `synco serve --framework <id>`
+	Id() string
 	Name() string
-	Detect() bool
+	// Detect inspects the working directory; if multiple frameworks are detected, the one with the
+	// highest confidence is used - unless the runner-up is about as likely.
+	Detect() DetectionResult
 	Serve(metadata *serve.TransferSession)
 }
 
+// DetectionResult describes how sure a framework is that it can handle the application in the working directory.
+type DetectionResult struct {
+	// Confidence is 0 if the framework was not detected; the higher, the more certain.
+	Confidence int
+	// Reasons are shown to the user, explaining the confidence.
+	Reasons []string
+}
+
+func (d DetectionResult) Detected() bool {
+	return d.Confidence > 0
+}
+
+// AddReason increases the confidence by the given amount, and records the reason for it.
+func (d *DetectionResult) AddReason(confidence int, reason string) {
+	d.Confidence += confidence
+	d.Reasons = append(d.Reasons, reason)
+}
+
 type DbCredentials struct {
-	Host     string
-	Port     int
+	Host string
+	Port int
+	// Socket is the path to a unix socket; if set, Host and Port are ignored.
+	Socket   string
 	User     string
 	Password string
 	DbName   string
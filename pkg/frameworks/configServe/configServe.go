@@ -0,0 +1,200 @@
+package configServe
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common"
+	"github.com/sandstorm/synco/v2/pkg/common/commonServe"
+	"github.com/sandstorm/synco/v2/pkg/common/config"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/serve"
+)
+
+// configServe is a "manual" framework which is configured completely by the .synco-serve.yml file;
+// so that synco can be used for any application stack without writing Go code for it.
+type configServe struct {
+}
+
+func (c configServe) Id() string {
+	return "config"
+}
+
+func (c configServe) Name() string {
+	return "Config File"
+}
+
+func (c configServe) Detect() common.DetectionResult {
+	serveConfig, err := config.ReadServeConfigFromYaml()
+	if err != nil {
+		pterm.Warning.Printfln("could not read %s: %s", config.SyncoServeYamlFile, err)
+		return common.DetectionResult{}
+	}
+	if serveConfig == nil {
+		pterm.Debug.Printfln("./%s not found, thus no manually configured application", config.SyncoServeYamlFile)
+		return common.DetectionResult{}
+	}
+	if serveConfig.Database == nil || len(serveConfig.Database.Driver) == 0 {
+		pterm.Debug.Printfln("./%s does not contain database.driver, thus no manually configured application", config.SyncoServeYamlFile)
+		return common.DetectionResult{}
+	}
+
+	// an explicit configuration always wins over auto-detected frameworks.
+	return common.DetectionResult{
+		Confidence: 1000,
+		Reasons:    []string{"./" + config.SyncoServeYamlFile + " contains database.driver"},
+	}
+}
+
+func (c configServe) Serve(transferSession *serve.TransferSession) {
+	serveConfig, err := config.ReadServeConfigFromYaml()
+	if err != nil {
+		pterm.Fatal.Printfln("could not read %s: %s", config.SyncoServeYamlFile, err)
+	}
+	if serveConfig == nil {
+		pterm.Fatal.Printfln("./%s not found - it is required for the %s framework.", config.SyncoServeYamlFile, c.Id())
+	}
+
+	webDirectory := serveConfig.WebDirectory
+	if len(webDirectory) == 0 {
+		webDirectory = "public"
+	}
+	err = transferSession.WithFrameworkAndWebDirectory(c.Name(), webDirectory)
+	if err != nil {
+		pterm.Fatal.Printfln("Error writing transferSession: %s", err)
+	}
+
+	switch {
+	case serveConfig.Database == nil:
+		transferSession.Warn("No database configured in %s - so NOT transferring a database.", config.SyncoServeYamlFile)
+	case serveConfig.Database.Driver == "mysql" || serveConfig.Database.Driver == "mariadb":
+		dbCredentials, err := toDbCredentials(serveConfig.Database)
+		if err != nil {
+			pterm.Fatal.Printfln("could not read database credentials from %s: %s", config.SyncoServeYamlFile, err)
+		}
+		if len(dbCredentials.Socket) > 0 {
+			pterm.Info.Printfln("Extracted Database Socket %s, User: %s", dbCredentials.Socket, dbCredentials.User)
+		} else {
+			pterm.Info.Printfln("Extracted Database Host %s, User: %s", dbCredentials.Host, dbCredentials.User)
+		}
+		tx := commonServe.DatabaseDump(transferSession, "dbDump", dbCredentials, map[string]string{}, map[string]string{}, nil)
+		_ = tx.Rollback()
+	case serveConfig.Database.Driver == "sqlite":
+		dbPath, err := serveConfig.Database.Path.Resolve()
+		if err != nil {
+			pterm.Fatal.Printfln("could not read database path from %s: %s", config.SyncoServeYamlFile, err)
+		}
+		if len(dbPath) == 0 {
+			pterm.Fatal.Printfln("database.path must be set in %s for the sqlite driver.", config.SyncoServeYamlFile)
+		}
+		pterm.Info.Printfln("Extracted SQLite Database %s", dbPath)
+		err = commonServe.SqliteDump(transferSession, "dbDump", dbPath)
+		if err != nil {
+			pterm.Fatal.Printfln("could not create SQLite dump: %s", err)
+		}
+	default:
+		transferSession.Warn("Database driver %s not supported right now - so NOT transferring the database.", serveConfig.Database.Driver)
+	}
+
+	// 1) extract PUBLIC folders
+	skipDirs := make(map[string]bool)
+	for _, directory := range serveConfig.PublicDirectories {
+		directory.Path = filepath.Clean(directory.Path)
+		baseUri := directory.BaseUri
+		if len(baseUri) == 0 {
+			baseUri, err = baseUriInWebDirectory(webDirectory, directory.Path)
+			if err != nil {
+				pterm.Fatal.Printfln("public directory %s is not inside the web directory %s - please specify baseUri for it.", directory.Path, webDirectory)
+			}
+		}
+		pterm.Info.Printfln("Extracting public resources for %s (path=%s, baseUri=%s)", directory.Name, directory.Path, baseUri)
+		commonServe.ExtractAllResourcesFromFolder(transferSession, directory.Name, directory.Path, baseUri)
+		// public folders nested inside private folders are not included in the private dump.
+		skipDirs[directory.Path] = true
+	}
+	// 2) extract PRIVATE folders, but skipping public nested ones
+	for _, directory := range serveConfig.PrivateDirectories {
+		directory.Path = filepath.Clean(directory.Path)
+		pterm.Info.Printfln("Encrypting and extracting private resources for %s (path=%s)", directory.Name, directory.Path)
+		commonServe.EncryptAndExtractAllResourcesFromFolder(transferSession, directory.Name, directory.Path, skipDirs)
+	}
+
+	transferSession.Meta.State = dto.STATE_READY
+	err = transferSession.UpdateMetadata()
+	if err != nil {
+		pterm.Fatal.Printfln("could not update state: %s", err)
+	}
+	pterm.Success.Printfln("")
+	pterm.Success.Printfln("=================================================================================")
+	pterm.Success.Printfln("")
+
+	transferSession.RenderConnectCommand()
+
+	pterm.Success.Printfln("")
+	pterm.Success.Printfln("=================================================================================")
+	pterm.Success.Printfln("")
+}
+
+func toDbCredentials(database *config.SyncoServeDatabaseConfig) (*common.DbCredentials, error) {
+	host, err := database.Host.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("host: %w", err)
+	}
+	portString, err := database.Port.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("port: %w", err)
+	}
+	socket, err := database.Socket.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("socket: %w", err)
+	}
+	user, err := database.User.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("user: %w", err)
+	}
+	password, err := database.Password.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("password: %w", err)
+	}
+	dbName, err := database.DbName.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("dbName: %w", err)
+	}
+
+	port := 3306
+	if len(portString) != 0 {
+		port, err = strconv.Atoi(portString)
+		if err != nil {
+			return nil, fmt.Errorf("port %q is no number: %w", portString, err)
+		}
+	}
+
+	return &common.DbCredentials{
+		Host:     host,
+		Port:     port,
+		Socket:   socket,
+		User:     user,
+		Password: password,
+		DbName:   dbName,
+	}, nil
+}
+
+func NewConfigFramework() common.ServeFramework {
+	return &configServe{}
+}
+
+// baseUriInWebDirectory returns the path of the public directory relative to the web directory, which is its URI.
+func baseUriInWebDirectory(webDirectory string, directoryPath string) (string, error) {
+	baseUri, err := filepath.Rel(webDirectory, directoryPath)
+	if err != nil {
+		return "", err
+	}
+	// filepath.Rel does not fail for directories outside of webDirectory, but returns "../..."
+	if baseUri == ".." || strings.HasPrefix(baseUri, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not inside %s", directoryPath, webDirectory)
+	}
+	return filepath.ToSlash(baseUri), nil
+}
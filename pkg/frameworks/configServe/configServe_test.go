@@ -0,0 +1,33 @@
+package configServe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeWithoutConfigFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// pterm.Fatal panics with an empty message; a nil pointer dereference would panic with a runtime error instead.
+	assert.PanicsWithValue(t, "", func() {
+		configServe{}.Serve(nil)
+	})
+}
+
+func TestBaseUriInWebDirectory(t *testing.T) {
+	baseUri, err := baseUriInWebDirectory("public", "public/uploads")
+	assert.NoError(t, err)
+	assert.Equal(t, "uploads", baseUri)
+
+	baseUri, err = baseUriInWebDirectory("public", "public/..uploads/files")
+	assert.NoError(t, err)
+	assert.Equal(t, "..uploads/files", baseUri)
+
+	_, err = baseUriInWebDirectory("public", "storage/uploads")
+	assert.ErrorContains(t, err, "is not inside public")
+	_, err = baseUriInWebDirectory("public/uploads", "public")
+	assert.Error(t, err)
+	_, err = baseUriInWebDirectory("/app/public", "storage")
+	assert.Error(t, err)
+}
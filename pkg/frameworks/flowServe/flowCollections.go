@@ -0,0 +1,267 @@
+package flowServe
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common/commonServe"
+	"github.com/sandstorm/synco/v2/pkg/serve"
+	"github.com/sandstorm/synco/v2/pkg/util/s3"
+	"gopkg.in/yaml.v3"
+)
+
+type flowResourceStorage struct {
+	Storage        string `yaml:"storage"`
+	StorageOptions struct {
+		// **for Neos\Flow\ResourceManagement\Storage\WritableFileSystemStorage:**
+		// f.e. /app/Data/Persistent/Resources/
+		Path string `yaml:"path"`
+
+		// **for Flownative\Aws\S3\S3Storage**
+		Bucket    string `yaml:"bucket"`
+		KeyPrefix string `yaml:"keyPrefix"`
+	} `yaml:"storageOptions"`
+}
+
+func (s flowResourceStorage) IsPackageStorage() bool {
+	return s.Storage == "Neos\\Flow\\ResourceManagement\\Storage\\PackageStorage"
+}
+
+func (s flowResourceStorage) IsFileSystemStorage() bool {
+	return s.Storage == "Neos\\Flow\\ResourceManagement\\Storage\\WritableFileSystemStorage" ||
+		s.Storage == "Neos\\Flow\\ResourceManagement\\Storage\\FileSystemStorage"
+}
+
+func (s flowResourceStorage) IsS3Storage() bool {
+	return s.Storage == "Flownative\\Aws\\S3\\S3Storage"
+}
+
+// flowCollection is a resource collection, with its storage and target resolved.
+type flowCollection struct {
+	Name    string
+	Storage *flowResourceStorage
+	Target  *flowResourceTarget
+	// resolver is set for targets publishing to an object storage or CDN
+	resolver targetResolver
+}
+
+// fileSetName is "Resources" for the persistent collection (as before synco supported multiple collections),
+// and Resources-<collection> for all others.
+func (c flowCollection) fileSetName() string {
+	if c.Name == "persistent" {
+		return FlowResources
+	}
+	return FlowResources + "-" + c.Name
+}
+
+// isPublic returns true if the resources of the collection can be downloaded via HTTP from their target.
+func (c flowCollection) isPublic() bool {
+	return c.Target != nil && (c.Target.IsFileSystemTarget() || c.resolver != nil)
+}
+
+// collectionsToTransfer returns all configured collections (the persistent one first, the others sorted by name).
+// targetUriPatterns contains additional public URI patterns by target class (see resolverForTarget).
+func (o *flowResourceOptions) collectionsToTransfer(targetUriPatterns map[string]string) []flowCollection {
+	names := make([]string, 0, len(o.Collections))
+	for name := range o.Collections {
+		if name != "persistent" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, found := o.Collections["persistent"]; found {
+		names = append([]string{"persistent"}, names...)
+	} else {
+		pterm.Warning.Printfln("did not find collection 'persistent' in config")
+	}
+
+	collections := make([]flowCollection, 0, len(names))
+	for _, name := range names {
+		collectionConfig := o.Collections[name]
+		collection := flowCollection{Name: name}
+		if storage, found := o.Storages[collectionConfig.Storage]; found {
+			collection.Storage = &storage
+		} else {
+			pterm.Warning.Printfln("did not find storage '%s' of collection '%s' in config.", collectionConfig.Storage, name)
+		}
+		if target, found := o.Targets[collectionConfig.Target]; found {
+			collection.Target = &target
+			collection.resolver = resolverForTarget(&target, targetUriPatterns)
+		} else {
+			pterm.Warning.Printfln("did not find target '%s' of collection '%s' in config.", collectionConfig.Target, name)
+		}
+		pterm.Info.Printfln("collection '%s' is using storage '%s' and target '%s'", name, collectionConfig.Storage, collectionConfig.Target)
+		collections = append(collections, collection)
+	}
+	return collections
+}
+
+// extractResourcesOfCollection builds the file set for a single collection: public collections are downloaded
+// via their target, all others are read from their storage into an encrypted archive.
+func extractResourcesOfCollection(transferSession *serve.TransferSession, tx *sql.Tx, collection flowCollection, whereClauseForTables map[string]string) {
+	fileSetName := collection.fileSetName()
+	defer transferSession.RecordPhase("resources "+fileSetName, time.Now())
+	if collection.Storage != nil && collection.Storage.IsPackageStorage() {
+		pterm.Info.Printfln("Skipping collection '%s': its resources are contained in the packages (and thus part of the code).", collection.Name)
+		return
+	}
+
+	if !collection.isPublic() {
+		if collection.Storage == nil {
+			transferSession.Warn("Skipping collection '%s': neither a public target nor a storage is configured.", collection.Name)
+			return
+		}
+		pterm.Info.Printfln("Encrypting and extracting private resources of collection '%s' (storage=%s)", collection.Name, collection.Storage.Storage)
+		err := extractPrivateResourcesFromStorage(transferSession, tx, fileSetName, collection, whereClauseForTables)
+		if err != nil {
+			pterm.Fatal.Printfln("could not extract private resources of collection '%s': %s", collection.Name, err)
+		}
+		return
+	}
+
+	target := collection.Target
+	if collection.resolver != nil {
+		pterm.Info.Printfln("Extracting resources of collection '%s' for %s (baseUri=%s)", collection.Name, target.Target, target.TargetOptions.BaseUri)
+		extractResourcesFromObjectStorage(transferSession, tx, fileSetName, collection.Name, target, collection.resolver, whereClauseForTables)
+	} else if transferSession.DumpAll {
+		pterm.Info.Printfln("Extracting ALL resources of collection '%s' for FileSystemTarget (path=%s, baseUri=%s)", collection.Name, target.TargetOptions.Path, target.TargetOptions.BaseUri)
+		extractAllResourcesFromFolder(transferSession, fileSetName, target.TargetOptions.Path, strings.TrimPrefix(target.TargetOptions.BaseUri, "_Resources/"))
+	} else {
+		pterm.Info.Printfln("Extracting resources of collection '%s' (but skipping thumbnails) for FileSystemTarget (path=%s, baseUri=%s)", collection.Name, target.TargetOptions.Path, target.TargetOptions.BaseUri)
+		extractResourcesFromFolderSkippingThumbnails(transferSession, tx, fileSetName, collection.Name, target, whereClauseForTables)
+	}
+}
+
+// queryResourcesOfCollection calls fn for every persistent resource of the given collection, respecting the
+// where clause configured for neos_flow_resourcemanagement_persistentresource. tx is the (still open) transaction
+// of the database dump.
+func queryResourcesOfCollection(tx *sql.Tx, collectionName string, whereClauseForTables map[string]string, fn func(resourceSha1 string, filename string, filesize uint64) error) error {
+	extraWhereClause := "true"
+	if len(whereClauseForTables["neos_flow_resourcemanagement_persistentresource"]) > 0 {
+		extraWhereClause = whereClauseForTables["neos_flow_resourcemanagement_persistentresource"]
+	}
+	q := fmt.Sprintf(`
+		SELECT
+			sha1, filename, filesize
+		FROM
+			neos_flow_resourcemanagement_persistentresource
+		WHERE collectionname = ? AND %s`, extraWhereClause)
+
+	rows, err := tx.Query(q, collectionName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var resourceSha1, filename string
+	var filesize uint64
+	for rows.Next() {
+		err := rows.Scan(&resourceSha1, &filename, &filesize)
+		if err != nil {
+			return fmt.Errorf("error loading DB row: %w", err)
+		}
+		if err := fn(resourceSha1, filename, filesize); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// extractPrivateResourcesFromStorage packs all resources of the collection into an encrypted archive, which is
+// extracted to dump/Resources/ on the receiving side (in the same structure as public resources).
+func extractPrivateResourcesFromStorage(transferSession *serve.TransferSession, tx *sql.Tx, fileSetName string, collection flowCollection, whereClauseForTables map[string]string) error {
+	var openResource func(resourceSha1 string) (io.ReadCloser, int64, error)
+	switch {
+	case collection.Storage.IsFileSystemStorage():
+		openResource = func(resourceSha1 string) (io.ReadCloser, int64, error) {
+			f, err := os.Open(filepath.Join(collection.Storage.StorageOptions.Path, resourcePath(resourceSha1)))
+			if err != nil {
+				return nil, 0, err
+			}
+			stat, err := f.Stat()
+			if err != nil {
+				_ = f.Close()
+				return nil, 0, err
+			}
+			return f, stat.Size(), nil
+		}
+	case collection.Storage.IsS3Storage():
+		client, err := s3ClientForStorage(transferSession, collection.Storage)
+		if err != nil {
+			return err
+		}
+		openResource = func(resourceSha1 string) (io.ReadCloser, int64, error) {
+			return client.GetObject(collection.Storage.StorageOptions.KeyPrefix + resourceSha1)
+		}
+	default:
+		transferSession.Warn("Skipping collection '%s': storage type '%s' is not supported right now.", collection.Name, collection.Storage.Storage)
+		return nil
+	}
+
+	tarWriter, err := commonServe.NewEncryptedTarWriter(transferSession, fileSetName, FlowResources)
+	if err != nil {
+		return err
+	}
+	// the same resource can be referenced multiple times (with different file names)
+	addedResources := make(map[string]bool)
+	err = queryResourcesOfCollection(tx, collection.Name, whereClauseForTables, func(resourceSha1 string, filename string, filesize uint64) error {
+		if addedResources[resourceSha1] {
+			return nil
+		}
+		addedResources[resourceSha1] = true
+
+		r, size, err := openResource(resourceSha1)
+		if err != nil {
+			pterm.Error.Printfln("Could NOT read resource %s (%s) - skipping: %s", resourceSha1, filename, err)
+			return nil
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+		return tarWriter.AddFile(resourcePath(resourceSha1), size, time.Now(), r)
+	})
+	if err != nil {
+		return err
+	}
+	return tarWriter.Finish()
+}
+
+// resourcePath replicates the folder structure of Flow's WritableFileSystemStorage, f.e. 3/2/3/3/3233621371f4...
+func resourcePath(resourceSha1 string) string {
+	return resourceSha1[0:1] + "/" + resourceSha1[1:2] + "/" + resourceSha1[2:3] + "/" + resourceSha1[3:4] + "/" + resourceSha1
+}
+
+// flownativeAwsS3Profile is the S3 client configuration of Flownative.Aws.S3 (Flownative.Aws.S3.profiles.default).
+type flownativeAwsS3Profile struct {
+	Credentials struct {
+		Key    string `yaml:"key"`
+		Secret string `yaml:"secret"`
+	} `yaml:"credentials"`
+	Region               string `yaml:"region"`
+	Endpoint             string `yaml:"endpoint"`
+	UsePathStyleEndpoint bool   `yaml:"use_path_style_endpoint"`
+}
+
+func s3ClientForStorage(transferSession *serve.TransferSession, storage *flowResourceStorage) (*s3.Client, error) {
+	output := readFlowSettings(transferSession, "Flownative.Aws.S3.profiles.default")
+	var profile flownativeAwsS3Profile
+	err := yaml.Unmarshal([]byte(output), &profile)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse Flownative.Aws.S3 profile: %w", err)
+	}
+	return &s3.Client{
+		Endpoint:     profile.Endpoint,
+		Region:       profile.Region,
+		Bucket:       storage.StorageOptions.Bucket,
+		AccessKey:    profile.Credentials.Key,
+		SecretKey:    profile.Credentials.Secret,
+		UsePathStyle: profile.UsePathStyleEndpoint,
+	}, nil
+}
@@ -0,0 +1,79 @@
+package flowServe
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// shortened output of `./flow configuration:show --type Settings --path Neos.Flow.resource`
+const flowResourceConfigYaml = `
+storages:
+  defaultPersistentResourcesStorage:
+    storage: Neos\Flow\ResourceManagement\Storage\WritableFileSystemStorage
+    storageOptions:
+      path: /app/Data/Persistent/Resources/
+  defaultStaticResourcesStorage:
+    storage: Neos\Flow\ResourceManagement\Storage\PackageStorage
+  protectedStorage:
+    storage: Flownative\Aws\S3\S3Storage
+    storageOptions:
+      bucket: project-protected
+      keyPrefix: protected/
+collections:
+  static:
+    storage: defaultStaticResourcesStorage
+    target: localWebDirectoryStaticResourcesTarget
+  protected:
+    storage: protectedStorage
+    target: protectedResourceTarget
+  persistent:
+    storage: defaultPersistentResourcesStorage
+    target: localWebDirectoryPersistentResourcesTarget
+targets:
+  localWebDirectoryStaticResourcesTarget:
+    target: Neos\Flow\ResourceManagement\Target\FileSystemSymlinkTarget
+    targetOptions:
+      path: /app/Web/_Resources/Static/Packages/
+      baseUri: _Resources/Static/Packages/
+  localWebDirectoryPersistentResourcesTarget:
+    target: Neos\Flow\ResourceManagement\Target\FileSystemSymlinkTarget
+    targetOptions:
+      path: /app/Web/_Resources/Persistent/
+      baseUri: _Resources/Persistent/
+  protectedResourceTarget:
+    target: Wwwision\PrivateResources\ResourceManagement\ProtectedResourceTarget
+`
+
+func TestCollectionsToTransfer(t *testing.T) {
+	var opts flowResourceOptions
+	if err := yaml.Unmarshal([]byte(flowResourceConfigYaml), &opts); err != nil {
+		t.Fatalf("unmarshalling config: %v", err)
+	}
+
+	collections := opts.collectionsToTransfer(nil)
+	if len(collections) != 3 {
+		t.Fatalf("expected 3 collections, got %d", len(collections))
+	}
+
+	persistent, protected, static := collections[0], collections[1], collections[2]
+	if persistent.Name != "persistent" || persistent.fileSetName() != "Resources" || !persistent.isPublic() {
+		t.Errorf("unexpected persistent collection: %+v", persistent)
+	}
+	if protected.Name != "protected" || protected.fileSetName() != "Resources-protected" || protected.isPublic() {
+		t.Errorf("unexpected protected collection: %+v", protected)
+	}
+	if !protected.Storage.IsS3Storage() || protected.Storage.StorageOptions.KeyPrefix != "protected/" {
+		t.Errorf("unexpected storage of protected collection: %+v", protected.Storage)
+	}
+	if static.Name != "static" || !static.Storage.IsPackageStorage() {
+		t.Errorf("unexpected static collection: %+v", static)
+	}
+}
+
+func TestResourcePath(t *testing.T) {
+	got := resourcePath("3233621371f429bfc0e36b47c12b116b688055bf")
+	if got != "3/2/3/3/3233621371f429bfc0e36b47c12b116b688055bf" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -11,10 +11,12 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pterm/pterm"
 	"github.com/sandstorm/synco/v2/pkg/common"
 	"github.com/sandstorm/synco/v2/pkg/common/commonServe"
+	"github.com/sandstorm/synco/v2/pkg/common/config"
 	"github.com/sandstorm/synco/v2/pkg/common/dto"
 	"github.com/sandstorm/synco/v2/pkg/serve"
 	"github.com/sandstorm/synco/v2/pkg/util"
@@ -23,61 +25,52 @@ import (
 
 const FlowResources = "Resources"
 
+// neos9ProjectionTables matches the projection tables of all content repositories in Neos 9, f.e. cr_default_p_graph_node
+const neos9ProjectionTables = "cr_*_p_*"
+
 type flowServe struct {
 }
 
+func (f flowServe) Id() string {
+	return "flow"
+}
+
 func (f flowServe) Name() string {
 	return "Neos/Flow"
 }
 
-func (f flowServe) Detect() bool {
+func (f flowServe) Detect() common.DetectionResult {
+	result := common.DetectionResult{}
 	if _, err := os.Stat("flow"); err != nil {
 		pterm.Debug.Println("./flow binary not found, thus no installed Flow Framework")
-		return false
+		return common.DetectionResult{}
 	}
+	result.AddReason(40, "./flow found")
 
 	if _, err := os.Stat("Web"); err != nil {
 		pterm.Debug.Println("./Web folder not found, thus no installed Flow Framework")
-		return false
+		return common.DetectionResult{}
 	}
+	result.AddReason(20, "./Web found")
 
-	return true
+	if commonServe.ComposerRequires("neos/flow") || commonServe.ComposerRequires("neos/neos") {
+		result.AddReason(30, "composer.json requires neos/flow or neos/neos")
+	}
+	if _, err := os.Stat("Configuration"); err == nil {
+		result.AddReason(10, "./Configuration found")
+	}
+	return result
 }
 
 type flowResourceOptions struct {
 	Collections map[string]flowResourceCollection `yaml:"collections"`
+	Storages    map[string]flowResourceStorage    `yaml:"storages"`
 	Targets     map[string]flowResourceTarget     `yaml:"targets"`
 }
 
-func (o *flowResourceOptions) FindPersistentTarget() *flowResourceTarget {
-	var persistentTargetName string
-	for key, collection := range o.Collections {
-		if key == "persistent" {
-			pterm.Info.Printfln("collection 'persistent' is using target '%s'", collection.Target)
-			persistentTargetName = collection.Target
-		}
-	}
-
-	if persistentTargetName == "" {
-		pterm.Warning.Printfln("did not find collection 'persistent' in config")
-		return nil
-	}
-
-	for key, target := range o.Targets {
-		if key == persistentTargetName {
-			pterm.Info.Printfln("target '%s' is configured as follows:", persistentTargetName)
-			pterm.Info.Printfln("  '%s'", target)
-			return &target
-		}
-	}
-
-	pterm.Warning.Printfln("did not find persistent target '%s' in config.", persistentTargetName)
-	return nil
-}
-
 type flowResourceCollection struct {
-	// storage does not matter, so we only add target for now
-	Target string `yaml:"target"`
+	Storage string `yaml:"storage"`
+	Target  string `yaml:"target"`
 }
 type flowResourceTarget struct {
 	Target        string `yaml:"target"`
@@ -100,10 +93,6 @@ type flowResourceTarget struct {
 	} `yaml:"targetOptions"`
 }
 
-func (t flowResourceTarget) IsS3Target() bool {
-	return t.Target == "Flownative\\Aws\\S3\\S3Target"
-}
-
 func (t flowResourceTarget) IsFileSystemTarget() bool {
 	return t.Target == "Neos\\Flow\\ResourceManagement\\Target\\FileSystemSymlinkTarget" ||
 		t.Target == "Neos\\Flow\\ResourceManagement\\Target\\FileSystemTarget"
@@ -118,6 +107,8 @@ type flowPersistenceBackendOptions struct {
 	Password string `yaml:"password"`
 	Charset  string `yaml:"charset"`
 	Port     string `yaml:"port"`
+	// UnixSocket is the path to the socket of the database, if it is not reachable via TCP
+	UnixSocket string `yaml:"unix_socket"`
 }
 
 func (fp *flowPersistenceBackendOptions) ToDbCredentials() *common.DbCredentials {
@@ -128,6 +119,7 @@ func (fp *flowPersistenceBackendOptions) ToDbCredentials() *common.DbCredentials
 	return &common.DbCredentials{
 		Host:     fp.Host,
 		Port:     port,
+		Socket:   fp.UnixSocket,
 		User:     fp.User,
 		Password: fp.Password,
 		DbName:   fp.DbName,
@@ -140,13 +132,14 @@ func (f flowServe) Serve(transferSession *serve.TransferSession) {
 		pterm.Fatal.Printfln("Error writing transferSession: %s", err)
 	}
 
-	flowPersistence := extractDatabaseCredentialsFromFlow()
-	whereClauseForTables := map[string]string{
+	flowPersistence := extractDatabaseCredentialsFromFlow(transferSession)
+	tableModes := map[string]string{
 		// event log can be HUGE and is usually not needed.
-		"neos_neos_eventlog_domain_model_event": "FALSE",
+		"neos_neos_eventlog_domain_model_event": "schema-only",
 		// thumbnails can be regenerated
-		"neos_media_domain_model_thumbnail": "FALSE",
-
+		"neos_media_domain_model_thumbnail": "schema-only",
+	}
+	whereClauseForTables := map[string]string{
 		// skip persistent resources which are purely for thumbnails
 		"neos_flow_resourcemanagement_persistentresource": `
 			NOT EXISTS (
@@ -157,31 +150,43 @@ func (f flowServe) Serve(transferSession *serve.TransferSession) {
 					AND th.resource = neos_flow_resourcemanagement_persistentresource.persistence_object_identifier
 				)`,
 	}
+	neosMajorVersion := detectNeosMajorVersion("composer.lock")
+	if neosMajorVersion > 0 {
+		pterm.Info.Printfln("Detected Neos %d", neosMajorVersion)
+		transferSession.AddConfigSource("composer.lock")
+	}
+	if neosMajorVersion >= 9 {
+		// Neos 9 (Event-Sourced Content Repository): all projections can be rebuilt from the events (cr_*_events),
+		// so we only need their table structure.
+		tableModes[neos9ProjectionTables] = "schema-only"
+	}
 	if transferSession.DumpAll {
 		whereClauseForTables = map[string]string{}
+		tableModes = map[string]string{}
 	}
-	db := commonServe.DatabaseDump(transferSession, flowPersistence.ToDbCredentials(), whereClauseForTables)
-	flowResourceConfig := extractResourceConfigFromFlow()
-	persistentTarget := flowResourceConfig.FindPersistentTarget()
-
-	if persistentTarget == nil {
-		pterm.Warning.Printfln("falling back to extracting locations from default location.")
+	if !transferSession.DumpAll && neosMajorVersion >= 9 {
+		transferSession.Meta.PostReceiveHints = append(transferSession.Meta.PostReceiveHints,
+			"The content repository projections (cr_*_p_* tables) were not transferred. After importing the database, "+
+				"rebuild them with: ./flow subscription:replayall (on Neos 9.0 betas: ./flow cr:projectionReplayAll)",
+		)
+	}
+	// the resource index is built inside the transaction of the dump, so that both describe the same point in time
+	// (resources uploaded in the meantime are neither in the dump nor in the index).
+	tx := commonServe.DatabaseDump(transferSession, "dbDump", flowPersistence.ToDbCredentials(), whereClauseForTables, tableModes, nil)
+	flowResourceConfig := extractResourceConfigFromFlow(transferSession)
+	collections := flowResourceConfig.collectionsToTransfer(readTargetUriPatterns())
+
+	if len(collections) == 0 {
+		transferSession.Warn("falling back to extracting locations from default location.")
 		// fallback to extracting resources from default location
-		extractAllResourcesFromFolder(transferSession, "./Web/_Resources/Persistent", "Persistent")
-	} else if persistentTarget.IsS3Target() {
-		pterm.Info.Printfln("Extracting resources for S3Target (baseUri=%s)", persistentTarget.TargetOptions.BaseUri)
-		extractResourcesFromS3(transferSession, db, persistentTarget, whereClauseForTables)
-	} else if persistentTarget.IsFileSystemTarget() {
-		if transferSession.DumpAll {
-			pterm.Info.Printfln("Extracting ALL resources for FileSystemTarget (path=%s, baseUri=%s)", persistentTarget.TargetOptions.Path, persistentTarget.TargetOptions.BaseUri)
-			extractAllResourcesFromFolder(transferSession, persistentTarget.TargetOptions.Path, strings.TrimPrefix(persistentTarget.TargetOptions.BaseUri, "_Resources/"))
-		} else {
-			pterm.Info.Printfln("Extracting resources (but skipping thumbnails) for FileSystemTarget (path=%s, baseUri=%s)", persistentTarget.TargetOptions.Path, persistentTarget.TargetOptions.BaseUri)
-			extractResourcesFromFolderSkippingThumbnails(transferSession, db, persistentTarget, whereClauseForTables)
-		}
-	} else {
-		pterm.Fatal.Printfln("unknown persistent target type '%s'", persistentTarget.Target)
+		start := time.Now()
+		extractAllResourcesFromFolder(transferSession, FlowResources, "./Web/_Resources/Persistent", "Persistent")
+		transferSession.RecordPhase("resources "+FlowResources, start)
 	}
+	for _, collection := range collections {
+		extractResourcesOfCollection(transferSession, tx, collection, whereClauseForTables)
+	}
+	_ = tx.Rollback()
 
 	transferSession.Meta.State = dto.STATE_READY
 	err = transferSession.UpdateMetadata()
@@ -196,6 +201,9 @@ func (f flowServe) Serve(transferSession *serve.TransferSession) {
 		pterm.Success.Printfln("- neos_neos_eventlog_domain_model_event (usually huge and not needed)")
 		pterm.Success.Printfln("- neos_media_domain_model_thumbnail (can be regenerated on the client)")
 		pterm.Success.Printfln("- partially neos_flow_resourcemanagement_persistentresource (thumbnails not included)")
+		if neosMajorVersion >= 9 {
+			pterm.Success.Printfln("- the content of the content repository projections %s (can be replayed from the events on the client)", neos9ProjectionTables)
+		}
 		pterm.Success.Printfln("")
 		pterm.Success.Printfln("In case you want to dump all tables, run with --all.")
 	}
@@ -207,21 +215,37 @@ func (f flowServe) Serve(transferSession *serve.TransferSession) {
 	pterm.Success.Printfln("")
 }
 
-func extractDatabaseCredentialsFromFlow() flowPersistenceBackendOptions {
+// readTargetUriPatterns returns the public URI patterns for custom resource targets from .synco-serve.yml
+func readTargetUriPatterns() map[string]string {
+	serveConfig, err := config.ReadServeConfigFromYaml()
+	if err != nil {
+		pterm.Fatal.Printfln("could not read %s: %s", config.SyncoServeYamlFile, err)
+	}
+	if serveConfig == nil || serveConfig.Flow == nil {
+		return nil
+	}
+	return serveConfig.Flow.TargetUriPatterns
+}
+
+func extractDatabaseCredentialsFromFlow(transferSession *serve.TransferSession) flowPersistenceBackendOptions {
 	pterm.Debug.Println("Finding database credentials")
-	output := readFlowSettings("Neos.Flow.persistence.backendOptions")
+	output := readFlowSettings(transferSession, "Neos.Flow.persistence.backendOptions")
 	var flowPersistence flowPersistenceBackendOptions
 	err := yaml.Unmarshal([]byte(output), &flowPersistence)
 	if err != nil {
 		pterm.Fatal.Printfln("could not parse output of ./flow configuration:show: %s. Output was: %s", err, output)
 	}
-	pterm.Info.Printfln("Extracted Database Host %s, User: %s", flowPersistence.Host, flowPersistence.User)
+	if len(flowPersistence.UnixSocket) > 0 {
+		pterm.Info.Printfln("Extracted Database Socket %s, User: %s", flowPersistence.UnixSocket, flowPersistence.User)
+	} else {
+		pterm.Info.Printfln("Extracted Database Host %s, User: %s", flowPersistence.Host, flowPersistence.User)
+	}
 	return flowPersistence
 }
 
-func extractResourceConfigFromFlow() flowResourceOptions {
+func extractResourceConfigFromFlow(transferSession *serve.TransferSession) flowResourceOptions {
 	pterm.Debug.Println("Finding resource configuration")
-	output := readFlowSettings("Neos.Flow.resource")
+	output := readFlowSettings(transferSession, "Neos.Flow.resource")
 	var opts flowResourceOptions
 	err := yaml.Unmarshal([]byte(output), &opts)
 	if err != nil {
@@ -230,7 +254,7 @@ func extractResourceConfigFromFlow() flowResourceOptions {
 	return opts
 }
 
-func readFlowSettings(path string) string {
+func readFlowSettings(transferSession *serve.TransferSession, path string) string {
 	cmd := commonServe.ExecWithVariousPhpInterpreters(fmt.Sprintf("flow configuration:show --type Settings --path %s", path))
 	php := os.Getenv("PHP")
 	if php != "" {
@@ -239,111 +263,55 @@ func readFlowSettings(path string) string {
 	}
 
 	output, _, err := util.RunWrappedCommand(cmd)
-	if err != nil {
-		pterm.Fatal.Printfln("./flow configuration:show did not succeed: %s", err)
-	}
 	// remove the first line; as it contains the " Configuration "Settings: Neos.Flow.persistence.backendOptions":" line:
 	outputParts := strings.SplitN(output, "\n", 2)
-	output = outputParts[1]
-	return output
-}
+	if err == nil && len(outputParts) == 2 {
+		transferSession.AddConfigSource("./flow configuration:show")
+		return outputParts[1]
+	}
 
-func extractResourcesFromS3(transferSession *serve.TransferSession, db *sql.DB, persistentTarget *flowResourceTarget, whereClauseForTables map[string]string) {
-	extraWhereClause := "true"
-	if len(whereClauseForTables["neos_flow_resourcemanagement_persistentresource"]) > 0 {
-		extraWhereClause = whereClauseForTables["neos_flow_resourcemanagement_persistentresource"]
+	// no (working) PHP CLI -> we read the YAML files ourselves.
+	transferSession.Warn("./flow configuration:show did not succeed (%s) - reading Configuration/**/Settings*.yaml without PHP instead.", err)
+	transferSession.AddConfigSource(fmt.Sprintf("Configuration/**/Settings*.yaml (FLOW_CONTEXT=%s)", os.Getenv("FLOW_CONTEXT")))
+	loader, err := newFlowSettingsLoader(".", os.Getenv("FLOW_CONTEXT"))
+	if err != nil {
+		pterm.Fatal.Printfln("could not read Flow settings: %s", err)
 	}
-	resourceFilesIndex := make(dto.PublicFilesIndex)
-	totalSizeBytes := uint64(0)
-	q := fmt.Sprintf(`
-		SELECT
-			sha1, filename, filesize
-		FROM
-			neos_flow_resourcemanagement_persistentresource
-		WHERE collectionname = 'persistent' AND %s`, extraWhereClause)
-
-	rows, err := db.Query(q)
+	output, err = loader.ReadSettings(path)
 	if err != nil {
-		pterm.Fatal.Printfln("could query for resources: %s", err)
+		pterm.Fatal.Printfln("could not read Flow settings without PHP: %s", err)
 	}
-	defer rows.Close()
-
-	var resourceSha1, filename string
-	var filesize uint64
-	for rows.Next() {
-		err := rows.Scan(&resourceSha1, &filename, &filesize)
-		if err != nil {
-			pterm.Fatal.Printfln("error loading DB row: %s", err)
-		}
+	return output
+}
 
+// extractResourcesFromObjectStorage builds the public files index for targets publishing to an object storage or CDN
+// (S3, Google Cloud Storage, ...); the public URI of every resource is generated by the resolver of the target.
+func extractResourcesFromObjectStorage(transferSession *serve.TransferSession, tx *sql.Tx, fileSetName string, collectionName string, persistentTarget *flowResourceTarget, resolver targetResolver, whereClauseForTables map[string]string) {
+	resourceFilesIndex := make(dto.PublicFilesIndex)
+	totalSizeBytes := uint64(0)
+	err := queryResourcesOfCollection(tx, collectionName, whereClauseForTables, func(resourceSha1 string, filename string, filesize uint64) error {
 		totalSizeBytes += filesize
 		resourceFilesIndex["Resources/"+resourceSha1[0:1]+"/"+resourceSha1[1:2]+"/"+resourceSha1[2:3]+"/"+resourceSha1[3:4]+"/"+resourceSha1] = dto.PublicFilesIndexEntry{
 			SizeBytes:     int64(filesize),
 			MTime:         0,
-			PublicUri:     generateS3ResourcePublicPath(persistentTarget, resourceSha1, filename),
+			PublicUri:     resolver(persistentTarget, resourceSha1, filename),
 			IsAbsoluteUrl: true,
 		}
-	}
-	err = rows.Err()
+		return nil
+	})
 	if err != nil {
-		pterm.Fatal.Printfln("error iterating rows: %s", err)
-	}
-
-	commonServe.WriteResourcesIndex(transferSession, dto.TYPE_PUBLICFILES, FlowResources, resourceFilesIndex, totalSizeBytes)
-}
-
-// generateS3ResourcePublicPath replicates S3Target::getPublicPersistentResourceUri
-// https://github.com/flownative/flow-aws-s3/blob/main/Classes/S3Target.php#L465
-func generateS3ResourcePublicPath(persistentTarget *flowResourceTarget, resourceSha1 string, filename string) string {
-	if pattern := persistentTarget.TargetOptions.PersistentResourceUris.Pattern; pattern != "" {
-		return strings.NewReplacer(
-			"{baseUri}", persistentTarget.TargetOptions.BaseUri,
-			"{bucketName}", persistentTarget.TargetOptions.Bucket,
-			"{keyPrefix}", persistentTarget.TargetOptions.KeyPrefix,
-			"{sha1}", resourceSha1,
-			"{filename}", filename,
-			"{fileExtension}", strings.TrimPrefix(filepath.Ext(filename), "."),
-		).Replace(pattern)
+		pterm.Fatal.Printfln("could not query for resources: %s", err)
 	}
 
-	// HACK: this is how it works for Neos / Flow. Probably not all escapes done
-	escapedFileName := url.PathEscape(filename)
-	escapedFileName = strings.ReplaceAll(escapedFileName, "+", "%2B")
-	return persistentTarget.TargetOptions.BaseUri + resourceSha1 + "/" + escapedFileName
+	commonServe.WriteResourcesIndex(transferSession, dto.TYPE_PUBLICFILES, fileSetName, resourceFilesIndex, totalSizeBytes)
 }
 
-func extractResourcesFromFolderSkippingThumbnails(transferSession *serve.TransferSession, db *sql.DB, persistentTarget *flowResourceTarget, whereClauseForTables map[string]string) {
-	extraWhereClause := "true"
-	if len(whereClauseForTables["neos_flow_resourcemanagement_persistentresource"]) > 0 {
-		extraWhereClause = whereClauseForTables["neos_flow_resourcemanagement_persistentresource"]
-	}
+func extractResourcesFromFolderSkippingThumbnails(transferSession *serve.TransferSession, tx *sql.Tx, fileSetName string, collectionName string, persistentTarget *flowResourceTarget, whereClauseForTables map[string]string) {
 	resourceFilesIndex := make(dto.PublicFilesIndex)
 	totalSizeBytes := uint64(0)
-	q := fmt.Sprintf(`
-		SELECT
-			sha1, filename, filesize
-		FROM
-			neos_flow_resourcemanagement_persistentresource
-		WHERE collectionname = 'persistent' AND %s`, extraWhereClause)
-
-	rows, err := db.Query(q)
-	if err != nil {
-		pterm.Fatal.Printfln("could query for resources: %s", err)
-	}
-	defer rows.Close()
-
-	var resourceSha1, filename string
-	var filesize uint64
-	for rows.Next() {
-		err := rows.Scan(&resourceSha1, &filename, &filesize)
-		if err != nil {
-			pterm.Fatal.Printfln("error loading DB row: %s", err)
-		}
-
+	err := queryResourcesOfCollection(tx, collectionName, whereClauseForTables, func(resourceSha1 string, filename string, filesize uint64) error {
 		totalSizeBytes += filesize
-		escapedFileName := url.PathEscape(filename)
-		// HACK: this is how it works for Neos / Flow. Probably not all escapes done
-		escapedFileName = strings.ReplaceAll(escapedFileName, "+", "%2B")
+		escapedFileName := escapeResourceFilename(filename)
 		adjustedBaseUri := strings.TrimPrefix(persistentTarget.TargetOptions.BaseUri, "_Resources/")
 		resourceFilesIndex["Resources/"+resourceSha1[0:1]+"/"+resourceSha1[1:2]+"/"+resourceSha1[2:3]+"/"+resourceSha1[3:4]+"/"+resourceSha1] = dto.PublicFilesIndexEntry{
 			SizeBytes:     int64(filesize),
@@ -351,20 +319,20 @@ func extractResourcesFromFolderSkippingThumbnails(transferSession *serve.Transfe
 			PublicUri:     adjustedBaseUri + resourceSha1[0:1] + "/" + resourceSha1[1:2] + "/" + resourceSha1[2:3] + "/" + resourceSha1[3:4] + "/" + resourceSha1 + "/" + escapedFileName,
 			IsAbsoluteUrl: false,
 		}
-	}
-	err = rows.Err()
+		return nil
+	})
 	if err != nil {
-		pterm.Fatal.Printfln("error iterating rows: %s", err)
+		pterm.Fatal.Printfln("could not query for resources: %s", err)
 	}
 
-	commonServe.WriteResourcesIndex(transferSession, dto.TYPE_PUBLICFILES, FlowResources, resourceFilesIndex, totalSizeBytes)
+	commonServe.WriteResourcesIndex(transferSession, dto.TYPE_PUBLICFILES, fileSetName, resourceFilesIndex, totalSizeBytes)
 }
 
 func NewFlowFramework() common.ServeFramework {
 	return &flowServe{}
 }
 
-func extractAllResourcesFromFolder(transferSession *serve.TransferSession, persistentResourcesBasePath string, baseUri string) {
+func extractAllResourcesFromFolder(transferSession *serve.TransferSession, fileSetName string, persistentResourcesBasePath string, baseUri string) {
 	resourceFilesIndex := make(dto.PublicFilesIndex)
 	totalSizeBytes := uint64(0)
 	err := filepath.Walk(persistentResourcesBasePath,
@@ -379,12 +347,12 @@ func extractAllResourcesFromFolder(transferSession *serve.TransferSession, persi
 
 			realPath, err := filepath.EvalSymlinks(filePath)
 			if err != nil {
-				pterm.Error.Printfln("Could NOT evaluate symlinks (skipping): %s: %s", filePath, err)
+				transferSession.Warn("Could NOT evaluate symlinks (skipping): %s: %s", filePath, err)
 				return nil
 			}
 			realFileInfo, err := os.Lstat(realPath)
 			if err != nil {
-				pterm.Error.Printfln("Could NOT read file info (skipping): %s: %s", realPath, err)
+				transferSession.Warn("Could NOT read file info (skipping): %s: %s", realPath, err)
 				return nil
 			}
 
@@ -411,5 +379,5 @@ func extractAllResourcesFromFolder(transferSession *serve.TransferSession, persi
 		log.Println(err)
 	}
 
-	commonServe.WriteResourcesIndex(transferSession, dto.TYPE_PUBLICFILES, FlowResources, resourceFilesIndex, totalSizeBytes)
+	commonServe.WriteResourcesIndex(transferSession, dto.TYPE_PUBLICFILES, fileSetName, resourceFilesIndex, totalSizeBytes)
 }
@@ -1,6 +1,10 @@
 package flowServe
 
-import "testing"
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
 
 type generateS3ResourcesPathTest struct {
 	filename            string
@@ -62,3 +66,67 @@ func TestGenerateS3ResourcesPaths(t *testing.T) {
 		}
 	}
 }
+
+func TestResolverForTarget(t *testing.T) {
+	targetsYaml := `
+gcs:
+  target: Flownative\GoogleCloudStorage\GcsTarget
+  targetOptions:
+    bucket: project-prod-assets
+    keyPrefix: site/
+gcsWithBaseUri:
+  target: Flownative\GoogleCloudStorage\GcsTarget
+  targetOptions:
+    baseUri: https://assets.vendor.com/
+    keyPrefix: site/
+customCdn:
+  target: Vendor\Cdn\CdnTarget
+  targetOptions:
+    baseUri: https://cdn.vendor.com/
+customWithPattern:
+  target: Vendor\Cdn\OtherTarget
+  targetOptions:
+    persistentResourceUris:
+      pattern: https://other.vendor.com/{sha1}.{fileExtension}
+unknown:
+  target: Vendor\Private\ProtectedTarget
+fileSystem:
+  target: Neos\Flow\ResourceManagement\Target\FileSystemSymlinkTarget
+`
+	var targets map[string]flowResourceTarget
+	if err := yaml.Unmarshal([]byte(targetsYaml), &targets); err != nil {
+		t.Fatalf("unmarshalling targets: %v", err)
+	}
+	targetUriPatterns := map[string]string{
+		"Vendor\\Cdn\\CdnTarget": "{baseUri}r/{sha1}/{filename}",
+	}
+
+	tests := []struct {
+		target          string
+		wantedPublicUri string
+	}{
+		{"gcs", "https://storage.googleapis.com/project-prod-assets/site/2b5a802db2bc2f3e5eb7f7d9720201abc5cc511a/my%20image.jpg"},
+		{"gcsWithBaseUri", "https://assets.vendor.com/site/2b5a802db2bc2f3e5eb7f7d9720201abc5cc511a/my%20image.jpg"},
+		{"customCdn", "https://cdn.vendor.com/r/2b5a802db2bc2f3e5eb7f7d9720201abc5cc511a/my%20image.jpg"},
+		{"customWithPattern", "https://other.vendor.com/2b5a802db2bc2f3e5eb7f7d9720201abc5cc511a.jpg"},
+	}
+	for _, tt := range tests {
+		target := targets[tt.target]
+		resolver := resolverForTarget(&target, targetUriPatterns)
+		if resolver == nil {
+			t.Errorf("%s: expected a resolver", tt.target)
+			continue
+		}
+		publicUri := resolver(&target, "2b5a802db2bc2f3e5eb7f7d9720201abc5cc511a", "my image.jpg")
+		if publicUri != tt.wantedPublicUri {
+			t.Errorf("%s: publicUri %q does not match %q", tt.target, publicUri, tt.wantedPublicUri)
+		}
+	}
+
+	for _, name := range []string{"unknown", "fileSystem"} {
+		target := targets[name]
+		if resolverForTarget(&target, targetUriPatterns) != nil {
+			t.Errorf("%s: expected no resolver", name)
+		}
+	}
+}
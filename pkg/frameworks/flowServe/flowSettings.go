@@ -0,0 +1,245 @@
+package flowServe
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// flowSettingsLoader reads the Settings of a Flow application without PHP, replicating Flow's MergeLoader:
+//
+//  1. Packages/*/Configuration/Settings*.yaml (in package loading order)
+//  2. Configuration/Settings*.yaml
+//  3. for every level of the FLOW_CONTEXT hierarchy (f.e. Production, then Production/Live):
+//     Packages/*/Configuration/<Context>/Settings*.yaml, then Configuration/<Context>/Settings*.yaml
+//
+// This is only a fallback for environments without a working PHP CLI: PHP class constants and settings
+// contributed by PHP code are not supported.
+type flowSettingsLoader struct {
+	// rootPath is FLOW_PATH_ROOT, with trailing slash
+	rootPath    string
+	flowContext string
+	getenv      func(key string) string
+}
+
+func newFlowSettingsLoader(rootPath string, flowContext string) (*flowSettingsLoader, error) {
+	rootPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	if flowContext == "" {
+		flowContext = "Development"
+	}
+	return &flowSettingsLoader{
+		rootPath:    filepath.ToSlash(rootPath) + "/",
+		flowContext: flowContext,
+		getenv:      os.Getenv,
+	}, nil
+}
+
+// ReadSettings returns the settings below the given dot-separated path (f.e. Neos.Flow.persistence.backendOptions)
+// as YAML, in the same format as `./flow configuration:show`.
+func (l *flowSettingsLoader) ReadSettings(settingsPath string) (string, error) {
+	settings, err := l.loadAll()
+	if err != nil {
+		return "", err
+	}
+
+	var current any = settings
+	for _, key := range strings.Split(settingsPath, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("setting %s not found", settingsPath)
+		}
+		current, ok = m[key]
+		if !ok {
+			return "", fmt.Errorf("setting %s not found", settingsPath)
+		}
+	}
+
+	out, err := yaml.Marshal(l.resolvePlaceholders(current))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func (l *flowSettingsLoader) loadAll() (map[string]any, error) {
+	packagePaths, err := l.packagePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	configurationDirectories := make([]string, 0)
+	for _, packagePath := range packagePaths {
+		configurationDirectories = append(configurationDirectories, filepath.Join(packagePath, "Configuration"))
+	}
+	configurationDirectories = append(configurationDirectories, filepath.Join(l.rootPath, "Configuration"))
+
+	for _, contextName := range contextHierarchy(l.flowContext) {
+		for _, packagePath := range packagePaths {
+			configurationDirectories = append(configurationDirectories, filepath.Join(packagePath, "Configuration", contextName))
+		}
+		configurationDirectories = append(configurationDirectories, filepath.Join(l.rootPath, "Configuration", contextName))
+	}
+
+	settings := make(map[string]any)
+	for _, configurationDirectory := range configurationDirectories {
+		// Settings.yaml first, then the split sources (Settings.*.yaml) - like Flow's YamlSource.
+		files := []string{filepath.Join(configurationDirectory, "Settings.yaml")}
+		splitFiles, _ := filepath.Glob(filepath.Join(configurationDirectory, "Settings.*.yaml"))
+		sort.Strings(splitFiles)
+		files = append(files, splitFiles...)
+
+		for _, file := range files {
+			contents, err := os.ReadFile(file)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			var fileSettings map[string]any
+			if err := yaml.Unmarshal(contents, &fileSettings); err != nil {
+				return nil, fmt.Errorf("could not parse %s: %w", file, err)
+			}
+			settings = mergeSettings(settings, fileSettings)
+		}
+	}
+	return settings, nil
+}
+
+// contextHierarchy returns f.e. [Production, Production/Live] for Production/Live
+func contextHierarchy(flowContext string) []string {
+	parts := strings.Split(flowContext, "/")
+	hierarchy := make([]string, 0, len(parts))
+	for i := range parts {
+		hierarchy = append(hierarchy, strings.Join(parts[:i+1], "/"))
+	}
+	return hierarchy
+}
+
+var packageStatesPathRegex = regexp.MustCompile(`'packagePath'\s*=>\s*'([^']+)'`)
+
+// packagePaths returns the (absolute) paths of all packages in loading order. The order is taken from
+// Configuration/PackageStates.php (which Flow writes in sorted order); without it, we order the packages found
+// in Packages/*/* by their composer dependencies.
+func (l *flowSettingsLoader) packagePaths() ([]string, error) {
+	packageStates, err := os.ReadFile(filepath.Join(l.rootPath, "Configuration", "PackageStates.php"))
+	if err == nil {
+		packagePaths := make([]string, 0)
+		for _, match := range packageStatesPathRegex.FindAllStringSubmatch(string(packageStates), -1) {
+			packagePaths = append(packagePaths, filepath.Join(l.rootPath, match[1]))
+		}
+		if len(packagePaths) > 0 {
+			return packagePaths, nil
+		}
+	}
+
+	composerFiles, err := filepath.Glob(filepath.Join(l.rootPath, "Packages", "*", "*", "composer.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(composerFiles)
+
+	type composerPackage struct {
+		path    string
+		Name    string            `json:"name"`
+		Require map[string]string `json:"require"`
+	}
+	packagesByName := make(map[string]*composerPackage)
+	packages := make([]*composerPackage, 0, len(composerFiles))
+	for _, composerFile := range composerFiles {
+		contents, err := os.ReadFile(composerFile)
+		if err != nil {
+			return nil, err
+		}
+		p := &composerPackage{path: filepath.Dir(composerFile)}
+		if err := json.Unmarshal(contents, p); err != nil {
+			// broken composer.json -> we still load its configuration
+			p.Name = composerFile
+		}
+		packagesByName[strings.ToLower(p.Name)] = p
+		packages = append(packages, p)
+	}
+
+	// depth-first topological sort: dependencies are loaded before the packages requiring them
+	sorted := make([]string, 0, len(packages))
+	visited := make(map[*composerPackage]bool)
+	var visit func(p *composerPackage)
+	visit = func(p *composerPackage) {
+		if visited[p] {
+			return
+		}
+		visited[p] = true
+		dependencies := make([]string, 0, len(p.Require))
+		for dependency := range p.Require {
+			dependencies = append(dependencies, strings.ToLower(dependency))
+		}
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			if dependencyPackage, found := packagesByName[dependency]; found {
+				visit(dependencyPackage)
+			}
+		}
+		sorted = append(sorted, p.path)
+	}
+	for _, p := range packages {
+		visit(p)
+	}
+	return sorted, nil
+}
+
+// mergeSettings replicates Arrays::arrayMergeRecursiveOverrule: nested maps are merged, everything else is overridden.
+func mergeSettings(base map[string]any, override map[string]any) map[string]any {
+	for key, overrideValue := range override {
+		baseMap, baseIsMap := base[key].(map[string]any)
+		overrideMap, overrideIsMap := overrideValue.(map[string]any)
+		if baseIsMap && overrideIsMap {
+			base[key] = mergeSettings(baseMap, overrideMap)
+		} else {
+			base[key] = overrideValue
+		}
+	}
+	return base
+}
+
+var envPlaceholderRegex = regexp.MustCompile(`%env:([A-Za-z0-9_]+)%`)
+
+// resolvePlaceholders replaces %env:NAME% and the %FLOW_PATH_*% constants in all string values.
+func (l *flowSettingsLoader) resolvePlaceholders(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		resolved := make(map[string]any, len(v))
+		for key, nested := range v {
+			resolved[key] = l.resolvePlaceholders(nested)
+		}
+		return resolved
+	case []any:
+		resolved := make([]any, len(v))
+		for i, nested := range v {
+			resolved[i] = l.resolvePlaceholders(nested)
+		}
+		return resolved
+	case string:
+		v = envPlaceholderRegex.ReplaceAllStringFunc(v, func(placeholder string) string {
+			return l.getenv(envPlaceholderRegex.FindStringSubmatch(placeholder)[1])
+		})
+		return strings.NewReplacer(
+			"%FLOW_PATH_ROOT%", l.rootPath,
+			"%FLOW_PATH_CONFIGURATION%", l.rootPath+"Configuration/",
+			"%FLOW_PATH_DATA%", l.rootPath+"Data/",
+			"%FLOW_PATH_PACKAGES%", l.rootPath+"Packages/",
+			"%FLOW_PATH_WEB%", l.rootPath+"Web/",
+			"%FLOW_PATH_TEMPORARY_BASE%", l.rootPath+"Data/Temporary/",
+		).Replace(v)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,155 @@
+package flowServe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func writeFile(t *testing.T, path string, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFlowSettingsLoaderMergesAlongContextHierarchy(t *testing.T) {
+	root := t.TempDir()
+	// Neos.Neos requires Neos.Flow, so its settings must win - although it is sorted first by path.
+	writeFile(t, filepath.Join(root, "Packages/Application/Neos.Neos/composer.json"), `{"name": "neos/neos", "require": {"neos/flow": "*"}}`)
+	writeFile(t, filepath.Join(root, "Packages/Application/Neos.Neos/Configuration/Settings.yaml"), `
+Neos:
+  Flow:
+    persistence:
+      backendOptions:
+        charset: utf8mb4
+`)
+	writeFile(t, filepath.Join(root, "Packages/Framework/Neos.Flow/composer.json"), `{"name": "neos/flow"}`)
+	writeFile(t, filepath.Join(root, "Packages/Framework/Neos.Flow/Configuration/Settings.Persistence.yaml"), `
+Neos:
+  Flow:
+    persistence:
+      backendOptions:
+        driver: pdo_mysql
+        host: 127.0.0.1
+        charset: utf8
+        port: 3306
+    resource:
+      storages:
+        defaultPersistentResourcesStorage:
+          storageOptions:
+            path: '%FLOW_PATH_DATA%Persistent/Resources/'
+`)
+	writeFile(t, filepath.Join(root, "Configuration/Settings.yaml"), `
+Neos:
+  Flow:
+    persistence:
+      backendOptions:
+        dbname: app
+`)
+	writeFile(t, filepath.Join(root, "Configuration/Production/Settings.yaml"), `
+Neos:
+  Flow:
+    persistence:
+      backendOptions:
+        host: '%env:DB_HOST%'
+        user: prod
+`)
+	writeFile(t, filepath.Join(root, "Configuration/Production/Live/Settings.Database.yaml"), `
+Neos:
+  Flow:
+    persistence:
+      backendOptions:
+        password: '%env:DB_PASSWORD%'
+        unix_socket: /var/run/mysqld/mysqld.sock
+`)
+	// must be ignored, as it is not part of the context hierarchy
+	writeFile(t, filepath.Join(root, "Configuration/Development/Settings.yaml"), `
+Neos:
+  Flow:
+    persistence:
+      backendOptions:
+        user: dev
+`)
+
+	loader, err := newFlowSettingsLoader(root, "Production/Live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader.getenv = func(key string) string {
+		return map[string]string{"DB_HOST": "db.internal", "DB_PASSWORD": "secret"}[key]
+	}
+
+	output, err := loader.ReadSettings("Neos.Flow.persistence.backendOptions")
+	if err != nil {
+		t.Fatalf("ReadSettings: %v", err)
+	}
+	var backendOptions flowPersistenceBackendOptions
+	if err := yaml.Unmarshal([]byte(output), &backendOptions); err != nil {
+		t.Fatalf("unmarshalling %s: %v", output, err)
+	}
+	want := flowPersistenceBackendOptions{
+		Driver:   "pdo_mysql",
+		Host:     "db.internal",
+		DbName:   "app",
+		User:     "prod",
+		Password: "secret",
+		Charset:  "utf8mb4",
+		Port:     "3306",
+
+		UnixSocket: "/var/run/mysqld/mysqld.sock",
+	}
+	if backendOptions != want {
+		t.Errorf("got %+v, want %+v", backendOptions, want)
+	}
+	if socket := backendOptions.ToDbCredentials().Socket; socket != want.UnixSocket {
+		t.Errorf("got socket %q, want %q", socket, want.UnixSocket)
+	}
+
+	output, err = loader.ReadSettings("Neos.Flow.resource")
+	if err != nil {
+		t.Fatalf("ReadSettings: %v", err)
+	}
+	var resourceOptions flowResourceOptions
+	if err := yaml.Unmarshal([]byte(output), &resourceOptions); err != nil {
+		t.Fatalf("unmarshalling %s: %v", output, err)
+	}
+	wantPath := loader.rootPath + "Data/Persistent/Resources/"
+	if path := resourceOptions.Storages["defaultPersistentResourcesStorage"].StorageOptions.Path; path != wantPath {
+		t.Errorf("got storage path %s, want %s", path, wantPath)
+	}
+
+	if _, err := loader.ReadSettings("Neos.Flow.missing"); err == nil {
+		t.Errorf("expected an error for a missing setting")
+	}
+}
+
+func TestFlowSettingsLoaderUsesPackageStatesOrder(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "Configuration/PackageStates.php"), `<?php
+return [
+    'packages' => [
+        'Neos.Flow' => ['packagePath' => 'Packages/Framework/Neos.Flow/', 'composerName' => 'neos/flow'],
+        'Acme.Site' => ['packagePath' => 'Packages/Sites/Acme.Site/', 'composerName' => 'acme/site'],
+    ],
+    'version' => 6,
+];`)
+
+	loader, err := newFlowSettingsLoader(root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packagePaths, err := loader.packagePaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(root, "Packages/Framework/Neos.Flow"), filepath.Join(root, "Packages/Sites/Acme.Site")}
+	if len(packagePaths) != 2 || packagePaths[0] != want[0] || packagePaths[1] != want[1] {
+		t.Errorf("got %v, want %v", packagePaths, want)
+	}
+}
@@ -0,0 +1,99 @@
+package flowServe
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	s3TargetClass  = "Flownative\\Aws\\S3\\S3Target"
+	gcsTargetClass = "Flownative\\GoogleCloudStorage\\GcsTarget"
+)
+
+// targetResolver generates the public URI of a resource, for targets which publish resources to an object storage
+// or CDN. The generated URIs are downloaded as-is (IsAbsoluteUrl), so they must not depend on the web directory.
+type targetResolver func(target *flowResourceTarget, resourceSha1 string, filename string) string
+
+// targetResolvers contains the resolvers for all target classes we know out of the box; further targets can
+// be configured via their persistentResourceUris.pattern option or via flow.targetUriPatterns in .synco-serve.yml.
+var targetResolvers = map[string]targetResolver{}
+
+func registerTargetResolver(targetClass string, resolver targetResolver) {
+	targetResolvers[targetClass] = resolver
+}
+
+func init() {
+	registerTargetResolver(s3TargetClass, generateS3ResourcePublicPath)
+	registerTargetResolver(gcsTargetClass, generateGcsResourcePublicPath)
+}
+
+// resolverForTarget returns the resolver for the given target, or nil if its resources can not be downloaded
+// from an object storage. targetUriPatterns (target class -> pattern) take precedence over the built-in resolvers.
+func resolverForTarget(target *flowResourceTarget, targetUriPatterns map[string]string) targetResolver {
+	if pattern, found := targetUriPatterns[target.Target]; found {
+		return patternTargetResolver(pattern)
+	}
+	if resolver, found := targetResolvers[target.Target]; found {
+		return resolver
+	}
+	if target.IsFileSystemTarget() {
+		return nil
+	}
+	if pattern := target.TargetOptions.PersistentResourceUris.Pattern; pattern != "" {
+		return patternTargetResolver(pattern)
+	}
+	return nil
+}
+
+// patternTargetResolver generates the public URI by replacing the placeholders in a fixed pattern.
+func patternTargetResolver(pattern string) targetResolver {
+	return func(target *flowResourceTarget, resourceSha1 string, filename string) string {
+		return replaceResourceUriPattern(pattern, target.TargetOptions.BaseUri, target, resourceSha1, filename)
+	}
+}
+
+// replaceResourceUriPattern replaces the placeholders which are supported by the persistentResourceUris.pattern
+// option of the Flownative S3 and Google Cloud Storage targets. The filename is escaped, like in the URIs generated
+// by Flow.
+func replaceResourceUriPattern(pattern string, baseUri string, target *flowResourceTarget, resourceSha1 string, filename string) string {
+	filename = escapeResourceFilename(filename)
+	return strings.NewReplacer(
+		"{baseUri}", baseUri,
+		"{bucketName}", target.TargetOptions.Bucket,
+		"{keyPrefix}", target.TargetOptions.KeyPrefix,
+		"{sha1}", resourceSha1,
+		"{filename}", filename,
+		"{fileExtension}", strings.TrimPrefix(filepath.Ext(filename), "."),
+	).Replace(pattern)
+}
+
+// generateS3ResourcePublicPath replicates S3Target::getPublicPersistentResourceUri
+// https://github.com/flownative/flow-aws-s3/blob/main/Classes/S3Target.php#L465
+func generateS3ResourcePublicPath(persistentTarget *flowResourceTarget, resourceSha1 string, filename string) string {
+	if pattern := persistentTarget.TargetOptions.PersistentResourceUris.Pattern; pattern != "" {
+		return replaceResourceUriPattern(pattern, persistentTarget.TargetOptions.BaseUri, persistentTarget, resourceSha1, filename)
+	}
+
+	return persistentTarget.TargetOptions.BaseUri + resourceSha1 + "/" + escapeResourceFilename(filename)
+}
+
+// generateGcsResourcePublicPath replicates GcsTarget::getPublicPersistentResourceUri
+// https://github.com/flownative/flow-google-cloudstorage/blob/main/Classes/GcsTarget.php
+func generateGcsResourcePublicPath(persistentTarget *flowResourceTarget, resourceSha1 string, filename string) string {
+	baseUri := persistentTarget.TargetOptions.BaseUri
+	if baseUri == "" {
+		baseUri = "https://storage.googleapis.com/" + persistentTarget.TargetOptions.Bucket + "/"
+	}
+	pattern := persistentTarget.TargetOptions.PersistentResourceUris.Pattern
+	if pattern == "" {
+		pattern = "{baseUri}{keyPrefix}{sha1}/{filename}"
+	}
+	return replaceResourceUriPattern(pattern, baseUri, persistentTarget, resourceSha1, filename)
+}
+
+// HACK: this is how it works for Neos / Flow. Probably not all escapes done
+func escapeResourceFilename(filename string) string {
+	escapedFileName := url.PathEscape(filename)
+	return strings.ReplaceAll(escapedFileName, "+", "%2B")
+}
@@ -0,0 +1,50 @@
+package flowServe
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type composerLock struct {
+	Packages []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"packages"`
+}
+
+// detectNeosMajorVersion reads the installed major version of neos/neos from composer.lock. Returns 0 if
+// Neos is not installed (plain Flow application) or the version could not be determined (f.e. dev-main).
+func detectNeosMajorVersion(composerLockFile string) int {
+	contents, err := os.ReadFile(composerLockFile)
+	if err != nil {
+		return 0
+	}
+	var lock composerLock
+	if err := json.Unmarshal(contents, &lock); err != nil {
+		return 0
+	}
+
+	for _, packageName := range []string{"neos/neos", "neos/contentrepository-core"} {
+		for _, p := range lock.Packages {
+			if p.Name == packageName {
+				if majorVersion := parseMajorVersion(p.Version); majorVersion > 0 {
+					return majorVersion
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// parseMajorVersion parses composer versions like 9.0.3, v8.3.12 or 9.0.x-dev
+func parseMajorVersion(version string) int {
+	version = strings.TrimPrefix(version, "v")
+	majorVersion, _, _ := strings.Cut(version, ".")
+	parsed, err := strconv.Atoi(majorVersion)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
@@ -0,0 +1,34 @@
+package flowServe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectNeosMajorVersion(t *testing.T) {
+	tests := []struct {
+		composerLock string
+		want         int
+	}{
+		{`{"packages": [{"name": "neos/flow", "version": "9.0.1"}, {"name": "neos/neos", "version": "9.0.3"}]}`, 9},
+		{`{"packages": [{"name": "neos/neos", "version": "v8.3.12"}]}`, 8},
+		{`{"packages": [{"name": "neos/neos", "version": "dev-main"}, {"name": "neos/contentrepository-core", "version": "9.1.x-dev"}]}`, 9},
+		// plain Flow application
+		{`{"packages": [{"name": "neos/flow", "version": "8.3.0"}]}`, 0},
+		{`not json`, 0},
+	}
+	for _, tt := range tests {
+		composerLockFile := filepath.Join(t.TempDir(), "composer.lock")
+		if err := os.WriteFile(composerLockFile, []byte(tt.composerLock), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := detectNeosMajorVersion(composerLockFile); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.composerLock, got, tt.want)
+		}
+	}
+
+	if got := detectNeosMajorVersion(filepath.Join(t.TempDir(), "missing.lock")); got != 0 {
+		t.Errorf("missing composer.lock: got %d, want 0", got)
+	}
+}
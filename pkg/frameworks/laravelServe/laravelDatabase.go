@@ -0,0 +1,265 @@
+package laravelServe
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common"
+	"github.com/sandstorm/synco/v2/pkg/common/commonServe"
+	"github.com/sandstorm/synco/v2/pkg/common/config"
+	"github.com/sandstorm/synco/v2/pkg/serve"
+)
+
+type laravelDatabaseOptions struct {
+	Default     string `json:"default"`
+	Connections map[string]laravelDatabaseConnectionOptions
+}
+
+type laravelDatabaseConnectionOptions struct {
+	Driver     string         `json:"driver"`
+	Url        string         `json:"url"`
+	Host       string         `json:"host"`
+	Port       flexibleString `json:"port"`
+	UnixSocket string         `json:"unix_socket"`
+	Database   string         `json:"database"`
+	Username   string         `json:"username"`
+	Password   string         `json:"password"`
+}
+
+// flexibleString accepts both JSON strings and numbers, as f.e. the port is a string if it comes from
+// the environment, but often a number if it is hard-coded in config/database.php.
+type flexibleString string
+
+func (f *flexibleString) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*f = flexibleString(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*f = flexibleString(n.String())
+	return nil
+}
+
+// connectionNames returns all configured connection names; the default connection first, the others sorted by name.
+func (ldo *laravelDatabaseOptions) connectionNames() []string {
+	names := make([]string, 0, len(ldo.Connections))
+	for name := range ldo.Connections {
+		if name != ldo.Default {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, found := ldo.Connections[ldo.Default]; found {
+		names = append([]string{ldo.Default}, names...)
+	}
+	return names
+}
+
+// urlDriverAliases replicates ConfigurationUrlParser::$driverAliases from Laravel.
+var urlDriverAliases = map[string]string{
+	"mssql":      "sqlsrv",
+	"mysql2":     "mysql",
+	"postgres":   "pgsql",
+	"postgresql": "pgsql",
+	"sqlite3":    "sqlite",
+	"redis":      "tcp",
+	"rediss":     "tls",
+}
+
+// withUrlApplied replicates Laravel's ConfigurationUrlParser: if the connection is configured via "url"
+// (usually DATABASE_URL / DB_URL), the URL parts override the separately configured options.
+func (connection laravelDatabaseConnectionOptions) withUrlApplied() (laravelDatabaseConnectionOptions, error) {
+	if len(connection.Url) == 0 {
+		return connection, nil
+	}
+
+	u, err := url.Parse(connection.Url)
+	if err != nil {
+		return connection, fmt.Errorf("could not parse database URL: %w", err)
+	}
+
+	if len(u.Scheme) > 0 {
+		connection.Driver = u.Scheme
+		if alias, found := urlDriverAliases[u.Scheme]; found {
+			connection.Driver = alias
+		}
+	}
+	if len(u.Hostname()) > 0 {
+		connection.Host = u.Hostname()
+	}
+	if len(u.Port()) > 0 {
+		connection.Port = flexibleString(u.Port())
+	}
+	if u.User != nil {
+		connection.Username = u.User.Username()
+		if password, set := u.User.Password(); set {
+			connection.Password = password
+		}
+	}
+	if database := strings.TrimPrefix(u.Path, "/"); len(database) > 0 {
+		connection.Database = database
+	}
+	if unixSocket := u.Query().Get("unix_socket"); len(unixSocket) > 0 {
+		connection.UnixSocket = unixSocket
+	}
+	return connection, nil
+}
+
+func (connection laravelDatabaseConnectionOptions) ToDbCredentials() (*common.DbCredentials, error) {
+	port := 3306
+	if len(connection.Port) != 0 {
+		var err error
+		port, err = strconv.Atoi(string(connection.Port))
+		if err != nil {
+			return nil, fmt.Errorf("port %q is no number: %w", connection.Port, err)
+		}
+	}
+	return &common.DbCredentials{
+		Host:     connection.Host,
+		Port:     port,
+		Socket:   connection.UnixSocket,
+		User:     connection.Username,
+		Password: connection.Password,
+		DbName:   connection.Database,
+	}, nil
+}
+
+func (connection laravelDatabaseConnectionOptions) isSupported() bool {
+	return connection.isMysql() || connection.isSqlite()
+}
+
+func (connection laravelDatabaseConnectionOptions) isMysql() bool {
+	return connection.Driver == "mysql" || connection.Driver == "mariadb"
+}
+
+func (connection laravelDatabaseConnectionOptions) isSqlite() bool {
+	return connection.Driver == "sqlite"
+}
+
+// dumpDatabaseConnections dumps every configured connection with a supported driver into its own file set
+// (dbDump-mysql, dbDump-tenant, ...).
+//
+// The default connection MUST work. Laravel's default config/database.php however contains lots of
+// connections (all reading the same DB_* environment variables) which are not used by the application; so
+// failures for non-default connections are only reported, and connections pointing to the same database
+// as an already-dumped one are skipped.
+func dumpDatabaseConnections(transferSession *serve.TransferSession, ldo laravelDatabaseOptions) {
+	if _, found := ldo.Connections[ldo.Default]; !found {
+		transferSession.Warn("Default DB connection '%s' not found in config, only dumping additional connections.", ldo.Default)
+	}
+
+	dumpedDatabases := make(map[string]string)
+	for _, name := range ldo.connectionNames() {
+		isDefault := name == ldo.Default
+		connection, err := ldo.Connections[name].withUrlApplied()
+		if err != nil {
+			transferSession.Warn("Skipping DB connection %s: %s", name, err)
+			continue
+		}
+
+		if !connection.isSupported() {
+			if isDefault {
+				transferSession.Warn("Default DB connection %s uses driver %s, which is not supported right now - so NOT transferring it.", name, connection.Driver)
+			} else {
+				pterm.Info.Printfln("Skipping DB connection %s: driver %s not supported right now.", name, connection.Driver)
+			}
+			continue
+		}
+
+		if connection.isSqlite() {
+			if dumpSqliteConnection(transferSession, name, isDefault, connection, dumpedDatabases) {
+				dumpedDatabases["sqlite|"+connection.Database] = name
+			}
+			continue
+		}
+
+		dbCredentials, err := connection.ToDbCredentials()
+		if err != nil {
+			transferSession.Warn("Skipping DB connection %s: %s", name, err)
+			continue
+		}
+		databaseKey := fmt.Sprintf("%s|%s:%d|%s", dbCredentials.Socket, dbCredentials.Host, dbCredentials.Port, dbCredentials.DbName)
+		if otherName, found := dumpedDatabases[databaseKey]; found {
+			pterm.Debug.Printfln("Skipping DB connection %s, as it points to the same database as %s.", name, otherName)
+			continue
+		}
+
+		pterm.Info.Printfln("Dumping DB connection %s (driver: %s, host: %s, socket: %s, user: %s)", name, connection.Driver, dbCredentials.Host, dbCredentials.Socket, dbCredentials.User)
+		fileSetName := "dbDump-" + name
+		tableLimits := laravelTableLimits
+		if transferSession.DumpAll {
+			tableLimits = nil
+		}
+		if isDefault {
+			tx := commonServe.DatabaseDump(transferSession, fileSetName, dbCredentials, map[string]string{}, map[string]string{}, tableLimits)
+			_ = tx.Rollback()
+		} else if tx, err := commonServe.TryDatabaseDump(transferSession, fileSetName, dbCredentials, map[string]string{}, map[string]string{}, tableLimits); err != nil {
+			transferSession.Warn("Could not dump DB connection %s (skipping): %s", name, err)
+			continue
+		} else {
+			_ = tx.Rollback()
+		}
+		dumpedDatabases[databaseKey] = name
+	}
+}
+
+// laravelTableLimits only transfers the newest rows of tables which grow without bounds, and are usually not needed
+// for development (use --all to transfer them completely).
+var laravelTableLimits = map[string]config.SyncoServeDumpTableLimit{
+	"failed_jobs": {OrderBy: "id DESC", Limit: 1000},
+	// spatie/laravel-activitylog
+	"activity_log": {OrderBy: "id DESC", Limit: 10000},
+}
+
+// dumpSqliteConnection dumps the database file of a sqlite connection; returns true if it was dumped.
+func dumpSqliteConnection(transferSession *serve.TransferSession, name string, isDefault bool, connection laravelDatabaseConnectionOptions, dumpedDatabases map[string]string) bool {
+	if otherName, found := dumpedDatabases["sqlite|"+connection.Database]; found {
+		pterm.Debug.Printfln("Skipping DB connection %s, as it points to the same database as %s.", name, otherName)
+		return false
+	}
+	if len(connection.Database) == 0 || connection.Database == ":memory:" {
+		pterm.Info.Printfln("Skipping DB connection %s: in-memory SQLite database.", name)
+		return false
+	}
+	if _, err := os.Stat(connection.Database); err != nil {
+		if isDefault {
+			transferSession.Warn("Default DB connection %s: SQLite database %s not found - so NOT transferring it.", name, connection.Database)
+		} else {
+			pterm.Info.Printfln("Skipping DB connection %s: SQLite database %s not found.", name, connection.Database)
+		}
+		return false
+	}
+
+	pterm.Info.Printfln("Dumping DB connection %s (driver: sqlite, database: %s)", name, connection.Database)
+	err := commonServe.SqliteDump(transferSession, "dbDump-"+name, connection.Database)
+	if err != nil {
+		if isDefault {
+			pterm.Fatal.Printfln("could not create SQLite dump: %s", err)
+		}
+		transferSession.Warn("Could not dump DB connection %s (skipping): %s", name, err)
+		return false
+	}
+	return true
+}
+
+func extractDatabaseCredentialsFromLaravel() laravelDatabaseOptions {
+	pterm.Debug.Println("Finding database credentials")
+	output := runArtisanTinker("echo json_encode(config('database'))")
+	var laravelDb laravelDatabaseOptions
+	err := json.Unmarshal([]byte(output), &laravelDb)
+	if err != nil {
+		pterm.Fatal.Printfln("could not parse output of artisan tinker: %s. Output was: %s", err, output)
+	}
+	pterm.Info.Printfln("Extracted %d DB connections, default: %s", len(laravelDb.Connections), laravelDb.Default)
+	return laravelDb
+}
@@ -0,0 +1,83 @@
+package laravelServe
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sandstorm/synco/v2/pkg/common/commonServe"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/serve"
+	"github.com/sandstorm/synco/v2/pkg/util/s3"
+)
+
+// presigned URLs for private S3 disks need to be valid as long as somebody might run "synco receive".
+const presignedUrlExpiry = 24 * time.Hour
+
+// extractResourcesFromS3 builds a public files index of all objects of a Laravel S3 disk; so that
+// "synco receive" can download them directly from the bucket.
+//
+// For public disks, we use the public URLs (like Storage::url() does); for private disks we generate presigned URLs
+// (like Storage::temporaryUrl() does). As the index file is encrypted, the presigned URLs are not leaked.
+//
+// Unlike for local disks (whose files are stored below their root path), the files are stored below the name of the
+// disk on the receiving side (dump/<disk>/<key relative to root>): the root of an S3 disk is a key prefix in the
+// bucket, not a path in the application - and without the disk name, two S3 disks with the same (or no) root would
+// overwrite each other.
+func extractResourcesFromS3(transferSession *serve.TransferSession, name string, disk laravelDisk) {
+	defer transferSession.RecordPhase("resources "+name, time.Now())
+	client := &s3.Client{
+		Endpoint:     disk.Endpoint,
+		Region:       disk.Region,
+		Bucket:       disk.Bucket,
+		AccessKey:    disk.Key,
+		SecretKey:    disk.Secret,
+		UsePathStyle: disk.UsePathStyleEndpoint,
+	}
+
+	prefix := strings.Trim(disk.Root, "/")
+	if len(prefix) > 0 {
+		prefix += "/"
+	}
+
+	resourceFilesIndex := make(dto.PublicFilesIndex)
+	totalSizeBytes := uint64(0)
+	err := client.ListObjects(prefix, func(object s3.Object) error {
+		publicUri, err := s3PublicUri(client, disk, object.Key)
+		if err != nil {
+			return err
+		}
+
+		totalSizeBytes += uint64(object.SizeBytes)
+		resourceFilesIndex[path.Join(name, strings.TrimPrefix(object.Key, prefix))] = dto.PublicFilesIndexEntry{
+			SizeBytes:     object.SizeBytes,
+			MTime:         object.LastModified.Unix(),
+			PublicUri:     publicUri,
+			IsAbsoluteUrl: true,
+		}
+		return nil
+	})
+	if err != nil {
+		transferSession.Warn("Could NOT list objects of S3 storage %s (skipping): %s", name, err)
+		return
+	}
+
+	commonServe.WriteResourcesIndex(transferSession, dto.TYPE_PUBLICFILES, name, resourceFilesIndex, totalSizeBytes)
+}
+
+func s3PublicUri(client *s3.Client, disk laravelDisk, key string) (string, error) {
+	if disk.Visibility != "public" {
+		return client.PresignGetObject(key, presignedUrlExpiry)
+	}
+
+	if len(disk.Url) > 0 {
+		// replicates FilesystemAdapter::url() - the configured URL is prepended to the (prefixed) path.
+		return url.JoinPath(disk.Url, key)
+	}
+	u, err := client.ObjectUrl(key)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
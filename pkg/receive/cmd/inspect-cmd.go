@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/receive"
+	"github.com/spf13/cobra"
+)
+
+var showReport bool
+
+var InspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Show what a transfer session contains, without downloading it",
+	Long: `Shows the file sets of a transfer session, and all tables of the database dumps (rows, size, filters).
+
+With --report, the report of the session is printed instead (as Markdown): configuration sources, database server
+version, file sets, warnings and durations.`,
+	Args: cobra.ExactArgs(2),
+	Example: `synco inspect [identifier] [password]
+synco inspect --report [identifier] [password]`,
+	Run: func(cmd *cobra.Command, args []string) {
+		receiveSession, err := receive.NewSession(args[0], args[1])
+		if err != nil {
+			pterm.Fatal.Printfln("Error initializing receive session: %s", err)
+		}
+		if err := detectBaseUrlAndUpdateReceiveSession(receiveSession); err != nil {
+			pterm.Fatal.Printfln("Error detecting base URL: %s", err)
+		}
+		if showReport {
+			report, err := receiveSession.FetchAndDecryptFileWithProgressBar(dto.FILENAME_REPORT_MARKDOWN, "")
+			if err != nil {
+				pterm.Fatal.Printfln("The report could not be fetched (the session is not ready yet, or was created with an older synco version): %s", err)
+			}
+			fmt.Print(report.String())
+			return
+		}
+
+		meta, err := receiveSession.FetchMeta()
+		if err != nil {
+			pterm.Fatal.Printfln("Metadata could not be fetched: %s", err)
+		}
+
+		pterm.Info.Printfln("Framework on server: %s", meta.FrameworkName)
+		pterm.Info.Printfln("State: %s", meta.State)
+		for _, fileSet := range meta.FileSets {
+			pterm.DefaultSection.Println(fileSet.Label())
+			if fileSet.IsMysqlDump() {
+				printTableBreakdown(fileSet, 0)
+			}
+		}
+	},
+}
+
+func init() {
+	InspectCmd.Flags().BoolVar(&showReport, "report", false, "print the report of the session (Markdown)")
+}
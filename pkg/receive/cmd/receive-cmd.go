@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pterm/pterm"
@@ -21,10 +22,15 @@ import (
 	"github.com/sandstorm/synco/v2/pkg/ui/boolselect"
 	"github.com/sandstorm/synco/v2/pkg/ui/multiselect"
 	"github.com/sandstorm/synco/v2/pkg/ui/textinput"
+	"github.com/sandstorm/synco/v2/pkg/util"
+	"github.com/sandstorm/synco/v2/pkg/util/compression"
+	"github.com/sandstorm/synco/v2/pkg/util/mysql"
 	"github.com/spf13/cobra"
 )
 
 var interactive bool
+var parallel int
+var importDsn string
 
 var ReceiveCmd = &cobra.Command{
 	Use:     "receive",
@@ -65,21 +71,38 @@ var ReceiveCmd = &cobra.Command{
 			return fileSet.Label()
 		})(meta.FileSets)
 
+		for _, fileSet := range meta.FileSets {
+			if fileSet.IsMysqlDump() {
+				printTableBreakdown(fileSet, 10)
+			}
+		}
+
 		if interactive {
 			filesToDownload = multiselect.Exec("Select data to download", filesToDownload, filesToDownload)
 		}
 
+		syncoConfig, err := config.ReadFromYaml()
+		if err != nil {
+			pterm.Fatal.Printfln("could not read %s: %s", config.SyncoYamlFile, err)
+		}
+
 		for _, fileToDownload := range filesToDownload {
 			fileSet := meta.FileSetByLabel(fileToDownload)
+			if err := compression.Validate(fileSet.Compression); err != nil {
+				pterm.Fatal.Printfln("Can not download %s: %s", fileSet.Name, err)
+			}
+			runReceiveHooks("beforeFileSet", syncoConfig.Hooks.BeforeFileSet, fileSet)
 			pterm.Info.Printfln("Downloading: %s (%s)", fileToDownload, fileSet.Type)
 
-			switch fileSet.Type {
-			case dto.TYPE_MYSQLDUMP:
+			switch fileSet.Type.Base() {
+			case dto.TYPE_MYSQLDUMP, dto.TYPE_MYSQLDUMP_CHUNKED:
 				err = downloadMysqldump(receiveSession, fileSet)
 			case dto.TYPE_PUBLICFILES:
 				err = downloadPublicFiles(receiveSession, fileSet)
 			case dto.TYPE_PRIVATE_ENCRYPTED_FILES:
 				err = downloadPrivateEncryptedFiles(receiveSession, fileSet)
+			case dto.TYPE_SQLITE:
+				err = downloadSqlite(receiveSession, fileSet)
 			default:
 				pterm.Fatal.Printfln("File Set type %s was unimplemented.", fileSet.Type)
 			}
@@ -87,9 +110,14 @@ var ReceiveCmd = &cobra.Command{
 			if err != nil {
 				pterm.Fatal.Printfln("Error with file type %s: %s", fileSet.Type, err)
 			}
+			runReceiveHooks("afterFileSet", syncoConfig.Hooks.AfterFileSet, fileSet)
 		}
+		runReceiveHooks("afterReceive", syncoConfig.Hooks.AfterReceive, nil)
 
 		pterm.Success.Printfln("All downloaded to dump/")
+		for _, hint := range meta.PostReceiveHints {
+			pterm.Warning.Printfln("%s", hint)
+		}
 
 		/*for _, framework := range RegisteredFrameworks {
 			if framework.Name() == meta.frameworkName {
@@ -105,6 +133,20 @@ var ReceiveCmd = &cobra.Command{
 	},
 }
 
+// runReceiveHooks runs the hooks from .synco.yml applying to the given file set (nil: hooks not specific to a file set).
+func runReceiveHooks(hookName string, hooks []config.SyncoReceiveHook, fileSet *dto.FileSet) {
+	env := []string{"SYNCO_DUMP_DIR=dump"}
+	fileSetName := ""
+	if fileSet != nil {
+		fileSetName = fileSet.Name
+		env = append(env, "SYNCO_FILESET_NAME="+fileSet.Name, "SYNCO_FILESET_TYPE="+string(fileSet.Type.Base()))
+	}
+	err := util.RunHooks(hookName, config.CommandsForFileSet(hooks, fileSetName), env)
+	if err != nil {
+		pterm.Fatal.Printfln("%s", err)
+	}
+}
+
 // detectBaseUrlAndUpdateReceiveSession tries to find the base URL, by:
 // - reading .synco.yml
 // - asking the user
@@ -215,12 +257,152 @@ func detectBaseUrlAndUpdateReceiveSession(rs *receive.ReceiveSession) error {
 }
 
 func downloadMysqldump(receiveSession *receive.ReceiveSession, fileSet *dto.FileSet) error {
-	return receiveSession.DumpAndDecryptFileWithProgressBar(fileSet.MysqlDump.FileName, fileSet.Name+".sql")
+	if len(fileSet.MysqlDump.Chunks) > 0 {
+		err := downloadMysqldumpChunks(receiveSession, fileSet)
+		if err != nil {
+			return err
+		}
+	}
+	err := receiveSession.DumpAndDecryptFileWithProgressBar(fileSet.MysqlDump.FileName, fileSet.Name+".sql", fileSet.Compression)
+	if err != nil {
+		return err
+	}
+
+	if len(fileSet.MysqlDump.UnchangedTables) > 0 {
+		pterm.Info.Printfln("%d tables were unchanged since the last refresh and are NOT contained in the dump: %s", len(fileSet.MysqlDump.UnchangedTables), strings.Join(fileSet.MysqlDump.UnchangedTables, ", "))
+		pterm.Warning.Printfln("Only import this dump into the database which was refreshed last time - the unchanged tables are kept as they are.")
+	}
+
+	if len(importDsn) > 0 {
+		mainFileName := receiveSession.PathInWorkDir(fileSet.Name + ".sql")
+		if len(fileSet.MysqlDump.Chunks) > 0 {
+			tableFileNames := make([]string, 0, len(fileSet.MysqlDump.Chunks))
+			for _, chunk := range fileSet.MysqlDump.Chunks {
+				localFileName := filepath.Join(fileSet.Name, strings.TrimSuffix(strings.TrimPrefix(chunk.FileName, fileSet.Name+"-"), ".enc"))
+				tableFileNames = append(tableFileNames, receiveSession.PathInWorkDir(localFileName))
+			}
+			// the main file is imported last, as it contains the triggers, views, routines and events.
+			err = mysql.ImportParallelDump(importDsn, tableFileNames, mainFileName, parallel)
+		} else {
+			err = mysql.ImportFiles(importDsn, []string{mainFileName})
+		}
+		if err != nil {
+			return fmt.Errorf("import failed: %w", err)
+		}
+		pterm.Success.Printfln("Imported %s", fileSet.Name)
+	}
+
+	if err := writeTableStats(receiveSession, fileSet); err != nil {
+		return err
+	}
+	return writeChecksums(receiveSession, fileSet)
+}
+
+// writeTableStats stores the row counts (and hashes) of the dump to dump/<file set name>.tables.json, so that
+// "synco verify-dump --tables" can check an import without relying on the footers of the SQL files.
+func writeTableStats(receiveSession *receive.ReceiveSession, fileSet *dto.FileSet) error {
+	if len(fileSet.MysqlDump.Tables) == 0 {
+		return nil
+	}
+	tablesJson, err := json.MarshalIndent(fileSet.MysqlDump.Tables, "", "  ")
+	if err != nil {
+		return err
+	}
+	return receiveSession.WriteFileInWorkDir(fileSet.Name+".tables.json", tablesJson)
+}
+
+// writeChecksums stores the table checksums of the dump to dump/<file set name>.checksums, so that the next
+// "synco serve --known-checksums" only transfers the changed tables.
+func writeChecksums(receiveSession *receive.ReceiveSession, fileSet *dto.FileSet) error {
+	if len(fileSet.MysqlDump.Checksums) == 0 {
+		return nil
+	}
+	token, err := dto.EncodeChecksums(fileSet.MysqlDump.Checksums)
+	if err != nil {
+		return err
+	}
+	checksumsFileName := fileSet.Name + ".checksums"
+	if err := receiveSession.WriteFileInWorkDir(checksumsFileName, []byte(token+"\n")); err != nil {
+		return err
+	}
+	pterm.Info.Printfln("Table checksums written to %s. After importing the dump, run the next refresh with:", receiveSession.PathInWorkDir(checksumsFileName))
+	pterm.Info.Printfln("    synco serve --known-checksums %s", token)
+	return nil
+}
+
+// downloadMysqldumpChunks downloads the tables of a parallel dump concurrently, to dump/<file set name>/<table>.sql
+func downloadMysqldumpChunks(receiveSession *receive.ReceiveSession, fileSet *dto.FileSet) error {
+	workerCount := parallel
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	progress, _ := pterm.DefaultProgressbar.WithTotal(int(fileSet.MysqlDump.SizeBytes)).WithTitle(fmt.Sprintf("%d tables", len(fileSet.MysqlDump.Chunks))).Start()
+	var progressMutex sync.Mutex
+	onProgress := func(n int) {
+		progressMutex.Lock()
+		defer progressMutex.Unlock()
+		progress.Add(n)
+	}
+
+	chunks := make(chan dto.FileSetMysqlDumpChunk)
+	errs := make(chan error, len(fileSet.MysqlDump.Chunks))
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				if strings.ContainsAny(chunk.FileName, "/\\") || strings.Contains(chunk.FileName, "..") {
+					errs <- fmt.Errorf("refusing to download table %s from suspicious file name %s", chunk.Table, chunk.FileName)
+					continue
+				}
+				localFileName := filepath.Join(fileSet.Name, strings.TrimSuffix(strings.TrimPrefix(chunk.FileName, fileSet.Name+"-"), ".enc"))
+				if err := receiveSession.DumpAndDecryptFile(chunk.FileName, localFileName, fileSet.Compression, onProgress); err != nil {
+					errs <- fmt.Errorf("table %s: %w", chunk.Table, err)
+				}
+			}
+		}()
+	}
+	for _, chunk := range fileSet.MysqlDump.Chunks {
+		chunks <- chunk
+	}
+	close(chunks)
+	wg.Wait()
+	close(errs)
+	_, _ = progress.Stop()
+
+	var err error
+	for chunkErr := range errs {
+		err = errors.Join(err, chunkErr)
+	}
+	if err != nil {
+		return err
+	}
+	if len(importDsn) > 0 {
+		pterm.Success.Printfln("Downloaded %d tables to dump/%s/", len(fileSet.MysqlDump.Chunks), fileSet.Name)
+	} else {
+		pterm.Success.Printfln("Downloaded %d tables to dump/%s/ - import them (they can be imported in parallel) before dump/%s.sql, which contains the triggers, views, routines and events.", len(fileSet.MysqlDump.Chunks), fileSet.Name, fileSet.Name)
+	}
+	return nil
+}
+
+// downloadSqlite restores the SQLite database to its original path (relative to the application root) inside dump/.
+func downloadSqlite(receiveSession *receive.ReceiveSession, fileSet *dto.FileSet) error {
+	relativePath := filepath.Clean(filepath.FromSlash(fileSet.Sqlite.RelativePath))
+	if filepath.IsAbs(relativePath) || strings.HasPrefix(relativePath, "..") {
+		return fmt.Errorf("refusing to write SQLite database outside of dump/: %s", fileSet.Sqlite.RelativePath)
+	}
+	err := receiveSession.DumpAndDecryptFileWithProgressBar(fileSet.Sqlite.FileName, relativePath, fileSet.Compression)
+	if err != nil {
+		return err
+	}
+	pterm.Success.Printfln("Restored SQLite database to dump/%s", filepath.ToSlash(relativePath))
+	return nil
 }
 
 func downloadPublicFiles(receiveSession *receive.ReceiveSession, fileSet *dto.FileSet) error {
 	indexFileName := fileSet.Name + ".index.json"
-	err := receiveSession.DumpAndDecryptFileWithProgressBar(fileSet.PublicFiles.IndexFileName, indexFileName)
+	err := receiveSession.DumpAndDecryptFileWithProgressBar(fileSet.PublicFiles.IndexFileName, indexFileName, compression.None)
 	if err != nil {
 		return fmt.Errorf("error dumping/decrypting files: %w", err)
 	}
@@ -288,7 +470,7 @@ func downloadPublicFiles(receiveSession *receive.ReceiveSession, fileSet *dto.Fi
 }
 
 func downloadPrivateEncryptedFiles(receiveSession *receive.ReceiveSession, fileSet *dto.FileSet) error {
-	tarBytes, err := receiveSession.FetchAndDecryptFileWithProgressBar(fileSet.PrivateEncryptedFiles.TarUri)
+	tarBytes, err := receiveSession.FetchAndDecryptFileWithProgressBar(fileSet.PrivateEncryptedFiles.TarUri, fileSet.Compression)
 	if err != nil {
 		return fmt.Errorf("error decrypting files: %w", err)
 	}
@@ -359,4 +541,6 @@ func extractTar(input *bytes.Buffer, destination string) error {
 
 func init() {
 	ReceiveCmd.Flags().BoolVar(&interactive, "interactive", true, "interactively select which files to download")
+	ReceiveCmd.Flags().IntVar(&parallel, "parallel", 4, "number of parallel downloads (and imports, with --import-dsn) for database dumps which were created with --parallel")
+	ReceiveCmd.Flags().StringVar(&importDsn, "import-dsn", "", "import database dumps directly into this database, f.e. user:password@tcp(127.0.0.1:3306)/db")
 }
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/dustin/go-humanize"
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+)
+
+// printTableBreakdown shows the biggest tables of a database dump (all tables for maxTables <= 0), so that it is
+// visible which tables dominate the download.
+func printTableBreakdown(fileSet *dto.FileSet, maxTables int) {
+	if fileSet.MysqlDump == nil || len(fileSet.MysqlDump.Tables) == 0 {
+		return
+	}
+	tables := append([]dto.FileSetMysqlDumpTable{}, fileSet.MysqlDump.Tables...)
+	sort.SliceStable(tables, func(i, j int) bool {
+		return tables[i].SizeBytes > tables[j].SizeBytes
+	})
+	var totalBytes uint64
+	for _, table := range tables {
+		totalBytes += table.SizeBytes
+	}
+
+	data := pterm.TableData{{"Table", "Rows", "Size (SQL)", "Share", "Dumped"}}
+	for i, table := range tables {
+		if maxTables > 0 && i >= maxTables {
+			data = append(data, []string{fmt.Sprintf("... %d more tables", len(tables)-maxTables), "", "", "", ""})
+			break
+		}
+		share := "-"
+		if totalBytes > 0 {
+			share = fmt.Sprintf("%.1f%%", float64(table.SizeBytes)*100/float64(totalBytes))
+		}
+		dumped := table.Mode
+		if len(table.Filter) > 0 {
+			dumped += ": " + table.Filter
+		}
+		data = append(data, []string{table.Name, strconv.FormatInt(table.Rows, 10), humanize.IBytes(table.SizeBytes), share, dumped})
+	}
+
+	pterm.Info.Printfln("Tables of %s (%d tables, %s uncompressed):", fileSet.Name, len(tables), humanize.IBytes(totalBytes))
+	if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+		pterm.Warning.Printfln("Could not render table breakdown: %s", err)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/pterm/pterm"
 	"github.com/sandstorm/synco/v2/pkg/common/dto"
 	"github.com/sandstorm/synco/v2/pkg/ui/boolselect"
+	"github.com/sandstorm/synco/v2/pkg/util/compression"
 )
 
 type State string
@@ -161,7 +162,14 @@ func (rs *ReceiveSession) loadMetaFile() (*http.Response, error) {
 	}
 	return resp, nil
 }
-func (rs *ReceiveSession) FetchAndDecryptFileWithProgressBar(fileName string) (*bytes.Buffer, error) {
+
+// FetchAndDecryptFileWithProgressBar downloads and decrypts the file; afterwards, it is decompressed with the given
+// codec (see package compression; empty for uncompressed files).
+func (rs *ReceiveSession) FetchAndDecryptFileWithProgressBar(fileName string, codec string) (*bytes.Buffer, error) {
+	if err := compression.Validate(codec); err != nil {
+		return nil, err
+	}
+
 	urlToLoad, err := url.JoinPath(*rs.baseUrl, rs.identifier, fileName)
 	pterm.Debug.Printfln("Trying to download %s", urlToLoad)
 	if err != nil {
@@ -208,8 +216,13 @@ func (rs *ReceiveSession) FetchAndDecryptFileWithProgressBar(fileName string) (*
 	if err != nil {
 		return nil, err
 	}
+	decompressedReader, err := compression.NewReader(codec, decryptedReader)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = decompressedReader.Close() }()
 	buf := new(bytes.Buffer)
-	_, err = buf.ReadFrom(decryptedReader)
+	_, err = buf.ReadFrom(decompressedReader)
 
 	// Now, we can check for ioCopyErr from the goroutine above
 	wg.Wait()
@@ -291,8 +304,8 @@ func (rs *ReceiveSession) FetchFileWithProgressBar(fileName string, fileDefiniti
 	return buf, nil
 }
 
-func (rs *ReceiveSession) DumpAndDecryptFileWithProgressBar(remoteFileName string, localFileName string) error {
-	contents, err := rs.FetchAndDecryptFileWithProgressBar(remoteFileName)
+func (rs *ReceiveSession) DumpAndDecryptFileWithProgressBar(remoteFileName string, localFileName string, codec string) error {
+	contents, err := rs.FetchAndDecryptFileWithProgressBar(remoteFileName, codec)
 	if err != nil {
 		return err
 	}
@@ -308,6 +321,55 @@ func (rs *ReceiveSession) DumpAndDecryptFileWithProgressBar(remoteFileName strin
 	return nil
 }
 
+// DumpAndDecryptFile streams the decrypted (and decompressed) file to disk, without its own progress bar (so it can be
+// called concurrently); onProgress is called with the number of downloaded (encrypted) bytes.
+func (rs *ReceiveSession) DumpAndDecryptFile(remoteFileName string, localFileName string, codec string, onProgress func(n int)) error {
+	if err := compression.Validate(codec); err != nil {
+		return err
+	}
+	urlToLoad, err := url.JoinPath(*rs.baseUrl, rs.identifier, remoteFileName)
+	if err != nil {
+		return err
+	}
+	pterm.Debug.Printfln("Trying to download %s", urlToLoad)
+
+	resp, err := rs.httpClient.Get(urlToLoad)
+	if err != nil {
+		return err
+	}
+	// prevent resource leaks
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("error trying to load %s - wrong status code: %d", urlToLoad, resp.StatusCode)
+	}
+
+	decryptedReader, err := age.Decrypt(io.TeeReader(resp.Body, progressFuncWriter(onProgress)), rs.identity)
+	if err != nil {
+		return err
+	}
+	decompressedReader, err := compression.NewReader(codec, decryptedReader)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = decompressedReader.Close() }()
+
+	workdirFilePath := rs.filepathInWorkDir(localFileName)
+	err = os.MkdirAll(filepath.Dir(workdirFilePath), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(workdirFilePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, decompressedReader)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("error decrypting %s: %w", remoteFileName, err)
+	}
+	return f.Close()
+}
+
 func (rs *ReceiveSession) DumpFileWithProgressBar(fileName string, fileDefinition dto.PublicFilesIndexEntry, progress *pterm.ProgressbarPrinter) error {
 	contents, err := rs.FetchFileWithProgressBar(fileName, fileDefinition, progress)
 	if err != nil {
@@ -329,6 +391,15 @@ func (rs *ReceiveSession) filepathInWorkDir(fileName string) string {
 	return filepath.Join(*rs.workDir, fileName)
 }
 
+// PathInWorkDir returns the local path of a file in the work directory (dump/)
+func (rs *ReceiveSession) PathInWorkDir(fileName string) string {
+	return rs.filepathInWorkDir(fileName)
+}
+
+func (rs *ReceiveSession) WriteFileInWorkDir(fileName string, content []byte) error {
+	return os.WriteFile(rs.filepathInWorkDir(fileName), content, 0600)
+}
+
 func (rs *ReceiveSession) FileContentsInWorkDir(fileName string) ([]byte, error) {
 	return os.ReadFile(rs.filepathInWorkDir(fileName))
 }
@@ -366,3 +437,11 @@ func (w *progressbarWriter) Write(p []byte) (int, error) {
 	w.pb.Add(len(p))
 	return n, nil
 }
+
+// progressFuncWriter calls the function with the number of bytes written to it
+type progressFuncWriter func(n int)
+
+func (w progressFuncWriter) Write(p []byte) (int, error) {
+	w(len(p))
+	return len(p), nil
+}
@@ -1,11 +1,15 @@
 package receive
 
 import (
+	"bytes"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"filippo.io/age"
 	"github.com/pterm/pterm"
 	"github.com/sandstorm/synco/v2/pkg/common/dto"
 )
@@ -121,3 +125,50 @@ func TestFetchFileWithProgressBar_RelativeUri(t *testing.T) {
 		"https://origin.example.com/downloads/css/main.css",
 	)
 }
+
+func TestDumpAndDecryptFileStreamsToWorkDir(t *testing.T) {
+	recipient, err := age.NewScryptRecipient("secret")
+	if err != nil {
+		t.Fatalf("NewScryptRecipient: %v", err)
+	}
+	recipient.SetWorkFactor(10)
+	encrypted := &bytes.Buffer{}
+	w, err := age.Encrypt(encrypted, recipient)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	_, _ = w.Write([]byte("INSERT INTO `orders` VALUES (1);"))
+	_ = w.Close()
+
+	identity, err := age.NewScryptIdentity("secret")
+	if err != nil {
+		t.Fatalf("NewScryptIdentity: %v", err)
+	}
+	transport := &recordingTransport{body: encrypted.String()}
+	rs := newTestReceiveSession("https://example.com/", transport)
+	rs.identity = identity
+	workDir := t.TempDir()
+	rs.workDir = &workDir
+
+	downloaded := 0
+	err = rs.DumpAndDecryptFile("dbDump-orders.sql.enc", filepath.Join("dbDump", "orders.sql"), "", func(n int) {
+		downloaded += n
+	})
+	if err != nil {
+		t.Fatalf("DumpAndDecryptFile: %v", err)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(workDir, "dbDump", "orders.sql"))
+	if err != nil {
+		t.Fatalf("reading dumped file: %v", err)
+	}
+	if string(contents) != "INSERT INTO `orders` VALUES (1);" {
+		t.Errorf("unexpected content: %q", contents)
+	}
+	if downloaded != encrypted.Len() {
+		t.Errorf("progress: got %d bytes, want %d", downloaded, encrypted.Len())
+	}
+	if len(transport.urls) != 1 || transport.urls[0] != "https://example.com/synco-test/dbDump-orders.sql.enc" {
+		t.Errorf("unexpected requests: %v", transport.urls)
+	}
+}
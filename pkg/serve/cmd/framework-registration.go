@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"github.com/sandstorm/synco/v2/pkg/common"
+	"github.com/sandstorm/synco/v2/pkg/frameworks/configServe"
 	"github.com/sandstorm/synco/v2/pkg/frameworks/flowServe"
 	"github.com/sandstorm/synco/v2/pkg/frameworks/laravelServe"
 )
 
 var RegisteredFrameworks = [...]common.ServeFramework{
+	// on equal detection confidence, the framework registered first wins.
+	configServe.NewConfigFramework(),
 	flowServe.NewFlowFramework(),
 	laravelServe.NewLaravel(),
 }
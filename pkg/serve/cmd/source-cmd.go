@@ -1,13 +1,25 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common"
+	"github.com/sandstorm/synco/v2/pkg/common/commonServe"
+	"github.com/sandstorm/synco/v2/pkg/common/config"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
 	"github.com/sandstorm/synco/v2/pkg/serve"
 	"github.com/sandstorm/synco/v2/pkg/util"
+	"github.com/sandstorm/synco/v2/pkg/util/compression"
+	"github.com/sandstorm/synco/v2/pkg/util/mysql"
+	mysqldump "github.com/sandstorm/synco/v2/pkg/util/mysql/go_mysqldump"
 	"github.com/spf13/cobra"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 )
 
 var identifier string
@@ -15,6 +27,17 @@ var password string
 var listen string
 var all bool
 var keep bool
+var frameworkId string
+var appDir string
+var dumpConfig config.SyncoServeDumpConfig
+var compressionCodec string
+var subsetRoots []string
+var schemaOnlyTables []string
+var dataOnlyTables []string
+var skipTables []string
+var limitTables []string
+var sampleTables []string
+var knownChecksums string
 
 var ServeCmd = &cobra.Command{
 	Use:   "serve",
@@ -46,37 +69,270 @@ and figures out what to extract. Depending on the framework, the system might NO
 
 		pterm.PrintOnErrorf("Error initializing progress bar: %e", err)
 
+		if len(appDir) > 0 {
+			if err := os.Chdir(appDir); err != nil {
+				pterm.Fatal.Printfln("Could not change into application directory %s: %s", appDir, err)
+			}
+			pterm.Info.Printfln("Using application directory %s", appDir)
+		}
+
 		pterm.Debug.Printfln("Detecting Frameworks")
+		detectionStart := time.Now()
+		framework, err := selectFramework(RegisteredFrameworks[:], frameworkId)
+		if err != nil {
+			pterm.Error.Printfln("%s", err)
+			os.Exit(1)
+		}
+		if framework == nil {
+			pterm.Error.Printfln("No frameworks could be detected. You can manually configure your application in a %s file (see https://sandstorm.github.io/synco/#/?id=manual-configuration), or choose a framework via --framework. Aborting.", pterm.ThemeDefault.HighlightStyle.Sprint(config.SyncoServeYamlFile))
+			os.Exit(1)
+		}
 
-		for _, framework := range RegisteredFrameworks {
-			pterm.Debug.Printfln("Checking for %s framework", framework.Name())
-			if framework.Detect() {
-				pterm.Info.Printfln("Found %s framework.", framework.Name())
-				transferSession, err := serve.NewSession(identifier, password, listen, all, keep, sigs)
-				if err != nil {
-					pterm.Fatal.Printfln("Error creating transfer session: %s", err)
-				}
+		pterm.Info.Printfln("Using %s framework.", framework.Name())
+		transferSession, err := serve.NewSession(identifier, password, listen, all, keep, sigs)
+		if err != nil {
+			pterm.Fatal.Printfln("Error creating transfer session: %s", err)
+		}
+		transferSession.RecordPhase("framework detection", detectionStart)
+		dumpConfig.Tables = map[string]string{}
+		dumpConfig.Limits = map[string]config.SyncoServeDumpTableLimit{}
+		serveConfig, err := config.ReadServeConfigFromYaml()
+		if err != nil {
+			pterm.Fatal.Printfln("could not read %s: %s", config.SyncoServeYamlFile, err)
+		}
+		if serveConfig != nil {
+			transferSession.AddConfigSource(config.SyncoServeYamlFile)
+			transferSession.WithHooks(serveConfig.Hooks.ByState())
+			// the flags can only enable further objects on top of the config file
+			dumpConfig.Views = dumpConfig.Views || serveConfig.Dump.Views
+			dumpConfig.Triggers = dumpConfig.Triggers || serveConfig.Dump.Triggers
+			dumpConfig.Routines = dumpConfig.Routines || serveConfig.Dump.Routines
+			dumpConfig.Events = dumpConfig.Events || serveConfig.Dump.Events
+			if !cmd.Flags().Changed("parallel") {
+				dumpConfig.Parallelism = serveConfig.Dump.Parallelism
+			}
+			if !cmd.Flags().Changed("compression") {
+				compressionCodec = serveConfig.Compression
+			}
+			dumpConfig.Subset = serveConfig.Dump.Subset
+			for table, mode := range serveConfig.Dump.Tables {
+				dumpConfig.Tables[table] = mode
+			}
+			for table, limit := range serveConfig.Dump.Limits {
+				dumpConfig.Limits[table] = limit
+			}
+			dumpConfig.Checksums = dumpConfig.Checksums || serveConfig.Dump.Checksums
+			dumpConfig.ChunkBlobs = dumpConfig.ChunkBlobs || serveConfig.Dump.ChunkBlobs
+			dumpConfig.RequireSynchronizedSnapshots = dumpConfig.RequireSynchronizedSnapshots || serveConfig.Dump.RequireSynchronizedSnapshots
+			if !cmd.Flags().Changed("max-allowed-packet") {
+				dumpConfig.MaxAllowedPacket = serveConfig.Dump.MaxAllowedPacket
+			}
+			if !cmd.Flags().Changed("db-tls") {
+				dumpConfig.TLS.Mode = serveConfig.Dump.TLS.Mode
+			}
+			if !cmd.Flags().Changed("db-tls-ca") {
+				dumpConfig.TLS.Ca = serveConfig.Dump.TLS.Ca
+			}
+			if !cmd.Flags().Changed("db-tls-server-name") {
+				dumpConfig.TLS.ServerName = serveConfig.Dump.TLS.ServerName
+			}
+			if !cmd.Flags().Changed("db-tls-cert") {
+				dumpConfig.TLS.Cert = serveConfig.Dump.TLS.Cert
+			}
+			if !cmd.Flags().Changed("db-tls-key") {
+				dumpConfig.TLS.Key = serveConfig.Dump.TLS.Key
+			}
+			if !cmd.Flags().Changed("db-ssh") {
+				dumpConfig.SSH.Host = serveConfig.Dump.SSH.Host
+			}
+			if !cmd.Flags().Changed("db-ssh-key") {
+				dumpConfig.SSH.Key = serveConfig.Dump.SSH.Key
+			}
+			if !cmd.Flags().Changed("db-ssh-known-hosts") {
+				dumpConfig.SSH.KnownHosts = serveConfig.Dump.SSH.KnownHosts
+			}
+		}
+		for mode, tables := range map[mysqldump.TableMode][]string{
+			mysqldump.TableModeSchemaOnly: schemaOnlyTables,
+			mysqldump.TableModeDataOnly:   dataOnlyTables,
+			mysqldump.TableModeSkip:       skipTables,
+		} {
+			for _, table := range tables {
+				dumpConfig.Tables[table] = string(mode)
+			}
+		}
+		if err := validateTableModes(dumpConfig.Tables); err != nil {
+			pterm.Fatal.Printfln("%s", err)
+		}
+		if len(dumpConfig.MaxAllowedPacket) > 0 {
+			if _, err := mysqldump.ParsePacketSize(dumpConfig.MaxAllowedPacket); err != nil {
+				pterm.Fatal.Printfln("--max-allowed-packet: %s", err)
+			}
+		}
+		dumpOptions := commonServe.MysqlDumpOptions(dumpConfig)
+		if err := mysql.ValidateTlsConfig(dumpOptions.TLS); err != nil {
+			pterm.Fatal.Printfln("--db-tls: %s", err)
+		}
+		if err := mysql.ValidateSshConfig(dumpOptions.SSH); err != nil {
+			pterm.Fatal.Printfln("--db-ssh: %s", err)
+		}
+		for _, flag := range limitTables {
+			table, limit, err := parseLimitTable(flag)
+			if err != nil {
+				pterm.Fatal.Printfln("%s", err)
+			}
+			dumpConfig.Limits[table] = limit
+		}
+		if len(knownChecksums) > 0 {
+			dumpConfig.KnownChecksums, err = readKnownChecksums(knownChecksums)
+			if err != nil {
+				pterm.Fatal.Printfln("--known-checksums: %s", err)
+			}
+			pterm.Info.Printfln("Incremental dump: tables which did not change since the last import (%d known checksums) are skipped.", len(dumpConfig.KnownChecksums))
+		}
+		for _, flag := range sampleTables {
+			table, limit, err := parseSampleTable(flag)
+			if err != nil {
+				pterm.Fatal.Printfln("%s", err)
+			}
+			dumpConfig.Limits[table] = limit
+		}
+		for _, root := range subsetRoots {
+			dumpConfig.Subset = append(dumpConfig.Subset, parseSubsetRoot(root))
+		}
+		transferSession.DumpConfig = dumpConfig
+		if compressionCodec == "none" {
+			compressionCodec = compression.None
+		}
+		if err := compression.Validate(compressionCodec); err != nil {
+			pterm.Fatal.Printfln("%s", err)
+		}
+		transferSession.Compression = compressionCodec
+		if err := transferSession.RunHooks(dto.STATE_CREATED); err != nil {
+			pterm.Fatal.Printfln("%s", err)
+		}
+
+		framework.Serve(transferSession)
+
+		if keep {
+			// TODO: Maybe offer flag or command to clean up manually -> e.g. synco serve --cleanup or synco cleanup ???
+			// -> however, if you choose to keep the files you are responsible for cleaning up
+			pterm.Debug.Printfln("Running with --keep flag. No automatic cleanup.")
+			os.Exit(0)
+		} else {
+			pterm.Debug.Printfln("Waiting for ctrl-c")
+			// done will never be fired; we'll wait forever here.
+			<-done
+			return
+		}
+	},
+}
+
+// ambiguousConfidenceMargin: if the two most likely frameworks are closer than this, we do not guess; f.e. a
+// directory containing both ./flow and ./artisan.
+const ambiguousConfidenceMargin = 30
 
-				framework.Serve(transferSession)
-
-				if keep {
-					// TODO: Maybe offer flag or command to clean up manually -> e.g. synco serve --cleanup or synco cleanup ???
-					// -> however, if you choose to keep the files you are responsible for cleaning up
-					pterm.Debug.Printfln("Running with --keep flag. No automatic cleanup.")
-					os.Exit(0)
-				} else {
-					pterm.Debug.Printfln("Waiting for ctrl-c")
-					// done will never be fired; we'll wait forever here.
-					<-done
-					return
+// selectFramework returns the framework with the given id (if set), or otherwise the detected framework with
+// the highest confidence. All detected candidates are printed. Returns an error if the two most likely frameworks
+// are too close to decide, and nil if no framework was detected.
+func selectFramework(frameworks []common.ServeFramework, frameworkId string) (common.ServeFramework, error) {
+	if len(frameworkId) > 0 {
+		ids := make([]string, 0, len(frameworks))
+		for _, framework := range frameworks {
+			if framework.Id() == frameworkId {
+				if result := framework.Detect(); !result.Detected() {
+					pterm.Warning.Printfln("%s framework was not detected in the working directory - using it nevertheless, as requested via --framework.", framework.Name())
 				}
+				return framework, nil
 			}
+			ids = append(ids, framework.Id())
 		}
+		return nil, fmt.Errorf("unknown framework %q - supported are: %s", frameworkId, strings.Join(ids, ", "))
+	}
 
-		pterm.Error.Printfln("No frameworks could be detected. Aborting.")
-		//pterm.Error.Printfln("No frameworks could be detected. You can manually create a syncro.yaml config file with %s.", pterm.ThemeDefault.HighlightStyle.Sprint("syncro config init"))
-		os.Exit(1)
-	},
+	var best, runnerUp common.ServeFramework
+	bestConfidence, runnerUpConfidence := 0, 0
+	for _, framework := range frameworks {
+		pterm.Debug.Printfln("Checking for %s framework", framework.Name())
+		result := framework.Detect()
+		if !result.Detected() {
+			continue
+		}
+		pterm.Info.Printfln("Found %s framework (--framework %s, confidence %d: %s)", framework.Name(), framework.Id(), result.Confidence, strings.Join(result.Reasons, ", "))
+		if result.Confidence > bestConfidence {
+			runnerUp, runnerUpConfidence = best, bestConfidence
+			best, bestConfidence = framework, result.Confidence
+		} else if result.Confidence > runnerUpConfidence {
+			runnerUp, runnerUpConfidence = framework, result.Confidence
+		}
+	}
+	if runnerUp != nil && bestConfidence-runnerUpConfidence < ambiguousConfidenceMargin {
+		return nil, fmt.Errorf("both the %s (confidence %d) and the %s (confidence %d) framework were detected - choose one via --framework %s or --framework %s (and --app-dir, if they live in different directories)", best.Name(), bestConfidence, runnerUp.Name(), runnerUpConfidence, best.Id(), runnerUp.Id())
+	}
+	return best, nil
+}
+
+// validateTableModes returns an error for unknown table modes (f.e. typos in .synco-serve.yml)
+func validateTableModes(tableModes map[string]string) error {
+	modes := make([]string, 0)
+	for _, mode := range mysqldump.TableModes() {
+		modes = append(modes, string(mode))
+	}
+	for table, mode := range tableModes {
+		if !slices.Contains(modes, mode) {
+			return fmt.Errorf("unknown mode %q for table %s - supported are: %s", mode, table, strings.Join(modes, ", "))
+		}
+	}
+	return nil
+}
+
+// readKnownChecksums decodes the --known-checksums flag; "@file" reads the value from a file (f.e. a
+// dump/<name>.checksums file copied from the receiving side).
+func readKnownChecksums(flag string) (map[string]string, error) {
+	if fileName, isFile := strings.CutPrefix(flag, "@"); isFile {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		flag = string(content)
+	}
+	return dto.DecodeChecksums(flag)
+}
+
+// parseLimitTable parses the --limit-table flag, f.e. "logs:10000" or "logs:10000:created_at DESC"
+func parseLimitTable(flag string) (string, config.SyncoServeDumpTableLimit, error) {
+	parts := strings.SplitN(flag, ":", 3)
+	if len(parts) < 2 {
+		return "", config.SyncoServeDumpTableLimit{}, fmt.Errorf("--limit-table %q: expected <table>:<rows>[:<order by>]", flag)
+	}
+	rows, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || rows <= 0 {
+		return "", config.SyncoServeDumpTableLimit{}, fmt.Errorf("--limit-table %q: %q is not a positive number of rows", flag, parts[1])
+	}
+	limit := config.SyncoServeDumpTableLimit{Limit: rows}
+	if len(parts) == 3 {
+		limit.OrderBy = strings.TrimSpace(parts[2])
+	}
+	return strings.TrimSpace(parts[0]), limit, nil
+}
+
+// parseSampleTable parses the --sample-table flag, f.e. "events:5" for a random 5% sample
+func parseSampleTable(flag string) (string, config.SyncoServeDumpTableLimit, error) {
+	table, percent, found := strings.Cut(flag, ":")
+	sample, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+	if !found || err != nil || sample <= 0 || sample > 100 {
+		return "", config.SyncoServeDumpTableLimit{}, fmt.Errorf("--sample-table %q: expected <table>:<percent> (0-100)", flag)
+	}
+	return strings.TrimSpace(table), config.SyncoServeDumpTableLimit{Sample: sample}, nil
+}
+
+// parseSubsetRoot parses the --subset flag, f.e. "orders" or "orders:tenant_id = 42"
+func parseSubsetRoot(flag string) config.SyncoServeDumpSubsetRoot {
+	table, where, _ := strings.Cut(flag, ":")
+	return config.SyncoServeDumpSubsetRoot{
+		Table: strings.TrimSpace(table),
+		Where: strings.TrimSpace(where),
+	}
 }
 
 func init() {
@@ -85,4 +341,31 @@ func init() {
 	ServeCmd.Flags().StringVar(&listen, "listen", "", "port to create a HTTP server on, if any")
 	ServeCmd.Flags().BoolVar(&keep, "keep", false, "exit after successful encryption, no automatic cleanup")
 	ServeCmd.Flags().BoolVar(&all, "all", false, "Should dump EVERYTHING? (depending on framework)")
+	ServeCmd.Flags().StringVar(&frameworkId, "framework", "", "skip auto-detection and use the given framework (flow, laravel, config)")
+	ServeCmd.Flags().StringVar(&appDir, "app-dir", "", "directory of the application (default: current directory)")
+	ServeCmd.Flags().BoolVar(&dumpConfig.Views, "dump-views", false, "include views in database dumps")
+	ServeCmd.Flags().BoolVar(&dumpConfig.Triggers, "dump-triggers", false, "include triggers in database dumps")
+	ServeCmd.Flags().BoolVar(&dumpConfig.Routines, "dump-routines", false, "include stored procedures and functions in database dumps")
+	ServeCmd.Flags().BoolVar(&dumpConfig.Events, "dump-events", false, "include scheduled events in database dumps")
+	ServeCmd.Flags().IntVar(&dumpConfig.Parallelism, "parallel", 1, "dump database tables with this many connections in parallel (one file per table)")
+	ServeCmd.Flags().BoolVar(&dumpConfig.RequireSynchronizedSnapshots, "require-synchronized-snapshots", false, "fail a parallel dump if its connections can not share the same snapshot (needs the RELOAD privilege), instead of only warning")
+	ServeCmd.Flags().StringArrayVar(&schemaOnlyTables, "schema-only-table", nil, "only dump the structure of this table, without content (can be repeated, wildcards like cache_* are supported)")
+	ServeCmd.Flags().StringArrayVar(&dataOnlyTables, "data-only-table", nil, "only dump the content of this table, to import it into an existing schema (can be repeated, wildcards supported)")
+	ServeCmd.Flags().StringArrayVar(&skipTables, "skip-table", nil, "do not dump this table at all (can be repeated, wildcards supported)")
+	ServeCmd.Flags().StringArrayVar(&limitTables, "limit-table", nil, "only dump <table>:<rows>[:<order by>], f.e. 'logs:10000:id DESC' for the newest 10000 rows (can be repeated, wildcards supported)")
+	ServeCmd.Flags().StringArrayVar(&sampleTables, "sample-table", nil, "only dump a random sample of <table>:<percent> rows (can be repeated, wildcards supported)")
+	ServeCmd.Flags().BoolVar(&dumpConfig.Checksums, "checksums", false, "compute a checksum of every database table, so that the next dump can be incremental")
+	ServeCmd.Flags().StringVar(&knownChecksums, "known-checksums", "", "only dump tables which changed since the dump with these checksums (as printed by synco receive; @file reads them from a file)")
+	ServeCmd.Flags().StringArrayVar(&subsetRoots, "subset", nil, "only dump the rows of <table>[:<where>] and the rows connected to them via foreign keys (can be repeated)")
+	ServeCmd.Flags().StringVar(&dumpConfig.MaxAllowedPacket, "max-allowed-packet", "", "max_allowed_packet of the target database, f.e. 16M (default: the value of the source database)")
+	ServeCmd.Flags().BoolVar(&dumpConfig.ChunkBlobs, "chunk-blobs", false, "split rows bigger than max_allowed_packet, by appending their BLOBs in chunks")
+	ServeCmd.Flags().StringVar(&dumpConfig.TLS.Mode, "db-tls", "", "TLS of the database connection: disabled, preferred (default), required, verify-ca or verify-full")
+	ServeCmd.Flags().StringVar(&dumpConfig.TLS.Ca, "db-tls-ca", "", "CA certificate (PEM) to verify the database server against, for --db-tls=verify-ca and verify-full (default: the system CAs)")
+	ServeCmd.Flags().StringVar(&dumpConfig.TLS.ServerName, "db-tls-server-name", "", "host name to verify the database certificate against, for --db-tls=verify-full (default: the database host; required for unix sockets)")
+	ServeCmd.Flags().StringVar(&dumpConfig.TLS.Cert, "db-tls-cert", "", "client certificate (PEM) for the database connection")
+	ServeCmd.Flags().StringVar(&dumpConfig.TLS.Key, "db-tls-key", "", "key of the client certificate (PEM)")
+	ServeCmd.Flags().StringVar(&dumpConfig.SSH.Host, "db-ssh", "", "connect to the database through this SSH jump host: [user@]host[:port]")
+	ServeCmd.Flags().StringVar(&dumpConfig.SSH.Key, "db-ssh-key", "", "private key for the SSH jump host (default: the SSH agent)")
+	ServeCmd.Flags().StringVar(&dumpConfig.SSH.KnownHosts, "db-ssh-known-hosts", "", "known_hosts file to verify the SSH jump host against (default: ~/.ssh/known_hosts)")
+	ServeCmd.Flags().StringVar(&compressionCodec, "compression", "none", "compress database dumps and private files before encrypting (none, gzip, zstd) - needs an up to date synco on the receiving side")
 }
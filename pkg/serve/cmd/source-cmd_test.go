@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/sandstorm/synco/v2/pkg/common"
+	"github.com/sandstorm/synco/v2/pkg/serve"
+)
+
+type fakeFramework struct {
+	id         string
+	confidence int
+}
+
+func (f fakeFramework) Id() string   { return f.id }
+func (f fakeFramework) Name() string { return f.id }
+func (f fakeFramework) Detect() common.DetectionResult {
+	if f.confidence == 0 {
+		return common.DetectionResult{}
+	}
+	return common.DetectionResult{Confidence: f.confidence, Reasons: []string{"fake"}}
+}
+func (f fakeFramework) Serve(*serve.TransferSession) {}
+
+func TestSelectFramework(t *testing.T) {
+	frameworks := []common.ServeFramework{
+		fakeFramework{id: "config"},
+		fakeFramework{id: "flow", confidence: 60},
+		fakeFramework{id: "laravel", confidence: 90},
+		fakeFramework{id: "other", confidence: 20},
+	}
+
+	tests := []struct {
+		frameworkId string
+		want        string
+	}{
+		// highest confidence wins
+		{"", "laravel"},
+		{"flow", "flow"},
+		// forcing an undetected framework is allowed
+		{"config", "config"},
+	}
+	for _, tt := range tests {
+		framework, err := selectFramework(frameworks, tt.frameworkId)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.frameworkId, err)
+		}
+		if framework == nil || framework.Id() != tt.want {
+			t.Errorf("%q: got %v, want %s", tt.frameworkId, framework, tt.want)
+		}
+	}
+
+	if _, err := selectFramework(frameworks, "rails"); err == nil {
+		t.Errorf("expected an error for an unknown framework")
+	}
+	if framework, _ := selectFramework([]common.ServeFramework{fakeFramework{id: "flow"}}, ""); framework != nil {
+		t.Errorf("expected no framework, got %v", framework)
+	}
+}
+
+func TestSelectFrameworkRefusesToGuess(t *testing.T) {
+	// f.e. ./flow and ./artisan in the same directory
+	frameworks := []common.ServeFramework{
+		fakeFramework{id: "config"},
+		fakeFramework{id: "flow", confidence: 100},
+		fakeFramework{id: "laravel", confidence: 90},
+	}
+	if framework, err := selectFramework(frameworks, ""); err == nil {
+		t.Errorf("expected an error for ambiguous frameworks, got %v", framework)
+	}
+	// choosing explicitly resolves it
+	if framework, err := selectFramework(frameworks, "laravel"); err != nil || framework.Id() != "laravel" {
+		t.Errorf("got %v, %v; want laravel", framework, err)
+	}
+	// an explicit config file always wins
+	frameworks[0] = fakeFramework{id: "config", confidence: 1000}
+	if framework, err := selectFramework(frameworks, ""); err != nil || framework.Id() != "config" {
+		t.Errorf("got %v, %v; want config", framework, err)
+	}
+}
+
+func TestParseLimitTable(t *testing.T) {
+	table, limit, err := parseLimitTable("logs:10000:created_at DESC, id DESC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table != "logs" || limit.Limit != 10000 || limit.OrderBy != "created_at DESC, id DESC" {
+		t.Errorf("unexpected result %s %+v", table, limit)
+	}
+	if _, _, err := parseLimitTable("logs"); err == nil {
+		t.Errorf("expected an error for a missing row count")
+	}
+	if _, _, err := parseSampleTable("events:150"); err == nil {
+		t.Errorf("expected an error for a sample > 100%%")
+	}
+	if _, limit, _ := parseSampleTable("events:2.5"); limit.Sample != 2.5 {
+		t.Errorf("unexpected sample %+v", limit)
+	}
+}
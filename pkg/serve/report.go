@@ -0,0 +1,82 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+)
+
+// AddConfigSource records where the configuration was read from, for the report
+func (ts *TransferSession) AddConfigSource(source string) {
+	ts.reportMutex.Lock()
+	defer ts.reportMutex.Unlock()
+	if !slices.Contains(ts.configSources, source) {
+		ts.configSources = append(ts.configSources, source)
+	}
+}
+
+// Warn prints a warning, and records it for the report
+func (ts *TransferSession) Warn(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	pterm.Warning.Println(message)
+	ts.AddWarning(message)
+}
+
+// AddWarning records a warning (which was already printed) for the report
+func (ts *TransferSession) AddWarning(message string) {
+	ts.reportMutex.Lock()
+	defer ts.reportMutex.Unlock()
+	ts.warnings = append(ts.warnings, message)
+}
+
+// RecordPhase records how long a step of the session took, for the report; use it as
+// `defer transferSession.RecordPhase("...", time.Now())`.
+func (ts *TransferSession) RecordPhase(name string, start time.Time) {
+	ts.reportMutex.Lock()
+	defer ts.reportMutex.Unlock()
+	ts.phases = append(ts.phases, dto.ReportPhase{
+		Name:            name,
+		DurationSeconds: time.Since(start).Seconds(),
+	})
+}
+
+// Report describes the session so far
+func (ts *TransferSession) Report() *dto.Report {
+	ts.reportMutex.Lock()
+	defer ts.reportMutex.Unlock()
+	report := &dto.Report{
+		Identifier:    ts.Identifier,
+		Framework:     ts.Meta.FrameworkName,
+		ConfigSources: append([]string{}, ts.configSources...),
+		StartedAt:     ts.startedAt,
+		FinishedAt:    time.Now(),
+		FileSets:      []dto.ReportFileSet{},
+		Warnings:      append([]string{}, ts.warnings...),
+		Phases:        append([]dto.ReportPhase{}, ts.phases...),
+	}
+	for _, fileSet := range ts.Meta.FileSets {
+		report.FileSets = append(report.FileSets, dto.NewReportFileSet(fileSet))
+	}
+	return report
+}
+
+// writeReport stores the report as JSON and Markdown (both encrypted) next to the meta data
+func (ts *TransferSession) writeReport() error {
+	report := ts.Report()
+	reportJson, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ts.EncryptBytesToFile(dto.FILENAME_REPORT_JSON, reportJson); err != nil {
+		return err
+	}
+	if err := ts.EncryptBytesToFile(dto.FILENAME_REPORT_MARKDOWN, []byte(report.Markdown())); err != nil {
+		return err
+	}
+	pterm.Info.Printfln("Wrote the session report (encrypted) to %s and %s - view it with: synco inspect --report %s %s", ts.filepathInWorkDir(dto.FILENAME_REPORT_JSON), ts.filepathInWorkDir(dto.FILENAME_REPORT_MARKDOWN), ts.Identifier, ts.Password)
+	return nil
+}
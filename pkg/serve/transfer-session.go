@@ -5,11 +5,16 @@ import (
 	"filippo.io/age"
 	"fmt"
 	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common/config"
 	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/util"
+	"github.com/sandstorm/synco/v2/pkg/util/compression"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
 type TransferSession struct {
@@ -28,6 +33,46 @@ type TransferSession struct {
 	sigs      chan os.Signal
 	DumpAll   bool
 	KeepFiles bool
+	// DumpConfig controls which schema objects (views, triggers, ...) are contained in database dumps
+	DumpConfig config.SyncoServeDumpConfig
+	// Compression is the codec (see package compression) used by CompressAndEncryptToFile; empty for none.
+	Compression string
+
+	// hooks are shell commands which are run when the session enters the given state (before it is persisted)
+	hooks map[dto.State][]string
+	// hookedState is the state whose hooks were run last (even if they failed); used for detecting state changes in
+	// UpdateMetadata, so that the hooks of a state are run only once.
+	hookedState dto.State
+
+	// collected for the report, see Report()
+	reportMutex   sync.Mutex
+	startedAt     time.Time
+	configSources []string
+	warnings      []string
+	phases        []dto.ReportPhase
+}
+
+// WithHooks configures shell commands to be run whenever the session enters the given state. The hooks of
+// STATE_CREATED need to be run via RunHooks, as this state is never persisted.
+func (ts *TransferSession) WithHooks(hooks map[dto.State][]string) {
+	ts.hooks = hooks
+}
+
+// RunHooks runs the hooks configured for the given state; the commands get SYNCO_STATE, SYNCO_FRAMEWORK and
+// SYNCO_WORK_DIR as environment variables.
+func (ts *TransferSession) RunHooks(state dto.State) error {
+	if len(ts.hooks[state]) == 0 {
+		return nil
+	}
+	workDir := ""
+	if ts.WorkDir != nil {
+		workDir = *ts.WorkDir
+	}
+	return util.RunHooks(string(state), ts.hooks[state], []string{
+		"SYNCO_STATE=" + string(state),
+		"SYNCO_FRAMEWORK=" + ts.Meta.FrameworkName,
+		"SYNCO_WORK_DIR=" + workDir,
+	})
 }
 
 func (ts *TransferSession) WithFrameworkAndWebDirectory(frameworkName string, webDirectory string) error {
@@ -76,13 +121,15 @@ func NewSession(identifier string, password string, listen string, all bool, kee
 		Meta: &dto.Meta{
 			State: dto.STATE_CREATED,
 		},
-		Identifier: "synco-" + identifier,
-		Password:   password,
-		DumpAll:    all,
-		KeepFiles:  keep,
-		recipient:  recipient,
-		listen:     listen,
-		sigs:       sigs,
+		Identifier:  "synco-" + identifier,
+		Password:    password,
+		DumpAll:     all,
+		KeepFiles:   keep,
+		recipient:   recipient,
+		listen:      listen,
+		sigs:        sigs,
+		hookedState: dto.STATE_CREATED,
+		startedAt:   time.Now(),
 	}
 
 	go func() {
@@ -98,9 +145,28 @@ func NewSession(identifier string, password string, listen string, all bool, kee
 
 const tempSuffix = ".tmp"
 
+// UpdateMetadata persists the metadata. On a state change, the hooks of the new state are run first; if they fail,
+// the state is NOT persisted, and the hooks are not run again by the next call (the caller should abort).
 func (ts *TransferSession) UpdateMetadata() error {
+	if ts.Meta.State != ts.hookedState {
+		// state change -> hooks run before the new state is visible for the receiving side
+		ts.hookedState = ts.Meta.State
+		if err := ts.RunHooks(ts.Meta.State); err != nil {
+			return err
+		}
+		// the report must exist when the receiving side sees the Ready state
+		if ts.Meta.State == dto.STATE_READY {
+			if err := ts.writeReport(); err != nil {
+				pterm.Warning.Printfln("Could not write the session report: %s", err)
+			}
+		}
+	}
+
 	// first transfer to temporary file, and then rename atomically to prevent race conditions.
 	wc, err := ts.EncryptToFile(dto.FILENAME_META + tempSuffix)
+	if err != nil {
+		return err
+	}
 	encoder := json.NewEncoder(wc)
 	err = encoder.Encode(ts.Meta)
 	if err != nil {
@@ -122,6 +188,9 @@ func (ts *TransferSession) UpdateMetadata() error {
 
 func (ts *TransferSession) EncryptBytesToFile(fileName string, contents []byte) error {
 	wc, err := ts.EncryptToFile(fileName)
+	if err != nil {
+		return err
+	}
 	_, err = wc.Write(contents)
 	if err != nil {
 		return err
@@ -133,11 +202,17 @@ func (ts *TransferSession) EncryptBytesToFile(fileName string, contents []byte)
 	return nil
 }
 
+// RemoveFile removes a (partially written) file from the work directory.
+func (ts *TransferSession) RemoveFile(fileName string) error {
+	return os.Remove(ts.filepathInWorkDir(fileName))
+}
+
 func (ts *TransferSession) filepathInWorkDir(fileName string) string {
 	return filepath.Join(*ts.WorkDir, fileName)
 }
 
-// EncryptFileToFile returns file size of encrypted file (and error) if needed
+// EncryptFileToFile returns file size of encrypted file (and error) if needed. The file is compressed with
+// ts.Compression before encrypting (see CompressAndEncryptToFile).
 func (ts *TransferSession) EncryptFileToFile(srcFileName string, destFileName string) (uint64, error) {
 	file, err := os.Open(srcFileName)
 	if err != nil {
@@ -147,7 +222,10 @@ func (ts *TransferSession) EncryptFileToFile(srcFileName string, destFileName st
 		_ = file.Close()
 	}(file)
 
-	wc, err := ts.EncryptToFile(destFileName)
+	wc, err := ts.CompressAndEncryptToFile(destFileName)
+	if err != nil {
+		return 0, fmt.Errorf("opening target file %s: %w", destFileName, err)
+	}
 
 	if _, err := io.Copy(wc, file); err != nil {
 		return 0, fmt.Errorf("encrypting file (1) %s: %w", srcFileName, err)
@@ -179,6 +257,25 @@ func (ts *TransferSession) EncryptToFile(fileName string) (WriteCloserWithSize,
 	}, nil
 }
 
+// CompressAndEncryptToFile is like EncryptToFile, but compresses the contents with ts.Compression first. Use it
+// for file sets only (and set their Compression field) - the meta data and index files are never compressed,
+// as they need to be readable by all synco versions. Size() returns the compressed size.
+func (ts *TransferSession) CompressAndEncryptToFile(fileName string) (WriteCloserWithSize, error) {
+	wc, err := ts.EncryptToFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if ts.Compression == compression.None {
+		return wc, nil
+	}
+	compressor, err := compression.NewWriter(ts.Compression, wc)
+	if err != nil {
+		_ = wc.Close()
+		return nil, err
+	}
+	return &compressingWriteCloser{compressor: compressor, WriteCloserWithSize: wc}, nil
+}
+
 func (ts *TransferSession) RenderConnectCommand() {
 	pterm.Success.Printfln("READY: Execute the following command on the target system to download the dump:")
 	pterm.Success.Printfln("")
@@ -229,3 +326,21 @@ func (f *ageEncryptWriteCloserHelper) Close() error {
 	// we want to ensure that the target file (f.f) is also closed.
 	return f.f.Close()
 }
+
+// compressingWriteCloser writes through the compressor into the encrypted file
+type compressingWriteCloser struct {
+	WriteCloserWithSize
+	compressor io.WriteCloser
+}
+
+func (c *compressingWriteCloser) Write(p []byte) (int, error) {
+	return c.compressor.Write(p)
+}
+
+func (c *compressingWriteCloser) Close() error {
+	if err := c.compressor.Close(); err != nil {
+		_ = c.WriteCloserWithSize.Close()
+		return err
+	}
+	return c.WriteCloserWithSize.Close()
+}
@@ -2,12 +2,19 @@ package serve
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"filippo.io/age"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/util"
+	"github.com/sandstorm/synco/v2/pkg/util/compression"
 )
 
 // newTestSession builds a TransferSession that writes into a fresh temp dir,
@@ -80,3 +87,135 @@ func TestEncryptToFileTruncatesExistingFile(t *testing.T) {
 		t.Fatalf("decrypted content mismatch after overwrite:\n got: %q\nwant: %q", got, short)
 	}
 }
+
+func TestHooksRunOnceWhenEnteringAState(t *testing.T) {
+	util.ResetCommandMocks()
+	defer util.ResetCommandMocks()
+	util.RegisterCommandMock("sh -c ./maintenance.sh on", "")
+	util.RegisterCommandMock("sh -c ./maintenance.sh off", "")
+
+	ts := newTestSession(t, "hook-password")
+	ts.Meta = &dto.Meta{State: dto.STATE_CREATED}
+	ts.hookedState = dto.STATE_CREATED
+	ts.WithHooks(map[dto.State][]string{
+		dto.STATE_INITIALIZING: {"./maintenance.sh on"},
+		dto.STATE_READY:        {"./maintenance.sh off"},
+	})
+
+	ts.Meta.State = dto.STATE_INITIALIZING
+	for i := 0; i < 3; i++ {
+		// f.e. after every added file set
+		if err := ts.UpdateMetadata(); err != nil {
+			t.Fatalf("UpdateMetadata: %v", err)
+		}
+	}
+	ts.Meta.State = dto.STATE_READY
+	if err := ts.UpdateMetadata(); err != nil {
+		t.Fatalf("UpdateMetadata: %v", err)
+	}
+
+	want := []string{"sh -c ./maintenance.sh on", "sh -c ./maintenance.sh off"}
+	if got := util.CommandMockCalls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got hook calls %v, want %v", got, want)
+	}
+}
+
+func TestFailingHookPreventsStateChange(t *testing.T) {
+	ts := newTestSession(t, "hook-password")
+	ts.Meta = &dto.Meta{State: dto.STATE_INITIALIZING}
+	ts.WithHooks(map[dto.State][]string{
+		dto.STATE_INITIALIZING: {"exit 3"},
+	})
+
+	if err := ts.UpdateMetadata(); err == nil {
+		t.Fatalf("expected the failing hook to fail UpdateMetadata")
+	}
+	if _, err := os.Stat(filepath.Join(*ts.WorkDir, dto.FILENAME_META)); !os.IsNotExist(err) {
+		t.Errorf("expected no metadata to be written, got %v", err)
+	}
+
+	// a retry does not run the hooks (f.e. maintenance mode commands) again; the mock would record the call
+	util.ResetCommandMocks()
+	defer util.ResetCommandMocks()
+	util.RegisterCommandMock("sh -c exit 3", "")
+	if err := ts.UpdateMetadata(); err != nil {
+		t.Fatalf("UpdateMetadata: %v", err)
+	}
+	if got := util.CommandMockCalls(); len(got) != 0 {
+		t.Errorf("expected no hook calls on retry, got %v", got)
+	}
+}
+
+func TestCompressAndEncryptToFile(t *testing.T) {
+	ts := newTestSession(t, "secret")
+	ts.Compression = compression.Zstd
+	payload := bytes.Repeat([]byte("INSERT INTO `orders` VALUES (1);\n"), 1000)
+
+	wc, err := ts.CompressAndEncryptToFile("dump.sql.enc")
+	if err != nil {
+		t.Fatalf("CompressAndEncryptToFile: %v", err)
+	}
+	if _, err := wc.Write(payload); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	if err := wc.Close(); err != nil {
+		t.Fatalf("closing: %v", err)
+	}
+	if wc.Size() == 0 || wc.Size() >= uint64(len(payload)) {
+		t.Errorf("Size() should be the compressed size, got %d for %d bytes", wc.Size(), len(payload))
+	}
+
+	r, err := compression.NewReader(compression.Zstd, bytes.NewReader(decryptFile(t, ts, "dump.sql.enc")))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompressing: %v", err)
+	}
+	if !bytes.Equal(decompressed, payload) {
+		t.Errorf("round trip changed the content")
+	}
+}
+
+func TestReportIsWrittenWhenReady(t *testing.T) {
+	ts := newTestSession(t, "report-password")
+	ts.Meta = &dto.Meta{State: dto.STATE_INITIALIZING, FrameworkName: "Neos/Flow"}
+	ts.AddConfigSource("./flow configuration:show")
+	ts.AddConfigSource("./flow configuration:show")
+	ts.AddWarning("Could NOT evaluate symlinks (skipping): Web/_Resources/broken")
+	ts.RecordPhase("database dump dbDump", time.Now())
+	ts.Meta.FileSets = append(ts.Meta.FileSets, &dto.FileSet{
+		Name:        "Resources",
+		Type:        dto.TYPE_PUBLICFILES,
+		PublicFiles: &dto.FileSetPublicFiles{SizeBytes: 2048, FileCount: 2},
+	})
+	if err := ts.UpdateMetadata(); err != nil {
+		t.Fatalf("UpdateMetadata: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(*ts.WorkDir, dto.FILENAME_REPORT_JSON)); !os.IsNotExist(err) {
+		t.Fatalf("the report must only be written when the session is ready, got %v", err)
+	}
+
+	ts.Meta.State = dto.STATE_READY
+	if err := ts.UpdateMetadata(); err != nil {
+		t.Fatalf("UpdateMetadata: %v", err)
+	}
+	var report dto.Report
+	if err := json.Unmarshal(decryptFile(t, ts, dto.FILENAME_REPORT_JSON), &report); err != nil {
+		t.Fatalf("decoding report: %v", err)
+	}
+	if !reflect.DeepEqual(report.ConfigSources, []string{"./flow configuration:show"}) {
+		t.Errorf("got config sources %v", report.ConfigSources)
+	}
+	if len(report.Warnings) != 1 || len(report.Phases) != 1 || report.Phases[0].Name != "database dump dbDump" {
+		t.Errorf("got warnings %v and phases %v", report.Warnings, report.Phases)
+	}
+	wantFileSets := []dto.ReportFileSet{{Name: "Resources", Type: dto.TYPE_PUBLICFILES, Files: 2, SizeBytes: 2048}}
+	if !reflect.DeepEqual(report.FileSets, wantFileSets) {
+		t.Errorf("got file sets %+v, want %+v", report.FileSets, wantFileSets)
+	}
+	if markdown := string(decryptFile(t, ts, dto.FILENAME_REPORT_MARKDOWN)); !strings.Contains(markdown, "| Resources | PublicFiles | 2 | 2.0 KiB |  |") {
+		t.Errorf("unexpected markdown report:\n%s", markdown)
+	}
+}
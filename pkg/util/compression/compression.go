@@ -0,0 +1,73 @@
+package compression
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codecs which can be used for compressing files before they are encrypted; the codec is stored in the
+// compression field of the file set.
+const (
+	None = ""
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// Codecs returns all supported codecs (except None)
+func Codecs() []string {
+	return []string{Gzip, Zstd}
+}
+
+// Validate returns an error if the codec is not supported by this version of synco
+func Validate(codec string) error {
+	switch codec {
+	case None, Gzip, Zstd:
+		return nil
+	default:
+		return fmt.Errorf("unknown compression %q (supported: %s) - if the file was created by a newer synco version, please update synco", codec, strings.Join(Codecs(), ", "))
+	}
+}
+
+// NewWriter compresses everything written to the returned writer into w. Close does not close w.
+func NewWriter(codec string, w io.Writer) (io.WriteCloser, error) {
+	switch codec {
+	case None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, Validate(codec)
+	}
+}
+
+// NewReader decompresses r.
+func NewReader(codec string, r io.Reader) (io.ReadCloser, error) {
+	switch codec {
+	case None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, Validate(codec)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	input := strings.Repeat("INSERT INTO `orders` VALUES (1,'compressible');\n", 1000)
+	for _, codec := range []string{None, Gzip, Zstd} {
+		var compressed bytes.Buffer
+		w, err := NewWriter(codec, &compressed)
+		if err != nil {
+			t.Fatalf("%q: NewWriter: %v", codec, err)
+		}
+		if _, err := io.WriteString(w, input); err != nil {
+			t.Fatalf("%q: Write: %v", codec, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%q: Close: %v", codec, err)
+		}
+		if codec != None && compressed.Len() >= len(input)/10 {
+			t.Errorf("%q: expected at least 10x compression, got %d bytes for %d", codec, compressed.Len(), len(input))
+		}
+
+		r, err := NewReader(codec, &compressed)
+		if err != nil {
+			t.Fatalf("%q: NewReader: %v", codec, err)
+		}
+		output, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%q: ReadAll: %v", codec, err)
+		}
+		_ = r.Close()
+		if string(output) != input {
+			t.Errorf("%q: round trip changed the content", codec)
+		}
+	}
+}
+
+func TestUnknownCodecFailsClearly(t *testing.T) {
+	_, err := NewReader("brotli", strings.NewReader(""))
+	if err == nil || !strings.Contains(err.Error(), "please update synco") {
+		t.Errorf("expected a clear error for an unknown codec, got %v", err)
+	}
+	if _, err := NewWriter("brotli", io.Discard); err == nil {
+		t.Errorf("expected an error for an unknown codec")
+	}
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadDotenvFile parses a .env file as used by Laravel, Symfony and many Node projects:
+//
+//	# comment
+//	KEY=value
+//	export KEY="quoted value"
+//	KEY='single quoted value'
+//
+// Variable interpolation (${OTHER}) is not supported.
+func ReadDotenvFile(fileName string) (map[string]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("opening dotenv file %s: %w", fileName, err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		values[strings.TrimSpace(key)] = parseDotenvValue(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading dotenv file %s: %w", fileName, err)
+	}
+	return values, nil
+}
+
+func parseDotenvValue(value string) string {
+	if len(value) >= 2 && value[0] == '"' {
+		if end := strings.LastIndex(value, "\""); end > 0 {
+			return strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value[1:end])
+		}
+	}
+	if len(value) >= 2 && value[0] == '\'' {
+		if end := strings.LastIndex(value, "'"); end > 0 {
+			return value[1:end]
+		}
+	}
+	// unquoted values may have a trailing comment
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+// RunHooks executes the given shell commands one after another via `sh -c`; env contains additional
+// environment variables (KEY=value). Stops at the first failing command.
+func RunHooks(hookName string, commands []string, env []string) error {
+	for _, command := range commands {
+		pterm.Info.Printfln("Running %s hook: %s", hookName, command)
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(os.Environ(), env...)
+		output, errorOutput, err := RunWrappedCommand(cmd)
+		if len(strings.TrimSpace(output)) > 0 {
+			pterm.Info.Printfln("%s", strings.TrimSpace(output))
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook %q failed: %w - %s", hookName, command, err, strings.TrimSpace(errorOutput))
+		}
+	}
+	return nil
+}
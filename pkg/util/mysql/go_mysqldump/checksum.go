@@ -0,0 +1,99 @@
+package go_mysqldump
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tableChecksum returns a checksum of the structure and the content of the table, as it would be dumped (with the
+// where clause and limit of the table). It is computed in the database (inside the dump transaction) with an order
+// independent hash of all rows, so that no data has to be transferred for it.
+//
+// Returns "" for tables which can not be compared (random samples are different every time).
+func (data *Data) tableChecksum(name string) (string, error) {
+	table := data.createTable(name)
+	if table.Mode == TableModeSkip || (table.Limit.SamplePercent > 0 && table.Limit.SamplePercent < 100) {
+		return "", nil
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "mode %s\n", table.Mode)
+	createSQL, err := table.CreateSQL()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(hash, "%s\n", createSQL)
+
+	if table.Mode != TableModeSchemaOnly {
+		if err := table.initColumnData(); err != nil {
+			return "", err
+		}
+		var count, rowHash uint64
+		if len(table.cols) > 0 {
+			if err := data.tx.QueryRow(table.checksumQuery()).Scan(&count, &rowHash); err != nil {
+				return "", fmt.Errorf("could not compute checksum of %s: %w", name, err)
+			}
+		}
+		fmt.Fprintf(hash, "%d %d\n", count, rowHash)
+		data.results.setRowHash(name, strconv.FormatUint(rowHash, 10))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+// checksumQuery returns the number of rows and the sum (mod 2^64) of a hash of every row. Every value is hashed with
+// its length as prefix ("3:abc"; "N" for NULL), so that f.e. ('a#b', 'c') and ('a', 'b#c') are different rows. The
+// sum is order independent like the XOR of pt-table-checksum, but identical rows do not cancel each other out.
+func (table *table) checksumQuery() string {
+	values := make([]string, 0, len(table.cols))
+	for _, col := range table.cols {
+		values = append(values, "COALESCE(CONCAT(LENGTH(`"+col+"`), ':', `"+col+"`), 'N')")
+	}
+	rowHash := "CAST(CONV(SUBSTRING(SHA1(CONCAT(" + strings.Join(values, ", ") + ")), 1, 16), 16, 10) AS UNSIGNED)"
+	// SUM of BIGINT UNSIGNED is a DECIMAL, so it does not overflow
+	return "SELECT COUNT(*), COALESCE(CAST(MOD(SUM(" + rowHash + "), 18446744073709551616) AS UNSIGNED), 0) FROM (" + table.selectQuery() + ") AS synco_checksum"
+}
+
+// checkUnchanged computes the checksum of the table (if Checksums is set), and returns true if it matches the
+// checksum in KnownChecksums - then, the table does not need to be dumped.
+func (data *Data) checkUnchanged(name string) (bool, error) {
+	if !data.Checksums {
+		return false, nil
+	}
+	checksum, err := data.tableChecksum(name)
+	if err != nil || len(checksum) == 0 {
+		return false, err
+	}
+
+	data.results.mutex.Lock()
+	defer data.results.mutex.Unlock()
+	data.results.checksums[name] = checksum
+	if data.KnownChecksums[name] == checksum {
+		data.results.unchanged = append(data.results.unchanged, name)
+		// not contained in the dump
+		delete(data.results.stats, name)
+		return true, nil
+	}
+	return false, nil
+}
+
+// TableChecksums returns the checksums of all tables after Dump() (only if Checksums is set)
+func (data *Data) TableChecksums() map[string]string {
+	if data.results == nil {
+		return nil
+	}
+	return data.results.checksums
+}
+
+// UnchangedTables returns the tables which were not dumped, because they match KnownChecksums (sorted)
+func (data *Data) UnchangedTables() []string {
+	if data.results == nil {
+		return nil
+	}
+	result := append([]string{}, data.results.unchanged...)
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,98 @@
+package go_mysqldump
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockChecksum(mock sqlmock.Sqlmock, rowHash uint64) {
+	mock.ExpectQuery("^SHOW CREATE TABLE `orders`$").WillReturnRows(sqlmock.NewRows([]string{"Table", "Create Table"}).
+		AddRow("orders", "CREATE TABLE `orders` (`id` int(11))"))
+	mock.ExpectQuery("^SHOW COLUMNS FROM `orders`$").WillReturnRows(sqlmock.NewRows([]string{"Field", "Extra"}).
+		AddRow("id", "").
+		AddRow("note", ""))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT COUNT(*), COALESCE(CAST(MOD(SUM(CAST(CONV(SUBSTRING(SHA1(CONCAT(COALESCE(CONCAT(LENGTH(`id`), ':', `id`), 'N'), COALESCE(CONCAT(LENGTH(`note`), ':', `note`), 'N'))), 1, 16), 16, 10) AS UNSIGNED)), 18446744073709551616) AS UNSIGNED), 0) FROM (SELECT `id`, `note` FROM `orders` WHERE TRUE) AS synco_checksum") + "$").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "hash"}).AddRow(2, rowHash))
+}
+
+func TestCheckUnchangedComparesWithKnownChecksums(t *testing.T) {
+	data, mock, err := getMockData()
+	assert.NoError(t, err)
+	defer data.Close()
+	data.Checksums = true
+	data.results = newDumpResults()
+
+	mockChecksum(mock, 12345)
+	unchanged, err := data.checkUnchanged("orders")
+	assert.NoError(t, err)
+	assert.False(t, unchanged, "nothing is known yet")
+	checksum := data.TableChecksums()["orders"]
+	assert.Len(t, checksum, 16)
+
+	data.KnownChecksums = map[string]string{"orders": checksum}
+	mockChecksum(mock, 12345)
+	unchanged, err = data.checkUnchanged("orders")
+	assert.NoError(t, err)
+	assert.True(t, unchanged)
+	assert.Equal(t, []string{"orders"}, data.UnchangedTables())
+
+	mockChecksum(mock, 54321)
+	unchanged, err = data.checkUnchanged("orders")
+	assert.NoError(t, err)
+	assert.False(t, unchanged, "a changed row leads to a different checksum")
+	assert.NotEqual(t, checksum, data.TableChecksums()["orders"])
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSampledTablesHaveNoChecksum(t *testing.T) {
+	data := &Data{TableLimits: map[string]TableLimit{"events": {SamplePercent: 10}}}
+	checksum, err := data.tableChecksum("events")
+	assert.NoError(t, err)
+	assert.Empty(t, checksum)
+}
+
+// rowHashModel computes what checksumQuery computes in the database for a single row (nil for NULL)
+func rowHashModel(values ...*string) uint64 {
+	var row strings.Builder
+	for _, value := range values {
+		if value == nil {
+			row.WriteString("N")
+			continue
+		}
+		fmt.Fprintf(&row, "%d:%s", len(*value), *value)
+	}
+	sum := sha1.Sum([]byte(row.String()))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func TestChecksumQueryHashesValuesUnambiguously(t *testing.T) {
+	table := &table{Name: "orders", cols: []string{"a", "b"}, WhereClause: "TRUE"}
+	assert.Contains(t, table.checksumQuery(), "CONCAT(COALESCE(CONCAT(LENGTH(`a`), ':', `a`), 'N'), COALESCE(CONCAT(LENGTH(`b`), ':', `b`), 'N'))")
+
+	str := func(s string) *string { return &s }
+	// with CONCAT_WS('#', ...), both rows were "a#b#c"
+	assert.NotEqual(t, rowHashModel(str("a#b"), str("c")), rowHashModel(str("a"), str("b#c")))
+	assert.NotEqual(t, rowHashModel(str(""), nil), rowHashModel(nil, str("")))
+	assert.NotEqual(t, rowHashModel(str("N"), str("")), rowHashModel(nil, str("")))
+}
+
+func TestChecksumQueryDoesNotCancelIdenticalRows(t *testing.T) {
+	table := &table{Name: "orders", cols: []string{"a"}, WhereClause: "TRUE"}
+	assert.Contains(t, table.checksumQuery(), "COALESCE(CAST(MOD(SUM(")
+	assert.NotContains(t, table.checksumQuery(), "BIT_XOR")
+
+	str := func(s string) *string { return &s }
+	row := rowHashModel(str("duplicate"))
+	other := rowHashModel(str("other"))
+	// with BIT_XOR, two identical rows cancel each other out: {other} and {other, row, row} had the same hash
+	assert.Equal(t, other, other^row^row)
+	assert.NotEqual(t, other, other+row+row, "the sum (mod 2^64) keeps identical rows")
+}
@@ -8,7 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -20,24 +23,132 @@ Data struct to configure dump behavior
 	Out:              Stream to wite to
 	Connection:       Database connection to dump
 	IgnoreTables:     Mark sensitive tables to ignore
-	WhereClauseForTables:     Mark sensitive tables to not dump the content for
-	MaxAllowedPacket: Sets the largest packet size to use in backups
+	WhereClauseForTables:     Mark sensitive tables to not dump the content for; keys can contain
+	                          wildcards (f.e. cr_*_p_*), an exact table name takes precedence
+	TableModes:       What to dump per table (see TableMode); keys can contain wildcards like WhereClauseForTables
+	TableLimits:      Only dump some rows of a table (see TableLimit); keys can contain wildcards. The sampled tables,
+	                  and the tables with more rows than their limit, are listed in the dump header. Not applied to
+	                  tables restricted by Subset.
+	MaxAllowedPacket: The max_allowed_packet of the target database; no statement of the dump is bigger (except rows
+	                  which can not be split, see ChunkBlobs). 0 uses @@max_allowed_packet of the source database.
+	                  Extended inserts are at most 4 MiB; bigger rows are inserted one by one.
+	ChunkBlobs:       Split rows bigger than MaxAllowedPacket: their BLOBs are inserted empty, and appended in chunks
+	                  via UPDATE ... CONCAT (needs a primary key)
 	LockTables:       Lock all tables for the duration of the dump
+	KeepTransaction:  Keep the read only transaction open after Dump(), so that further queries (see Tx())
+	                  see exactly the same state as the dump. The caller must call Rollback() afterwards.
+	DumpViews:        Dump views (ordered by their dependencies)
+	DumpTriggers:     Dump the triggers of every table (after its content)
+	DumpRoutines:     Dump stored procedures and functions
+	DumpEvents:       Dump scheduled events
+	Parallelism:      If > 1 (and OpenTableOut is set), dump the tables concurrently with this many connections; every
+	                  table is written to its own stream from OpenTableOut. Out then only contains the schema objects
+	                  (triggers, routines, views, events), which must be imported after the tables.
+	OpenTableOut:     Opens the stream for a single table (only used with Parallelism)
+	RequireSynchronizedSnapshots: Fail the parallel dump if the connections can not share the same snapshot
+	                  (see SnapshotsSynchronized), instead of dumping the tables from slightly different points in time.
+	Checksums:        Compute a checksum of every table (inside the dump transaction), see TableChecksums()
+	KnownChecksums:   Checksums of a previous dump (by table name); tables with the same checksum are not dumped at
+	                  all (see UnchangedTables()), so that only the changed tables have to be imported again.
+	Subset:           Only dump the rows selected by these roots, plus the rows connected to them via foreign keys
+	                  (see computeSubset); the where clauses of the connected tables are combined with
+	                  WhereClauseForTables via AND.
+	The DEFINER of views, triggers, routines and events is removed, so that they can be imported by any user.
 */
 type Data struct {
 	Out                  io.Writer
 	Connection           *sql.DB
 	IgnoreTables         []string
 	WhereClauseForTables map[string]string
+	TableModes           map[string]TableMode
+	TableLimits          map[string]TableLimit
 	MaxAllowedPacket     int
+	ChunkBlobs           bool
 	LockTables           bool
+	KeepTransaction      bool
+	DumpViews            bool
+	DumpTriggers         bool
+	DumpRoutines         bool
+	DumpEvents           bool
+	Parallelism          int
+	OpenTableOut         func(tableName string) (io.WriteCloser, error)
+	// RequireSynchronizedSnapshots: see above
+	RequireSynchronizedSnapshots bool
+	Subset                       []SubsetRoot
+	Checksums                    bool
+	KnownChecksums               map[string]string
+	// SnapshotsSynchronized is set after a parallel Dump(): false if the connections could not be synchronized
+	// via FLUSH TABLES WITH READ LOCK; then, the tables might come from (slightly) different points in time.
+	SnapshotsSynchronized bool
+	// ServerVersion is set after Dump(), f.e. "8.4.2" or "11.4.3-MariaDB"
+	ServerVersion string
 
 	tx                 *sql.Tx
 	headerTmpl         *template.Template
 	tableStructureTmpl *template.Template
 	tableContentTmpl   *template.Template
+	tableDataOnlyTmpl  *template.Template
+	viewTmpl           *template.Template
+	routineTmpl        *template.Template
 	footerTmpl         *template.Template
 	err                error
+	// triggers contains the trigger names by table name; only filled if DumpTriggers is set.
+	triggers map[string][]string
+	results  *dumpResults
+	// subsetWhereClauses contains the where clauses computed for Subset, by table name
+	subsetWhereClauses map[string]string
+}
+
+// TableMode controls what is dumped of a table
+type TableMode string
+
+const (
+	// TableModeFull dumps the table structure (DROP + CREATE) and the content; this is the default.
+	TableModeFull TableMode = "full"
+	// TableModeSchemaOnly only dumps the table structure, f.e. for big tables which are not needed (event log)
+	TableModeSchemaOnly TableMode = "schema-only"
+	// TableModeDataOnly deletes the existing rows and inserts the content, without touching the table structure -
+	// for importing into an already migrated schema.
+	TableModeDataOnly TableMode = "data-only"
+	// TableModeSkip does not dump the table at all (like IgnoreTables)
+	TableModeSkip TableMode = "skip"
+)
+
+// TableModes returns all valid table modes
+func TableModes() []TableMode {
+	return []TableMode{TableModeFull, TableModeSchemaOnly, TableModeDataOnly, TableModeSkip}
+}
+
+// TableLimit restricts the rows of a table, f.e. the newest 10000 rows of a log table:
+//
+//	TableLimit{OrderBy: "id DESC", Limit: 10000}
+type TableLimit struct {
+	OrderBy string
+	// Limit is the maximum number of rows; 0 for no limit
+	Limit int
+	// SamplePercent > 0 selects a random sample of roughly this percentage of the rows
+	SamplePercent float64
+}
+
+// IsRestricted returns true if the limit actually excludes rows
+func (l TableLimit) IsRestricted() bool {
+	return l.Limit > 0 || (l.SamplePercent > 0 && l.SamplePercent < 100)
+}
+
+// String describes the limit for humans, f.e. "at most 10000 rows (ORDER BY id DESC)"
+func (l TableLimit) String() string {
+	parts := make([]string, 0)
+	if l.SamplePercent > 0 && l.SamplePercent < 100 {
+		parts = append(parts, fmt.Sprintf("random sample of %s%% of the rows", strconv.FormatFloat(l.SamplePercent, 'f', -1, 64)))
+	}
+	if l.Limit > 0 {
+		parts = append(parts, fmt.Sprintf("at most %d rows", l.Limit))
+	}
+	result := strings.Join(parts, ", ")
+	if len(l.OrderBy) > 0 && l.Limit > 0 {
+		result += " (ORDER BY " + l.OrderBy + ")"
+	}
+	return result
 }
 
 type table struct {
@@ -49,12 +160,28 @@ type table struct {
 	rows        *sql.Rows
 	values      []interface{}
 	WhereClause string
+	Mode        TableMode
+	Limit       TableLimit
+	// rowCount is the number of rows written by Stream()
+	rowCount int64
+	// oversizedRows is the number of rows bigger than MaxAllowedPacket, which could not be split
+	oversizedRows int64
+	// onUpdateCols are the columns with ON UPDATE CURRENT_TIMESTAMP
+	onUpdateCols []string
+	// primaryKey is only loaded with ChunkBlobs
+	primaryKey []string
+	// chunkable are the columns (by index) which can be appended in chunks (BLOB and BINARY types)
+	chunkable []bool
 }
 
 type metaData struct {
 	DumpVersion   string
 	ServerVersion string
 	CompleteTime  string
+	// LimitedTables describes the tables which are not dumped completely (see TableLimits)
+	LimitedTables []string
+	// Tables are the stats of the tables in this file, written to the footer
+	Tables []TableStats
 }
 
 const (
@@ -69,6 +196,13 @@ const headerTmpl = `-- Go SQL Dump {{ .DumpVersion }}
 --
 -- ------------------------------------------------------
 -- Server version	{{ .ServerVersion }}
+{{- if .LimitedTables }}
+--
+-- NOT ALL ROWS of the following tables are contained in this dump:
+{{- range .LimitedTables }}
+--   {{ . }}
+{{- end }}
+{{- end }}
 
 /*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
 /*!40101 SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS */;
@@ -92,6 +226,13 @@ const footerTmpl = `/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
 /*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;
 /*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;
 /*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;
+{{- if .Tables }}
+
+-- Rows and checksums of the dumped tables, see "synco verify-dump":
+{{- range .Tables }}
+-- verify ` + "`" + `{{ .Name }}` + "`" + ` rows={{ .Rows }}{{ if .RowHash }} hash={{ .RowHash }}{{ end }}
+{{- end }}
+{{- end }}
 
 -- Dump completed on {{ .CompleteTime }}
 `
@@ -109,11 +250,20 @@ DROP TABLE IF EXISTS {{ .NameEsc }};
 /*!40101 SET character_set_client = @saved_cs_client */;
 `
 
+// Takes a *table; the structure of a data-only table is kept, only its rows are replaced
+const tableDataOnlyTmpl = `
+--
+-- Replacing data of table {{ .NameEsc }} (data only, the table structure is not changed)
+--
+
+DELETE FROM {{ .NameEsc }};
+`
+
 // Takes a *table
 const tableContentTmpl = `
 
 --
--- Dumping data for table {{ .NameEsc }}
+-- Dumping data for table {{ .NameEsc }}{{ if .Limit.IsRestricted }} - {{ .Limit }}{{ end }}
 --
 
 LOCK TABLES {{ .NameEsc }} WRITE;
@@ -128,15 +278,11 @@ UNLOCK TABLES;
 const nullType = "NULL"
 
 // Dump data using struct
-func (data *Data) Dump() error {
+func (data *Data) Dump() (err error) {
 	meta := metaData{
 		DumpVersion: Version,
 	}
 
-	if data.MaxAllowedPacket == 0 {
-		data.MaxAllowedPacket = defaultMaxAllowedPacket
-	}
-
 	if err := data.getTemplates(); err != nil {
 		return err
 	}
@@ -147,18 +293,54 @@ func (data *Data) Dump() error {
 	if err := data.begin(); err != nil {
 		return err
 	}
-	defer data.rollback()
+	defer func() {
+		if err != nil || !data.KeepTransaction {
+			data.rollback()
+		}
+	}()
 
 	if err := meta.updateServerVersion(data); err != nil {
 		return err
 	}
+	data.ServerVersion = meta.ServerVersion
+	if data.MaxAllowedPacket == 0 {
+		data.MaxAllowedPacket = data.sourceMaxAllowedPacket()
+	}
 
-	if err := data.headerTmpl.Execute(data.Out, meta); err != nil {
+	tables, views, err := data.getTablesAndViews()
+	if err != nil {
+		return err
+	}
+	if data.DumpTriggers {
+		if data.triggers, err = data.getTriggers(); err != nil {
+			return err
+		}
+	}
+	// the snapshots must be synchronized before the first read from a table (subset, row limits), as this
+	// already establishes the snapshot of the main transaction
+	var workerTxs []*sql.Tx
+	if data.isParallel() && len(tables) > 0 {
+		workerTxs, err = data.beginSynchronizedSnapshots(min(data.Parallelism, len(tables)), tables[0])
+		defer func() {
+			for _, tx := range workerTxs {
+				_ = tx.Rollback()
+			}
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	if len(data.Subset) > 0 {
+		if data.subsetWhereClauses, tables, err = data.computeSubset(tables); err != nil {
+			return err
+		}
+	}
+	if meta.LimitedTables, err = data.describeLimitedTables(tables); err != nil {
 		return err
 	}
+	data.results = newDumpResults()
 
-	tables, err := data.getTables()
-	if err != nil {
+	if err := data.headerTmpl.Execute(data.Out, meta); err != nil {
 		return err
 	}
 
@@ -180,15 +362,61 @@ func (data *Data) Dump() error {
 		defer data.Connection.Exec("UNLOCK TABLES")
 	}
 
-	for _, name := range tables {
-		if err := data.dumpTable(name); err != nil {
+	if data.isParallel() {
+		if err := data.dumpTablesParallel(tables, workerTxs, meta); err != nil {
 			return err
 		}
+		// the triggers are created after ALL tables were imported
+		unchangedTables := data.UnchangedTables()
+		for _, name := range tables {
+			if containsString(unchangedTables, name) {
+				continue
+			}
+			if err := data.dumpTriggers(name); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, name := range tables {
+			if unchanged, err := data.checkUnchanged(name); err != nil {
+				return err
+			} else if unchanged {
+				fmt.Fprintf(data.Out, "\n--\n-- Table `%s` is unchanged (same checksum as the known dump), so it is not contained in this dump\n--\n", name)
+				continue
+			}
+			if err := data.dumpTable(name); err != nil {
+				return err
+			}
+			if err := data.dumpTriggers(name); err != nil {
+				return err
+			}
+		}
 	}
 	if data.err != nil {
 		return data.err
 	}
 
+	// routines before views, as views can call stored functions
+	if data.DumpRoutines {
+		if err := data.dumpRoutines(); err != nil {
+			return err
+		}
+	}
+	if data.DumpViews {
+		if err := data.dumpViews(views); err != nil {
+			return err
+		}
+	}
+	if data.DumpEvents {
+		if err := data.dumpEvents(); err != nil {
+			return err
+		}
+	}
+
+	if !data.isParallel() {
+		// with Parallelism, the stats are in the footer of the table streams
+		meta.Tables = data.TableStats()
+	}
 	meta.CompleteTime = time.Now().String()
 	return data.footerTmpl.Execute(data.Out, meta)
 }
@@ -210,6 +438,19 @@ func (data *Data) rollback() error {
 	return data.tx.Rollback()
 }
 
+// Tx returns the read only transaction of the dump; only usable after Dump() with KeepTransaction set.
+func (data *Data) Tx() *sql.Tx {
+	return data.tx
+}
+
+// Rollback ends the transaction which was kept open via KeepTransaction.
+func (data *Data) Rollback() error {
+	if data.tx == nil {
+		return nil
+	}
+	return data.rollback()
+}
+
 // MARK: writter methods
 
 func (data *Data) dumpTable(name string) error {
@@ -221,13 +462,26 @@ func (data *Data) dumpTable(name string) error {
 }
 
 func (data *Data) writeTable(table *table) error {
-	if err := data.tableStructureTmpl.Execute(data.Out, table); err != nil {
-		return err
+	out := &countingWriter{w: data.Out}
+	switch table.Mode {
+	case TableModeSkip:
+		return nil
+	case TableModeDataOnly:
+		if err := data.tableDataOnlyTmpl.Execute(out, table); err != nil {
+			return err
+		}
+	default:
+		if err := data.tableStructureTmpl.Execute(out, table); err != nil {
+			return err
+		}
 	}
 
-	if err := data.tableContentTmpl.Execute(data.Out, table); err != nil {
-		return err
+	if table.Mode != TableModeSchemaOnly {
+		if err := data.tableContentTmpl.Execute(out, table); err != nil {
+			return err
+		}
 	}
+	data.results.recordTable(table, out.n)
 
 	return table.Err
 }
@@ -247,6 +501,21 @@ func (data *Data) getTemplates() (err error) {
 		return
 	}
 
+	data.tableDataOnlyTmpl, err = template.New("mysqldumpTableDataOnly").Parse(tableDataOnlyTmpl)
+	if err != nil {
+		return
+	}
+
+	data.viewTmpl, err = template.New("mysqldumpView").Parse(viewTmpl)
+	if err != nil {
+		return
+	}
+
+	data.routineTmpl, err = template.New("mysqldumpRoutine").Parse(routineTmpl)
+	if err != nil {
+		return
+	}
+
 	data.footerTmpl, err = template.New("mysqldumpTable").Parse(footerTmpl)
 	if err != nil {
 		return
@@ -255,25 +524,37 @@ func (data *Data) getTemplates() (err error) {
 }
 
 func (data *Data) getTables() ([]string, error) {
+	tables, _, err := data.getTablesAndViews()
+	return tables, err
+}
+
+// getTablesAndViews returns the base tables and the views (which are only dumped with DumpViews)
+func (data *Data) getTablesAndViews() ([]string, []string, error) {
 	tables := make([]string, 0)
+	views := make([]string, 0)
 
 	rows, err := data.tx.Query("SHOW FULL TABLES")
 	if err != nil {
-		return tables, err
+		return tables, views, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var name, tableType sql.NullString
 		if err := rows.Scan(&name, &tableType); err != nil {
-			return tables, err
+			return tables, views, err
+		}
+		if !name.Valid || data.isIgnoredTable(name.String) {
+			continue
 		}
-		// this will exclude views from the dump
-		if name.Valid && tableType.String == "BASE TABLE" && !data.isIgnoredTable(name.String) {
+		switch tableType.String {
+		case "BASE TABLE":
 			tables = append(tables, name.String)
+		case "VIEW":
+			views = append(views, name.String)
 		}
 	}
-	return tables, rows.Err()
+	return tables, views, rows.Err()
 }
 
 func (data *Data) isIgnoredTable(name string) bool {
@@ -282,7 +563,7 @@ func (data *Data) isIgnoredTable(name string) bool {
 			return true
 		}
 	}
-	return false
+	return data.tableModeForTable(name) == TableModeSkip
 }
 
 func (meta *metaData) updateServerVersion(data *Data) (err error) {
@@ -298,7 +579,9 @@ func (data *Data) createTable(name string) *table {
 	t := &table{
 		Name:        name,
 		data:        data,
-		WhereClause: data.WhereClauseForTables[name],
+		WhereClause: data.whereClauseForTable(name),
+		Mode:        data.tableModeForTable(name),
+		Limit:       data.tableLimitForTable(name),
 	}
 	if len(t.WhereClause) == 0 {
 		t.WhereClause = "TRUE"
@@ -306,6 +589,117 @@ func (data *Data) createTable(name string) *table {
 	return t
 }
 
+// whereClauseForTable returns the where clause for the given table; exact matches are preferred over wildcard
+// patterns (see matchingTablePattern). A subset where clause is combined with it via AND, so that the filters of the
+// framework (f.e. for removed nodes) still apply.
+func (data *Data) whereClauseForTable(name string) string {
+	whereClause := ""
+	keys := make([]string, 0, len(data.WhereClauseForTables))
+	for key := range data.WhereClauseForTables {
+		keys = append(keys, key)
+	}
+	if key, found := matchingTablePattern(name, keys); found {
+		whereClause = data.WhereClauseForTables[key]
+	}
+	if subsetWhereClause, found := data.subsetWhereClauses[name]; found {
+		if len(whereClause) == 0 {
+			return subsetWhereClause
+		}
+		return "(" + subsetWhereClause + ") AND (" + whereClause + ")"
+	}
+	return whereClause
+}
+
+// tableModeForTable returns the mode for the given table (TableModeFull if not configured)
+func (data *Data) tableModeForTable(name string) TableMode {
+	keys := make([]string, 0, len(data.TableModes))
+	for key := range data.TableModes {
+		keys = append(keys, key)
+	}
+	if key, found := matchingTablePattern(name, keys); found && len(data.TableModes[key]) > 0 {
+		return data.TableModes[key]
+	}
+	return TableModeFull
+}
+
+// tableLimitForTable returns the row limit of the given table; tables restricted by the subset are never limited,
+// as this would break the references between the rows.
+func (data *Data) tableLimitForTable(name string) TableLimit {
+	if _, found := data.subsetWhereClauses[name]; found {
+		return TableLimit{}
+	}
+	keys := make([]string, 0, len(data.TableLimits))
+	for key := range data.TableLimits {
+		keys = append(keys, key)
+	}
+	if key, found := matchingTablePattern(name, keys); found {
+		return data.TableLimits[key]
+	}
+	return TableLimit{}
+}
+
+// describeLimitedTables returns a description of every table whose content is limited, f.e.
+// "`logs`: at most 10000 rows (ORDER BY id DESC)". Tables with a row limit are only listed if they have more rows.
+func (data *Data) describeLimitedTables(tables []string) ([]string, error) {
+	result := make([]string, 0)
+	for _, name := range tables {
+		if data.tableModeForTable(name) == TableModeSchemaOnly {
+			continue
+		}
+		limit := data.tableLimitForTable(name)
+		if !limit.IsRestricted() {
+			continue
+		}
+		if limit.SamplePercent <= 0 || limit.SamplePercent >= 100 {
+			exceeded, err := data.exceedsRowLimit(name, limit.Limit)
+			if err != nil {
+				return nil, err
+			}
+			if !exceeded {
+				continue
+			}
+		}
+		result = append(result, "`"+name+"`: "+limit.String())
+	}
+	return result, nil
+}
+
+// exceedsRowLimit checks if the table has more than limit rows (matching its where clause); at most limit+1 rows
+// are counted.
+func (data *Data) exceedsRowLimit(name string, limit int) (bool, error) {
+	whereClause := data.whereClauseForTable(name)
+	if len(whereClause) == 0 {
+		whereClause = "TRUE"
+	}
+	var count int
+	err := data.tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM `%s` WHERE %s LIMIT %d) AS limited", name, whereClause, limit+1)).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("could not count the rows of %s: %w", name, err)
+	}
+	return count > limit, nil
+}
+
+// matchingTablePattern returns the key matching the table name; exact matches are preferred over wildcard patterns
+// (as understood by path.Match). If multiple patterns match, the alphabetically first one wins.
+func matchingTablePattern(name string, keys []string) (string, bool) {
+	patterns := make([]string, 0)
+	for _, key := range keys {
+		if key == name {
+			return key, true
+		}
+		if strings.ContainsAny(key, "*?[") {
+			patterns = append(patterns, key)
+		}
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
 func (table *table) NameEsc() string {
 	return "`" + table.Name + "`"
 }
@@ -387,6 +781,9 @@ func (table *table) initColumnData() error {
 		if !info[extraIndex].Valid || !strings.Contains(info[extraIndex].String, "VIRTUAL") {
 			result = append(result, info[fieldIndex].String)
 		}
+		if strings.Contains(strings.ToLower(info[extraIndex].String), "on update") {
+			table.onUpdateCols = append(table.onUpdateCols, info[fieldIndex].String)
+		}
 	}
 	table.cols = result
 	return nil
@@ -409,9 +806,15 @@ func (table *table) Init() error {
 		// No data to dump since this is a virtual table
 		return nil
 	}
+	if table.data.ChunkBlobs {
+		// must be loaded before the rows, as the connection is busy while reading them
+		if err := table.initPrimaryKey(); err != nil {
+			return err
+		}
+	}
 
 	var err error
-	table.rows, err = table.data.tx.Query("SELECT " + table.columnsList() + " FROM " + table.NameEsc() + " WHERE " + table.WhereClause)
+	table.rows, err = table.data.tx.Query(table.selectQuery())
 	if err != nil {
 		return err
 	}
@@ -422,38 +825,96 @@ func (table *table) Init() error {
 	}
 
 	table.values = make([]interface{}, len(tt))
+	table.chunkable = make([]bool, len(tt))
 	for i, tp := range tt {
 		table.values[i] = reflect.New(reflectColumnType(tp)).Interface()
+		typeName := strings.ToUpper(tp.DatabaseTypeName())
+		table.chunkable[i] = strings.Contains(typeName, "BLOB") || strings.Contains(typeName, "BINARY")
 	}
 	return nil
 }
 
+// selectQuery returns the query for the content of the table, including the TableLimit
+func (table *table) selectQuery() string {
+	query := "SELECT " + table.columnsList() + " FROM " + table.NameEsc() + " WHERE " + table.WhereClause
+	if table.Limit.SamplePercent > 0 && table.Limit.SamplePercent < 100 {
+		query = "SELECT " + table.columnsList() + " FROM " + table.NameEsc() + " WHERE (" + table.WhereClause + ") AND RAND() < " + strconv.FormatFloat(table.Limit.SamplePercent/100, 'f', -1, 64)
+	}
+	if len(table.Limit.OrderBy) > 0 {
+		query += " ORDER BY " + table.Limit.OrderBy
+	}
+	if table.Limit.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", table.Limit.Limit)
+	}
+	return query
+}
+
+// reflectColumnType returns the type a column is scanned into. Everything which is not a number or binary is scanned
+// as string, as the driver returns the exact textual representation of the server (f.e. for DECIMAL, DATETIME(6),
+// TIME, YEAR, ENUM, SET and JSON) - this way, nothing is lost by parsing and re-formatting.
 func reflectColumnType(tp *sql.ColumnType) reflect.Type {
+	// determine by name first, as the scan type of binary and text columns is the same for nullable columns
+	switch tp.DatabaseTypeName() {
+	case "BIT":
+		return reflect.TypeOf(bitValue{})
+	case "BLOB", "BINARY", "VARBINARY", "GEOMETRY", "VECTOR":
+		return reflect.TypeOf(sql.Null[[]byte]{})
+	case "DATETIME", "DATE", "TIMESTAMP":
+		return reflect.TypeOf(temporalValue{})
+	case "VARCHAR", "CHAR", "TEXT", "DECIMAL", "JSON", "TIME", "ENUM", "SET":
+		return reflect.TypeOf(sql.NullString{})
+	case "UNSIGNED BIGINT", "BIGINT UNSIGNED":
+		return reflect.TypeOf(sql.Null[uint64]{})
+	}
+
+	if tp.ScanType() == nil {
+		return reflect.TypeOf(sql.Null[any]{})
+	}
+
 	// reflect for scanable
 	switch tp.ScanType().Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return reflect.TypeOf(sql.NullInt64{})
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.TypeOf(sql.Null[uint64]{})
 	case reflect.Float32, reflect.Float64:
 		return reflect.TypeOf(sql.NullFloat64{})
+	case reflect.Bool:
+		return reflect.TypeOf(sql.NullBool{})
 	case reflect.String:
 		return reflect.TypeOf(sql.NullString{})
+	case reflect.Slice:
+		return reflect.TypeOf(sql.Null[[]byte]{})
 	}
 
-	// determine by name
 	switch tp.DatabaseTypeName() {
-	case "BLOB", "BINARY", "VARBINARY":
-		return reflect.TypeOf(sql.RawBytes{})
-	case "VARCHAR", "TEXT", "DECIMAL", "JSON", "DATETIME", "DATE", "TIMESTAMP":
-		return reflect.TypeOf(sql.NullString{})
-	case "BIGINT", "TINYINT", "INT":
+	case "BIGINT", "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "YEAR":
 		return reflect.TypeOf(sql.NullInt64{})
-	case "DOUBLE":
+	case "FLOAT", "DOUBLE":
 		return reflect.TypeOf(sql.NullFloat64{})
 	}
 
-	// unknown datatype
-	return tp.ScanType()
+	// unknown datatype - scanned as whatever the driver returns
+	return reflect.TypeOf(sql.Null[any]{})
+}
+
+// temporalValue is a DATE, DATETIME or TIMESTAMP column; usually returned as text, but as time.Time if the connection
+// uses parseTime=true.
+type temporalValue struct {
+	sql.NullString
+}
+
+func (v *temporalValue) Scan(value any) error {
+	if t, ok := value.(time.Time); ok {
+		v.String, v.Valid = formatTime(t), true
+		return nil
+	}
+	return v.NullString.Scan(value)
+}
+
+// bitValue is a BIT(n) column, which is dumped as b'0101' literal (a binary string would not be converted correctly)
+type bitValue struct {
+	sql.Null[[]byte]
 }
 
 func (table *table) Next() bool {
@@ -492,56 +953,132 @@ func (table *table) RowBuffer() *bytes.Buffer {
 		if key != 0 {
 			b.WriteString(",")
 		}
-		switch s := value.(type) {
-		case nil:
-			b.WriteString(nullType)
-		case *sql.NullString:
-			if s.Valid {
-				fmt.Fprintf(&b, "'%s'", sanitize(s.String))
-			} else {
-				b.WriteString(nullType)
-			}
-		case *sql.NullInt64:
-			if s.Valid {
-				fmt.Fprintf(&b, "%d", s.Int64)
-			} else {
-				b.WriteString(nullType)
-			}
-		case *sql.Null[uint64]:
-			if s.Valid {
-				fmt.Fprintf(&b, "%d", s.V)
-			} else {
-				b.WriteString(nullType)
-			}
-		case *sql.NullFloat64:
-			if s.Valid {
-				fmt.Fprintf(&b, "%f", s.Float64)
-			} else {
-				b.WriteString(nullType)
-			}
-		case *sql.RawBytes:
-			if len(*s) == 0 {
-				b.WriteString(nullType)
-			} else {
-				fmt.Fprintf(&b, "_binary 0x%s", hex.EncodeToString(*s))
-			}
-		case *sql.NullTime:
-			if s.Valid {
-				fmt.Fprintf(&b, `"%s"`, s.Time.Format("2006-01-02 15:04:05"))
-			} else {
-				b.WriteString(nullType)
-			}
-		case *time.Time:
-			fmt.Fprintf(&b, `"%s"`, s.Format("2006-01-02 15:04:05"))
-		default:
-			fmt.Fprintf(&b, "'%s'", value)
-		}
+		writeValue(&b, value)
 	}
 	b.WriteString(")")
 
 	return &b
 }
 
+// writeValue writes a scanned value as SQL literal, which imports to exactly the same value again
+func writeValue(b *bytes.Buffer, value interface{}) {
+	switch s := value.(type) {
+	case nil:
+		b.WriteString(nullType)
+	case *sql.NullString:
+		if s.Valid {
+			fmt.Fprintf(b, "'%s'", sanitize(s.String))
+		} else {
+			b.WriteString(nullType)
+		}
+	case *temporalValue:
+		if s.Valid {
+			fmt.Fprintf(b, "'%s'", sanitize(s.String))
+		} else {
+			b.WriteString(nullType)
+		}
+	case *sql.NullInt64:
+		if s.Valid {
+			b.WriteString(strconv.FormatInt(s.Int64, 10))
+		} else {
+			b.WriteString(nullType)
+		}
+	case *sql.Null[uint64]:
+		if s.Valid {
+			b.WriteString(strconv.FormatUint(s.V, 10))
+		} else {
+			b.WriteString(nullType)
+		}
+	case *sql.NullFloat64:
+		if s.Valid {
+			// shortest representation which parses to the same float again (f.e. 1e+20 instead of 100000000000000000000.000000)
+			b.WriteString(strconv.FormatFloat(s.Float64, 'g', -1, 64))
+		} else {
+			b.WriteString(nullType)
+		}
+	case *sql.NullBool:
+		if !s.Valid {
+			b.WriteString(nullType)
+		} else if s.Bool {
+			b.WriteString("1")
+		} else {
+			b.WriteString("0")
+		}
+	case *bitValue:
+		if s.Valid {
+			writeBits(b, s.V)
+		} else {
+			b.WriteString(nullType)
+		}
+	case *sql.Null[[]byte]:
+		if !s.Valid {
+			b.WriteString(nullType)
+		} else if len(s.V) == 0 {
+			b.WriteString("''")
+		} else {
+			fmt.Fprintf(b, "_binary 0x%s", hex.EncodeToString(s.V))
+		}
+	case *sql.RawBytes:
+		if *s == nil {
+			b.WriteString(nullType)
+		} else if len(*s) == 0 {
+			b.WriteString("''")
+		} else {
+			fmt.Fprintf(b, "_binary 0x%s", hex.EncodeToString(*s))
+		}
+	case *sql.NullTime:
+		if s.Valid {
+			writeTime(b, s.Time)
+		} else {
+			b.WriteString(nullType)
+		}
+	case *time.Time:
+		writeTime(b, *s)
+	case *sql.Null[any]:
+		if s.Valid {
+			writeValue(b, s.V)
+		} else {
+			b.WriteString(nullType)
+		}
+	case []byte:
+		fmt.Fprintf(b, "_binary 0x%s", hex.EncodeToString(s))
+	case string:
+		fmt.Fprintf(b, "'%s'", sanitize(s))
+	case int64:
+		b.WriteString(strconv.FormatInt(s, 10))
+	case uint64:
+		b.WriteString(strconv.FormatUint(s, 10))
+	case float64:
+		b.WriteString(strconv.FormatFloat(s, 'g', -1, 64))
+	case time.Time:
+		writeTime(b, s)
+	default:
+		fmt.Fprintf(b, "'%s'", sanitize(fmt.Sprint(value)))
+	}
+}
+
+func writeTime(b *bytes.Buffer, t time.Time) {
+	fmt.Fprintf(b, "'%s'", formatTime(t))
+}
+
+// formatTime formats the time in UTC (the dump is imported with TIME_ZONE='+00:00'), including microseconds if set
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.999999")
+}
+
+// writeBits writes a BIT(n) value as b'...' literal, without leading zeros
+func writeBits(b *bytes.Buffer, value []byte) {
+	bits := make([]byte, 0, len(value)*8)
+	for _, v := range value {
+		bits = append(bits, fmt.Sprintf("%08b", v)...)
+	}
+	trimmed := strings.TrimLeft(string(bits), "0")
+	if trimmed == "" {
+		trimmed = "0"
+	}
+	fmt.Fprintf(b, "b'%s'", trimmed)
+}
+
 func (table *table) Stream() <-chan string {
 	valueOut := make(chan string, 1)
 	go func() {
@@ -549,9 +1086,27 @@ func (table *table) Stream() <-chan string {
 		var insert bytes.Buffer
 
 		for table.Next() {
+			table.rowCount++
 			b := table.RowBuffer()
+			if table.isOversized(b) {
+				statements := table.chunkedRow()
+				if statements == nil {
+					table.oversizedRows++
+				} else {
+					if insert.Len() != 0 {
+						insert.WriteString(";")
+						valueOut <- insert.String()
+						insert.Reset()
+					}
+					for _, statement := range statements {
+						valueOut <- statement
+					}
+					continue
+				}
+			}
+
 			// Truncate our insert if it won't fit
-			if insert.Len() != 0 && insert.Len()+b.Len() > table.data.MaxAllowedPacket-1 {
+			if insert.Len() != 0 && insert.Len()+b.Len() > table.data.insertSize()-1 {
 				insert.WriteString(";")
 				valueOut <- insert.String()
 				insert.Reset()
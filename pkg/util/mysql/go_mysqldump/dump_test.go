@@ -3,10 +3,16 @@ package go_mysqldump
 import (
 	"bytes"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/hex"
+	"errors"
+	"io"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -363,6 +369,85 @@ func TestCreateTableRowValuesVarbinary(t *testing.T) {
 	assert.NotContains(t, result, "&"+uuid, "row values must not contain the raw UUID string with a stray leading '&'")
 }
 
+// The driver returns most values as text (text protocol, no parseTime); every type must be written as a
+// literal which imports to exactly the same value again.
+func TestCreateTableRowValuesAllTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		column   *sqlmock.Column
+		value    driver.Value
+		expected string
+	}{
+		{"bigint unsigned beyond int64", sqlmock.NewColumn("v").OfType("BIGINT UNSIGNED", uint64(0)).Nullable(false), []byte("18446744073709551615"), "18446744073709551615"},
+		{"nullable bigint unsigned beyond int64", sqlmock.NewColumn("v").OfType("UNSIGNED BIGINT", sql.Null[uint64]{}).Nullable(true), []byte("18446744073709551615"), "18446744073709551615"},
+		{"bigint min", sqlmock.NewColumn("v").OfType("BIGINT", sql.NullInt64{}).Nullable(true), []byte("-9223372036854775808"), "-9223372036854775808"},
+		{"double precision", sqlmock.NewColumn("v").OfType("DOUBLE", float64(0)).Nullable(false), []byte("0.1234567890123456"), "0.1234567890123456"},
+		{"double scientific", sqlmock.NewColumn("v").OfType("DOUBLE", sql.NullFloat64{}).Nullable(true), []byte("1e20"), "1e+20"},
+		{"double tiny", sqlmock.NewColumn("v").OfType("DOUBLE", sql.NullFloat64{}).Nullable(true), []byte("2.2250738585072014e-308"), "2.2250738585072014e-308"},
+		{"float", sqlmock.NewColumn("v").OfType("FLOAT", float32(0)).Nullable(false), []byte("1.1"), "1.1"},
+		{"decimal", sqlmock.NewColumn("v").OfType("DECIMAL", sql.NullString{}).Nullable(true), []byte("-12345678901234567890.0123456789"), "'-12345678901234567890.0123456789'"},
+		{"empty blob", sqlmock.NewColumn("v").OfType("BLOB", []byte{}).Nullable(true), []byte{}, "''"},
+		{"null blob", sqlmock.NewColumn("v").OfType("BLOB", []byte{}).Nullable(true), nil, "NULL"},
+		{"binary", sqlmock.NewColumn("v").OfType("BINARY", []byte{}).Nullable(true), []byte{0, 1, 0xff}, "_binary 0x0001ff"},
+		{"geometry", sqlmock.NewColumn("v").OfType("GEOMETRY", []byte{}).Nullable(true), []byte{0, 0, 0, 0, 1, 1}, "_binary 0x000000000101"},
+		{"bit", sqlmock.NewColumn("v").OfType("BIT", []byte{}).Nullable(true), []byte{0x00, 0x05}, "b'101'"},
+		{"bit zero", sqlmock.NewColumn("v").OfType("BIT", []byte{}).Nullable(true), []byte{0x00}, "b'0'"},
+		{"year", sqlmock.NewColumn("v").OfType("YEAR", sql.NullInt64{}).Nullable(true), []byte("1901"), "1901"},
+		{"time", sqlmock.NewColumn("v").OfType("TIME", sql.NullString{}).Nullable(true), []byte("-838:59:59.000000"), "'-838:59:59.000000'"},
+		{"datetime with fraction", sqlmock.NewColumn("v").OfType("DATETIME", sql.NullTime{}).Nullable(true), []byte("9999-12-31 23:59:59.999999"), "'9999-12-31 23:59:59.999999'"},
+		{"parsed timestamp", sqlmock.NewColumn("v").OfType("TIMESTAMP", time.Time{}).Nullable(true), time.Date(2038, 1, 19, 4, 14, 7, 999999000, time.FixedZone("CET", 3600)), "'2038-01-19 03:14:07.999999'"},
+		{"enum", sqlmock.NewColumn("v").OfType("ENUM", sql.NullString{}).Nullable(true), []byte("c"), "'c'"},
+		{"set", sqlmock.NewColumn("v").OfType("SET", sql.NullString{}).Nullable(true), []byte("x,z"), "'x,z'"},
+		{"json", sqlmock.NewColumn("v").OfType("JSON", sql.NullString{}).Nullable(true), []byte(`{"a": "it's"}`), `'{\"a\": \"it\'s\"}'`},
+		{"bool", sqlmock.NewColumn("v").OfType("BOOL", false).Nullable(false), true, "1"},
+		{"unknown type", sqlmock.NewColumn("v").OfType("SOMETHING_NEW", nil).Nullable(true), "it's", "'it\\'s'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, mock, err := getMockData()
+			assert.NoError(t, err)
+			defer data.Close()
+
+			mock.ExpectQuery("^SHOW COLUMNS FROM `test`$").WillReturnRows(sqlmock.NewRows([]string{"Field", "Extra"}).AddRow("v", ""))
+			mock.ExpectQuery("^SELECT (.+) FROM `test` WHERE TRUE$").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(tt.column).AddRow(tt.value))
+
+			table := data.createTable("test")
+			assert.True(t, table.Next())
+			assert.NoError(t, table.Err)
+			assert.Equal(t, "("+tt.expected+")", table.RowValues())
+		})
+	}
+}
+
+func TestWhereClauseForTableSupportsWildcards(t *testing.T) {
+	data := &Data{
+		WhereClauseForTables: map[string]string{
+			"cr_*_p_*":             "FALSE",
+			"cr_default_p_special": "id < 10",
+			"neos_*_thumbnail":     "TRUE",
+		},
+	}
+
+	assert.Equal(t, "FALSE", data.whereClauseForTable("cr_default_p_graph_node"))
+	assert.Equal(t, "id < 10", data.whereClauseForTable("cr_default_p_special"))
+	assert.Equal(t, "", data.whereClauseForTable("cr_default_events"))
+	assert.Equal(t, "TRUE", data.createTable("cr_default_events").WhereClause)
+}
+
+func TestWhereClauseForTableCombinesSubsetAndFrameworkFilters(t *testing.T) {
+	data := &Data{
+		WhereClauseForTables: map[string]string{"nodes": "removed = 0"},
+		subsetWhereClauses: map[string]string{
+			"nodes":  "(`id`) IN (('1'))",
+			"orders": "(`id`) IN (('5'))",
+		},
+	}
+
+	assert.Equal(t, "((`id`) IN (('1'))) AND (removed = 0)", data.whereClauseForTable("nodes"))
+	assert.Equal(t, "(`id`) IN (('5'))", data.whereClauseForTable("orders"))
+}
+
 func TestCreateTableOk(t *testing.T) {
 	data, mock, err := getMockData()
 	assert.NoError(t, err, "an error was not expected when opening a stub database connection")
@@ -423,6 +508,90 @@ UNLOCK TABLES;
 	assert.Equal(t, expectedResult, result)
 }
 
+func TestTableModes(t *testing.T) {
+	data, mock, err := getMockData()
+	assert.NoError(t, err, "an error was not expected when opening a stub database connection")
+	defer data.Close()
+
+	data.TableModes = map[string]TableMode{
+		"eventlog": TableModeSchemaOnly,
+		"users":    TableModeDataOnly,
+		"cache_*":  TableModeSkip,
+	}
+
+	// schema only: no SELECT of the content
+	mock.ExpectQuery("^SHOW CREATE TABLE `eventlog`$").WillReturnRows(sqlmock.NewRows([]string{"Table", "Create Table"}).
+		AddRow("eventlog", "CREATE TABLE `eventlog` (`id` int(11))"))
+	// data only: no SHOW CREATE TABLE
+	mockTableSelect(mock, "users")
+
+	var buf bytes.Buffer
+	data.Out = &buf
+	data.MaxAllowedPacket = 4096
+	data.results = newDumpResults()
+	assert.NoError(t, data.getTemplates())
+
+	assert.NoError(t, data.writeTable(data.createTable("eventlog")))
+	assert.NoError(t, data.writeTable(data.createTable("users")))
+	assert.NoError(t, mock.ExpectationsWereMet(), "there were unfulfilled expections")
+
+	result := buf.String()
+	assert.Contains(t, result, "CREATE TABLE `eventlog`")
+	assert.NotContains(t, result, "INSERT INTO `eventlog`")
+	assert.NotContains(t, result, "DROP TABLE IF EXISTS `users`")
+	assert.Contains(t, result, "DELETE FROM `users`;\n")
+	assert.Contains(t, result, "INSERT INTO `users` (`id`, `email`, `name`) VALUES (1,'test@test.de','Test Name 1'),(2,'test2@test.de','Test Name 2');")
+
+	stats := data.TableStats()
+	if assert.Len(t, stats, 2) {
+		assert.Equal(t, TableStats{Name: "eventlog", Mode: TableModeSchemaOnly, Rows: 0, Bytes: stats[0].Bytes}, stats[0])
+		assert.Equal(t, TableStats{Name: "users", Mode: TableModeDataOnly, Rows: 2, Bytes: stats[1].Bytes}, stats[1])
+		assert.Equal(t, int64(len(result)), stats[0].Bytes+stats[1].Bytes)
+	}
+
+	assert.True(t, data.isIgnoredTable("cache_pages"))
+	assert.False(t, data.isIgnoredTable("users"))
+	assert.Equal(t, TableModeFull, data.tableModeForTable("other"))
+}
+
+func TestTableLimits(t *testing.T) {
+	data, mock, err := getMockData()
+	assert.NoError(t, err)
+	defer data.Close()
+	data.TableLimits = map[string]TableLimit{
+		"logs":     {OrderBy: "id DESC", Limit: 10000},
+		"audit":    {Limit: 100},
+		"events_*": {SamplePercent: 5},
+		"users":    {SamplePercent: 100},
+	}
+	data.TableModes = map[string]TableMode{"events_old": TableModeSchemaOnly}
+	data.WhereClauseForTables = map[string]string{"audit": "level > 1"}
+
+	logs := data.createTable("logs")
+	logs.cols = []string{"id"}
+	assert.Equal(t, "SELECT `id` FROM `logs` WHERE TRUE ORDER BY id DESC LIMIT 10000", logs.selectQuery())
+
+	events := data.createTable("events_2024")
+	events.cols = []string{"id"}
+	events.WhereClause = "a = 1 OR b = 2"
+	assert.Equal(t, "SELECT `id` FROM `events_2024` WHERE (a = 1 OR b = 2) AND RAND() < 0.05", events.selectQuery())
+	assert.Equal(t, "at most 10000 rows (ORDER BY id DESC)", logs.filterDescription())
+	assert.Equal(t, "WHERE a = 1 OR b = 2, random sample of 5% of the rows", events.filterDescription())
+
+	// audit has less rows than its limit, so it is complete
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT 1 FROM `audit` WHERE level > 1 LIMIT 101) AS limited") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(42))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT 1 FROM `logs` WHERE TRUE LIMIT 10001) AS limited") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(10001))
+	limitedTables, err := data.describeLimitedTables([]string{"audit", "events_2024", "events_old", "logs", "users"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []string{
+		"`events_2024`: random sample of 5% of the rows",
+		"`logs`: at most 10000 rows (ORDER BY id DESC)",
+	}, limitedTables)
+}
+
 func TestCreateTableOkSmallPackets(t *testing.T) {
 	data, mock, err := getMockData()
 	assert.NoError(t, err, "an error was not expected when opening a stub database connection")
@@ -483,3 +652,271 @@ UNLOCK TABLES;
 	result := strings.Replace(buf.String(), "`", "~", -1)
 	assert.Equal(t, expectedResult, result)
 }
+
+func TestKeepTransactionAllowsQueriesAfterDump(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err, "an error was not expected when opening a stub database connection")
+	defer db.Close()
+
+	var buf bytes.Buffer
+	data := &Data{
+		Out:             &buf,
+		Connection:      db,
+		KeepTransaction: true,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT version\(\)$`).WillReturnRows(sqlmock.NewRows([]string{"Version()"}).AddRow("test_version"))
+	mock.ExpectQuery("^SHOW FULL TABLES$").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_Testdb", "Table_type"}))
+	// the query after the dump must run inside the same transaction, before the rollback
+	mock.ExpectQuery("^SELECT sha1 FROM resources$").WillReturnRows(sqlmock.NewRows([]string{"sha1"}).AddRow("abc"))
+	mock.ExpectRollback()
+
+	assert.NoError(t, data.Dump())
+
+	var sha1 string
+	assert.NoError(t, data.Tx().QueryRow("SELECT sha1 FROM resources").Scan(&sha1))
+	assert.Equal(t, "abc", sha1)
+
+	assert.NoError(t, data.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet(), "there were unfulfilled expections")
+}
+
+func TestStripDefiner(t *testing.T) {
+	tests := []struct {
+		createSql string
+		want      string
+	}{
+		{"CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `v` AS select 1 AS `1`", "CREATE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `v` AS select 1 AS `1`"},
+		{"CREATE DEFINER=`app`@`10.0.%` TRIGGER `t` BEFORE INSERT ON `x` FOR EACH ROW SET NEW.a = 1", "CREATE TRIGGER `t` BEFORE INSERT ON `x` FOR EACH ROW SET NEW.a = 1"},
+		{"CREATE DEFINER='app'@'localhost' PROCEDURE `p`() BEGIN SELECT 1; END", "CREATE PROCEDURE `p`() BEGIN SELECT 1; END"},
+		{"CREATE DEFINER=CURRENT_USER EVENT `e` ON SCHEDULE EVERY 1 DAY DO DELETE FROM x", "CREATE EVENT `e` ON SCHEDULE EVERY 1 DAY DO DELETE FROM x"},
+		{"CREATE FUNCTION `f`() RETURNS int RETURN 1", "CREATE FUNCTION `f`() RETURNS int RETURN 1"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, stripDefiner(tt.createSql))
+	}
+}
+
+func TestSortViewsByDependencies(t *testing.T) {
+	views := map[string]*object{
+		"a_report": {Name: "a_report", CreateSQL: "CREATE VIEW `a_report` AS select `c_base`.`id` AS `id` from `c_base` join `b_active`"},
+		"b_active": {Name: "b_active", CreateSQL: "CREATE VIEW `b_active` AS select `c_base`.`id` AS `id` from `c_base`"},
+		"c_base":   {Name: "c_base", CreateSQL: "CREATE VIEW `c_base` AS select `t`.`id` AS `id` from `t`"},
+		"d_other":  {Name: "d_other", CreateSQL: "CREATE VIEW `d_other` AS select 1 AS `x`"},
+	}
+
+	names := make([]string, 0)
+	for _, view := range sortViewsByDependencies(views) {
+		names = append(names, view.Name)
+	}
+	assert.Equal(t, []string{"c_base", "b_active", "a_report", "d_other"}, names)
+}
+
+func TestDumpViewsTriggersRoutinesAndEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err, "an error was not expected when opening a stub database connection")
+	defer db.Close()
+
+	var buf bytes.Buffer
+	data := &Data{
+		Out:          &buf,
+		Connection:   db,
+		DumpViews:    true,
+		DumpTriggers: true,
+		DumpRoutines: true,
+		DumpEvents:   true,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT version\(\)$`).WillReturnRows(sqlmock.NewRows([]string{"Version()"}).AddRow("test_version"))
+	mock.ExpectQuery("^SHOW FULL TABLES$").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_Testdb", "Table_type"}).
+		AddRow("orders", "BASE TABLE").
+		AddRow("open_orders", "VIEW"))
+	mock.ExpectQuery("^SELECT TRIGGER_NAME, EVENT_OBJECT_TABLE FROM information_schema.TRIGGERS").WillReturnRows(sqlmock.NewRows([]string{"TRIGGER_NAME", "EVENT_OBJECT_TABLE"}).
+		AddRow("orders_bi", "orders"))
+	mock.ExpectQuery("^SHOW CREATE TABLE `orders`$").WillReturnRows(sqlmock.NewRows([]string{"Table", "Create Table"}).
+		AddRow("orders", "CREATE TABLE `orders` (`id` int)"))
+	mock.ExpectQuery("^SHOW COLUMNS FROM `orders`$").WillReturnRows(sqlmock.NewRows([]string{"Field", "Type", "Null", "Key", "Default", "Extra"}).
+		AddRow("id", "int", "NO", "", nil, ""))
+	mock.ExpectQuery("^SELECT (.+) FROM `orders`").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(c("id", 0)).AddRow(1))
+	mock.ExpectQuery("^SHOW CREATE TRIGGER `orders_bi`$").WillReturnRows(sqlmock.NewRows([]string{"Trigger", "sql_mode", "SQL Original Statement", "character_set_client"}).
+		AddRow("orders_bi", "STRICT_TRANS_TABLES", "CREATE DEFINER=`root`@`%` TRIGGER `orders_bi` BEFORE INSERT ON `orders` FOR EACH ROW BEGIN SET NEW.id = NEW.id + 1; END", "utf8mb4"))
+	mock.ExpectQuery("^SELECT ROUTINE_NAME, ROUTINE_TYPE FROM information_schema.ROUTINES").WillReturnRows(sqlmock.NewRows([]string{"ROUTINE_NAME", "ROUTINE_TYPE"}).
+		AddRow("order_total", "FUNCTION"))
+	mock.ExpectQuery("^SHOW CREATE FUNCTION `order_total`$").WillReturnRows(sqlmock.NewRows([]string{"Function", "sql_mode", "Create Function"}).
+		AddRow("order_total", "", "CREATE DEFINER=`root`@`%` FUNCTION `order_total`() RETURNS int RETURN 1"))
+	mock.ExpectQuery("^SHOW CREATE VIEW `open_orders`$").WillReturnRows(sqlmock.NewRows([]string{"View", "Create View"}).
+		AddRow("open_orders", "CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `open_orders` AS select `orders`.`id` AS `id` from `orders`"))
+	mock.ExpectQuery("^SELECT EVENT_NAME FROM information_schema.EVENTS").WillReturnRows(sqlmock.NewRows([]string{"EVENT_NAME"}).
+		AddRow("cleanup"))
+	mock.ExpectQuery("^SHOW CREATE EVENT `cleanup`$").WillReturnRows(sqlmock.NewRows([]string{"Event", "sql_mode", "time_zone", "Create Event"}).
+		AddRow("cleanup", "", "SYSTEM", "CREATE DEFINER=`root`@`%` EVENT `cleanup` ON SCHEDULE EVERY 1 DAY DO DELETE FROM `orders`"))
+	mock.ExpectRollback()
+
+	assert.NoError(t, data.Dump())
+	assert.NoError(t, mock.ExpectationsWereMet(), "there were unfulfilled expections")
+
+	result := buf.String()
+	assert.NotContains(t, result, "DEFINER=")
+	assert.Contains(t, result, "DELIMITER ;;\nCREATE TRIGGER `orders_bi` BEFORE INSERT ON `orders` FOR EACH ROW BEGIN SET NEW.id = NEW.id + 1; END ;;\nDELIMITER ;\n")
+	assert.Contains(t, result, "/*!50003 SET sql_mode = 'STRICT_TRANS_TABLES' */;")
+	assert.Contains(t, result, "DROP FUNCTION IF EXISTS `order_total`;")
+	assert.Contains(t, result, "CREATE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `open_orders` AS select `orders`.`id` AS `id` from `orders`;")
+	assert.Contains(t, result, "/*!50003 SET time_zone = 'SYSTEM' */;")
+
+	// triggers after the table content, functions before the views (which might use them), events last
+	assert.Less(t, strings.Index(result, "INSERT INTO `orders`"), strings.Index(result, "CREATE TRIGGER"))
+	assert.Less(t, strings.Index(result, "CREATE FUNCTION"), strings.Index(result, "VIEW `open_orders` AS"))
+	assert.Less(t, strings.Index(result, "VIEW `open_orders` AS"), strings.Index(result, "CREATE EVENT"))
+}
+
+type closeRecorder struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestParallelDumpWritesOneStreamPerTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err, "an error was not expected when opening a stub database connection")
+	defer db.Close()
+	// the workers run concurrently
+	mock.MatchExpectationsInOrder(false)
+
+	var main bytes.Buffer
+	var mutex sync.Mutex
+	tableOuts := make(map[string]*closeRecorder)
+	data := &Data{
+		Out:          &main,
+		Connection:   db,
+		DumpTriggers: true,
+		Parallelism:  2,
+		OpenTableOut: func(tableName string) (io.WriteCloser, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			tableOuts[tableName] = &closeRecorder{}
+			return tableOuts[tableName], nil
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT version\(\)$`).WillReturnRows(sqlmock.NewRows([]string{"Version()"}).AddRow("test_version"))
+	mock.ExpectQuery("^SHOW FULL TABLES$").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_Testdb", "Table_type"}).
+		AddRow("a", "BASE TABLE").
+		AddRow("b", "BASE TABLE"))
+	mock.ExpectQuery("^SELECT TRIGGER_NAME, EVENT_OBJECT_TABLE FROM information_schema.TRIGGERS").WillReturnRows(sqlmock.NewRows([]string{"TRIGGER_NAME", "EVENT_OBJECT_TABLE"}).
+		AddRow("b_bi", "b"))
+	mock.ExpectExec("^FLUSH TABLES WITH READ LOCK$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectBegin()
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery("^SELECT 1 FROM `a` LIMIT 1$").WillReturnRows(sqlmock.NewRows([]string{"1"}))
+	}
+	mock.ExpectExec("^UNLOCK TABLES$").WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, name := range []string{"a", "b"} {
+		mock.ExpectQuery("^SHOW CREATE TABLE `" + name + "`$").WillReturnRows(sqlmock.NewRows([]string{"Table", "Create Table"}).
+			AddRow(name, "CREATE TABLE `"+name+"` (`id` int)"))
+		mock.ExpectQuery("^SHOW COLUMNS FROM `" + name + "`$").WillReturnRows(sqlmock.NewRows([]string{"Field", "Type", "Null", "Key", "Default", "Extra"}).
+			AddRow("id", "int", "NO", "", nil, ""))
+		mock.ExpectQuery("^SELECT `id` FROM `" + name + "`").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(c("id", 0)).AddRow(1))
+	}
+	mock.ExpectQuery("^SHOW CREATE TRIGGER `b_bi`$").WillReturnRows(sqlmock.NewRows([]string{"Trigger", "sql_mode", "SQL Original Statement"}).
+		AddRow("b_bi", "", "CREATE TRIGGER `b_bi` BEFORE INSERT ON `b` FOR EACH ROW SET NEW.id = 1"))
+	mock.ExpectRollback()
+	mock.ExpectRollback()
+	mock.ExpectRollback()
+
+	assert.NoError(t, data.Dump())
+	assert.NoError(t, mock.ExpectationsWereMet(), "there were unfulfilled expections")
+	assert.True(t, data.SnapshotsSynchronized)
+
+	for _, name := range []string{"a", "b"} {
+		out := tableOuts[name]
+		if assert.NotNil(t, out, "table %s was not written to its own stream", name) {
+			assert.True(t, out.closed)
+			assert.Contains(t, out.String(), "-- Go SQL Dump")
+			assert.Contains(t, out.String(), "INSERT INTO `"+name+"` (`id`) VALUES (1);")
+			assert.Contains(t, out.String(), "-- verify `"+name+"` rows=1\n")
+			assert.Contains(t, out.String(), "-- Dump completed")
+		}
+	}
+	assert.NotContains(t, main.String(), "INSERT INTO")
+	assert.NotContains(t, main.String(), "-- verify", "the stats are in the table streams")
+	assert.Contains(t, main.String(), "CREATE TRIGGER `b_bi`")
+}
+
+func TestParallelDumpSynchronizesSnapshotsBeforeReadingTables(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err, "an error was not expected when opening a stub database connection")
+	defer db.Close()
+
+	data := &Data{
+		Out:              &bytes.Buffer{},
+		Connection:       db,
+		MaxAllowedPacket: 1024 * 1024,
+		Parallelism:      2,
+		TableLimits:      map[string]TableLimit{"logs": {Limit: 10}},
+		OpenTableOut: func(tableName string) (io.WriteCloser, error) {
+			return &closeRecorder{}, nil
+		},
+	}
+
+	// a single table, so there is only one worker and the statements are executed in a deterministic order
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT version\(\)$`).WillReturnRows(sqlmock.NewRows([]string{"Version()"}).AddRow("test_version"))
+	mock.ExpectQuery("^SHOW FULL TABLES$").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_Testdb", "Table_type"}).
+		AddRow("logs", "BASE TABLE"))
+	mock.ExpectExec("^FLUSH TABLES WITH READ LOCK$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("^SELECT 1 FROM `logs` LIMIT 1$").WillReturnRows(sqlmock.NewRows([]string{"1"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT 1 FROM `logs` LIMIT 1$").WillReturnRows(sqlmock.NewRows([]string{"1"}))
+	mock.ExpectExec("^UNLOCK TABLES$").WillReturnResult(sqlmock.NewResult(0, 0))
+	// the row limit is only checked after the snapshots were established
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT 1 FROM `logs` WHERE TRUE LIMIT 11) AS limited") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(11))
+	mock.ExpectQuery("^SHOW CREATE TABLE `logs`$").WillReturnRows(sqlmock.NewRows([]string{"Table", "Create Table"}).
+		AddRow("logs", "CREATE TABLE `logs` (`id` int)"))
+	mock.ExpectQuery("^SHOW COLUMNS FROM `logs`$").WillReturnRows(sqlmock.NewRows([]string{"Field", "Type", "Null", "Key", "Default", "Extra"}).
+		AddRow("id", "int", "NO", "", nil, ""))
+	mock.ExpectQuery("^SELECT `id` FROM `logs`").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(c("id", 0)).AddRow(1))
+	mock.ExpectRollback()
+	mock.ExpectRollback()
+
+	assert.NoError(t, data.Dump())
+	assert.NoError(t, mock.ExpectationsWereMet(), "there were unfulfilled expections")
+	assert.True(t, data.SnapshotsSynchronized)
+}
+
+func TestParallelDumpCanRequireSynchronizedSnapshots(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err, "an error was not expected when opening a stub database connection")
+	defer db.Close()
+
+	data := &Data{
+		Out:                          &bytes.Buffer{},
+		Connection:                   db,
+		Parallelism:                  2,
+		RequireSynchronizedSnapshots: true,
+		OpenTableOut: func(tableName string) (io.WriteCloser, error) {
+			return &closeRecorder{}, nil
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT version\(\)$`).WillReturnRows(sqlmock.NewRows([]string{"Version()"}).AddRow("test_version"))
+	mock.ExpectQuery("^SHOW FULL TABLES$").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_Testdb", "Table_type"}).
+		AddRow("a", "BASE TABLE").
+		AddRow("b", "BASE TABLE"))
+	mock.ExpectExec("^FLUSH TABLES WITH READ LOCK$").WillReturnError(errors.New("Access denied; you need the RELOAD privilege"))
+	mock.ExpectRollback()
+
+	err = data.Dump()
+	assert.ErrorContains(t, err, "could not synchronize the snapshots")
+	assert.False(t, data.SnapshotsSynchronized)
+	assert.NoError(t, mock.ExpectationsWereMet(), "there were unfulfilled expections")
+}
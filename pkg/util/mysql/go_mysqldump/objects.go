@@ -0,0 +1,322 @@
+package go_mysqldump
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Takes a *object
+const viewTmpl = `
+--
+-- View structure for view {{ .NameEsc }}
+--
+
+DROP VIEW IF EXISTS {{ .NameEsc }};
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+ SET character_set_client = utf8mb4 ;
+{{ .CreateSQL }};
+/*!40101 SET character_set_client = @saved_cs_client */;
+`
+
+// Takes a *object; used for triggers, procedures, functions and events. Their bodies can contain ";", so they are
+// wrapped in DELIMITER statements (understood by the mysql command line client, like in dumps of mysqldump).
+const routineTmpl = `
+--
+-- {{ .Title }}
+--
+
+DROP {{ .Kind }} IF EXISTS {{ .NameEsc }};
+/*!50003 SET @saved_sql_mode = @@sql_mode */;
+/*!50003 SET sql_mode = '{{ .SqlModeEsc }}' */;
+{{- if .TimeZone }}
+/*!50003 SET @saved_time_zone = @@time_zone */;
+/*!50003 SET time_zone = '{{ .TimeZoneEsc }}' */;
+{{- end }}
+DELIMITER ;;
+{{ .CreateSQL }} ;;
+DELIMITER ;
+{{- if .TimeZone }}
+/*!50003 SET time_zone = @saved_time_zone */;
+{{- end }}
+/*!50003 SET sql_mode = @saved_sql_mode */;
+`
+
+// object is a view, trigger, procedure, function or event
+type object struct {
+	// Kind is the SQL keyword, f.e. VIEW or PROCEDURE
+	Kind      string
+	Title     string
+	Name      string
+	CreateSQL string
+	SqlMode   string
+	TimeZone  string
+}
+
+func (o *object) NameEsc() string {
+	return "`" + o.Name + "`"
+}
+
+func (o *object) SqlModeEsc() string {
+	return sanitize(o.SqlMode)
+}
+
+func (o *object) TimeZoneEsc() string {
+	return sanitize(o.TimeZone)
+}
+
+// definerRegex matches the DEFINER clause of CREATE statements, f.e. DEFINER=`root`@`%` or DEFINER=CURRENT_USER
+var definerRegex = regexp.MustCompile("\\s*DEFINER\\s*=\\s*(?:`[^`]*`|'[^']*'|[^\\s@`']+)(?:\\s*@\\s*(?:`[^`]*`|'[^']*'|[^\\s`']+))?")
+
+// stripDefiner removes the DEFINER clause, so that the object can be created on a system which does not have the
+// original user (the importing user becomes the definer). SQL SECURITY DEFINER is kept.
+func stripDefiner(createSql string) string {
+	return definerRegex.ReplaceAllString(createSql, "")
+}
+
+// showCreate runs a SHOW CREATE ... query and returns its columns by name
+func (data *Data) showCreate(query string) (map[string]string, error) {
+	rows, err := data.tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("no rows returned for " + query)
+	}
+
+	dest := make([]interface{}, len(cols))
+	vals := make([]sql.NullString, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(cols))
+	for i, col := range cols {
+		result[col] = vals[i].String
+	}
+	return result, rows.Err()
+}
+
+// queryNames returns the first column of all rows of the query
+func (data *Data) queryNames(query string) ([]string, error) {
+	rows, err := data.tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// MARK: triggers
+
+// getTriggers returns the trigger names per table, in execution order
+func (data *Data) getTriggers() (map[string][]string, error) {
+	rows, err := data.tx.Query("SELECT TRIGGER_NAME, EVENT_OBJECT_TABLE FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = DATABASE() ORDER BY EVENT_OBJECT_TABLE, ACTION_TIMING, EVENT_MANIPULATION, ACTION_ORDER")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggers := make(map[string][]string)
+	for rows.Next() {
+		var name, tableName string
+		if err := rows.Scan(&name, &tableName); err != nil {
+			return nil, err
+		}
+		triggers[tableName] = append(triggers[tableName], name)
+	}
+	return triggers, rows.Err()
+}
+
+// dumpTriggers writes the triggers of the given table. They are created after the table content was written,
+// so they do not fire during the import.
+func (data *Data) dumpTriggers(tableName string) error {
+	if data.tableModeForTable(tableName) == TableModeDataOnly {
+		// the structure of data-only tables (including their triggers) is kept as is
+		return nil
+	}
+	for _, name := range data.triggers[tableName] {
+		created, err := data.showCreate("SHOW CREATE TRIGGER `" + name + "`")
+		if err != nil {
+			return fmt.Errorf("could not read trigger %s: %w", name, err)
+		}
+		err = data.routineTmpl.Execute(data.Out, &object{
+			Kind:      "TRIGGER",
+			Title:     "Trigger `" + name + "` for table `" + tableName + "`",
+			Name:      name,
+			CreateSQL: stripDefiner(created["SQL Original Statement"]),
+			SqlMode:   created["sql_mode"],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MARK: views
+
+// dumpViews writes all views, ordered so that views are created after the views they depend on.
+func (data *Data) dumpViews(viewNames []string) error {
+	views := make(map[string]*object, len(viewNames))
+	for _, name := range viewNames {
+		created, err := data.showCreate("SHOW CREATE VIEW `" + name + "`")
+		if err != nil {
+			return fmt.Errorf("could not read view %s: %w", name, err)
+		}
+		views[name] = &object{
+			Kind:      "VIEW",
+			Name:      name,
+			CreateSQL: stripDefiner(created["Create View"]),
+		}
+	}
+
+	for _, view := range sortViewsByDependencies(views) {
+		if err := data.viewTmpl.Execute(data.Out, view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortViewsByDependencies orders the views (alphabetically where possible) so that every view comes after the views
+// referenced in its SELECT statement.
+func sortViewsByDependencies(views map[string]*object) []*object {
+	names := make([]string, 0, len(views))
+	for name := range views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sorted := make([]*object, 0, len(views))
+	visited := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		view := views[name]
+		// only the SELECT part references other views (the view name itself comes before " AS ")
+		selectStatement := view.CreateSQL
+		if i := strings.Index(strings.ToUpper(selectStatement), " AS "); i >= 0 {
+			selectStatement = selectStatement[i:]
+		}
+		for _, other := range names {
+			if other != name && strings.Contains(selectStatement, "`"+other+"`") {
+				visit(other)
+			}
+		}
+		sorted = append(sorted, view)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return sorted
+}
+
+// MARK: routines and events
+
+func (data *Data) dumpRoutines() error {
+	rows, err := data.tx.Query("SELECT ROUTINE_NAME, ROUTINE_TYPE FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = DATABASE() ORDER BY ROUTINE_TYPE, ROUTINE_NAME")
+	if err != nil {
+		return err
+	}
+	type routine struct {
+		name, routineType string
+	}
+	routines := make([]routine, 0)
+	for rows.Next() {
+		var r routine
+		if err := rows.Scan(&r.name, &r.routineType); err != nil {
+			rows.Close()
+			return err
+		}
+		routines = append(routines, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range routines {
+		// routineType is PROCEDURE or FUNCTION; the CREATE statement is in the column "Create Procedure" / "Create Function"
+		created, err := data.showCreate("SHOW CREATE " + r.routineType + " `" + r.name + "`")
+		if err != nil {
+			return fmt.Errorf("could not read %s %s: %w", strings.ToLower(r.routineType), r.name, err)
+		}
+		createSql := created["Create "+capitalize(r.routineType)]
+		if createSql == "" {
+			// happens if the user is neither the definer nor has SELECT on mysql.proc / SHOW_ROUTINE privilege
+			return fmt.Errorf("not allowed to read the definition of %s %s", strings.ToLower(r.routineType), r.name)
+		}
+		err = data.routineTmpl.Execute(data.Out, &object{
+			Kind:      r.routineType,
+			Title:     capitalize(r.routineType) + " `" + r.name + "`",
+			Name:      r.name,
+			CreateSQL: stripDefiner(createSql),
+			SqlMode:   created["sql_mode"],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (data *Data) dumpEvents() error {
+	names, err := data.queryNames("SELECT EVENT_NAME FROM information_schema.EVENTS WHERE EVENT_SCHEMA = DATABASE() ORDER BY EVENT_NAME")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		created, err := data.showCreate("SHOW CREATE EVENT `" + name + "`")
+		if err != nil {
+			return fmt.Errorf("could not read event %s: %w", name, err)
+		}
+		err = data.routineTmpl.Execute(data.Out, &object{
+			Kind:      "EVENT",
+			Title:     "Event `" + name + "`",
+			Name:      name,
+			CreateSQL: stripDefiner(created["Create Event"]),
+			SqlMode:   created["sql_mode"],
+			TimeZone:  created["time_zone"],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capitalize turns f.e. PROCEDURE into Procedure
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return s[:1] + strings.ToLower(s[1:])
+}
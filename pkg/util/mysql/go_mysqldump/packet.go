@@ -0,0 +1,155 @@
+package go_mysqldump
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ParsePacketSize parses a size like MySQL does for max_allowed_packet, f.e. "16M", "512K", "1G" or "4194304"
+func ParsePacketSize(input string) (int, error) {
+	size := strings.TrimSpace(input)
+	multiplier := 1
+	if len(size) > 0 {
+		switch strings.ToUpper(size[len(size)-1:]) {
+		case "K":
+			multiplier = 1024
+		case "M":
+			multiplier = 1024 * 1024
+		case "G":
+			multiplier = 1024 * 1024 * 1024
+		}
+		if multiplier > 1 {
+			size = size[:len(size)-1]
+		}
+	}
+	value, err := strconv.Atoi(size)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid packet size %q (expected f.e. 16M)", input)
+	}
+	return value * multiplier, nil
+}
+
+// sourceMaxAllowedPacket returns @@max_allowed_packet of the source database - usually, the target database is
+// configured the same way.
+func (data *Data) sourceMaxAllowedPacket() int {
+	var maxAllowedPacket int
+	if err := data.tx.QueryRow("SELECT @@max_allowed_packet").Scan(&maxAllowedPacket); err != nil || maxAllowedPacket <= 0 {
+		return defaultMaxAllowedPacket
+	}
+	return maxAllowedPacket
+}
+
+// insertSize is the maximum size of an extended insert; bigger inserts do not make the import faster.
+func (data *Data) insertSize() int {
+	if data.MaxAllowedPacket > 0 && data.MaxAllowedPacket < defaultMaxAllowedPacket {
+		return data.MaxAllowedPacket
+	}
+	return defaultMaxAllowedPacket
+}
+
+// isOversized returns true if a single INSERT of the row would be bigger than MaxAllowedPacket
+func (table *table) isOversized(row *bytes.Buffer) bool {
+	if table.data.MaxAllowedPacket <= 0 {
+		return false
+	}
+	insertLength := len("INSERT INTO ") + len(table.NameEsc()) + len(" () VALUES ;") + len(table.columnsList()) + row.Len()
+	return insertLength > table.data.MaxAllowedPacket
+}
+
+func (table *table) initPrimaryKey() error {
+	rows, err := table.data.tx.Query("SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION", table.Name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	table.primaryKey = nil
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return err
+		}
+		table.primaryKey = append(table.primaryKey, column)
+	}
+	return rows.Err()
+}
+
+// minChunkedBlobSize: smaller BLOBs are inserted directly, even if the row is split
+const minChunkedBlobSize = 1024
+
+// chunkedRow splits the current row (see ChunkBlobs) into an INSERT with empty BLOBs, and UPDATE statements
+// appending the BLOBs in chunks. Returns nil if the row can not be split - also if a single BLOB is bigger than
+// MaxAllowedPacket, as the target returns NULL for a CONCAT result bigger than its max_allowed_packet.
+func (table *table) chunkedRow() []string {
+	if !table.data.ChunkBlobs || len(table.primaryKey) == 0 {
+		return nil
+	}
+
+	var where bytes.Buffer
+	for i, column := range table.primaryKey {
+		index := slices.Index(table.cols, column)
+		if index < 0 {
+			return nil
+		}
+		if i > 0 {
+			where.WriteString(" AND ")
+		}
+		where.WriteString("`" + column + "` = ")
+		writeValue(&where, table.values[index])
+	}
+	// ON UPDATE CURRENT_TIMESTAMP columns must keep their value
+	keepOnUpdateCols := ""
+	for _, column := range table.onUpdateCols {
+		keepOnUpdateCols += ", `" + column + "` = `" + column + "`"
+	}
+
+	var insert bytes.Buffer
+	fmt.Fprint(&insert, "INSERT INTO ", table.NameEsc(), " (", table.columnsList(), ") VALUES (")
+	blobs := make(map[string][]byte)
+	for i, value := range table.values {
+		if i > 0 {
+			insert.WriteString(",")
+		}
+		if blob, ok := value.(*sql.Null[[]byte]); ok && table.chunkable[i] && blob.Valid && len(blob.V) > minChunkedBlobSize {
+			if len(blob.V) > table.data.MaxAllowedPacket {
+				return nil
+			}
+			blobs[table.cols[i]] = blob.V
+			insert.WriteString("''")
+			continue
+		}
+		writeValue(&insert, value)
+	}
+	insert.WriteString(");")
+	if len(blobs) == 0 || insert.Len() > table.data.MaxAllowedPacket {
+		return nil
+	}
+
+	overhead := len("UPDATE  SET `` = CONCAT(``, 0x) WHERE ;") + len(table.NameEsc()) + len(keepOnUpdateCols) + where.Len()
+	chunkSize := 0
+	for column := range blobs {
+		if size := (table.data.insertSize() - overhead - 2*len(column)) / 2; chunkSize == 0 || size < chunkSize {
+			chunkSize = size
+		}
+	}
+	if chunkSize < minChunkedBlobSize {
+		return nil
+	}
+
+	statements := []string{insert.String()}
+	for _, column := range table.cols {
+		blob, ok := blobs[column]
+		if !ok {
+			continue
+		}
+		for start := 0; start < len(blob); start += chunkSize {
+			end := min(start+chunkSize, len(blob))
+			statements = append(statements, "UPDATE "+table.NameEsc()+" SET `"+column+"` = CONCAT(`"+column+"`, 0x"+hex.EncodeToString(blob[start:end])+")"+keepOnUpdateCols+" WHERE "+where.String()+";")
+		}
+	}
+	return statements
+}
@@ -0,0 +1,106 @@
+package go_mysqldump
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePacketSize(t *testing.T) {
+	for input, expected := range map[string]int{"4194304": 4194304, "512K": 512 * 1024, "16M": 16 * 1024 * 1024, "1g": 1024 * 1024 * 1024} {
+		size, err := ParsePacketSize(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, size, input)
+	}
+	_, err := ParsePacketSize("16MB")
+	assert.Error(t, err)
+}
+
+func mockBlobTable(mock sqlmock.Sqlmock, withPrimaryKey bool, blobSize int) {
+	mock.ExpectQuery("^SHOW COLUMNS FROM `files`$").WillReturnRows(sqlmock.NewRows([]string{"Field", "Extra"}).
+		AddRow("id", "").
+		AddRow("content", "").
+		AddRow("updated", "on update current_timestamp()"))
+	if withPrimaryKey {
+		mock.ExpectQuery("^SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE").WithArgs("files").
+			WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+	}
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id`, `content`, `updated` FROM `files` WHERE TRUE") + "$").WillReturnRows(
+		sqlmock.NewRowsWithColumnDefinition(c("id", 0), sqlmock.NewColumn("content").OfType("BLOB", []byte{}), c("updated", "")).
+			AddRow(1, []byte("small"), "2024-01-01 00:00:00").
+			AddRow(2, bytes.Repeat([]byte{0xab}, blobSize), "2024-01-01 00:00:00").
+			AddRow(3, []byte("small"), "2024-01-01 00:00:00"))
+}
+
+func TestOversizedRowsAreSplitIntoChunks(t *testing.T) {
+	data, mock, err := getMockData()
+	assert.NoError(t, err)
+	defer data.Close()
+	data.MaxAllowedPacket = 4096
+	data.ChunkBlobs = true
+	mockBlobTable(mock, true, 2500)
+
+	var statements []string
+	for statement := range data.createTable("files").Stream() {
+		statements = append(statements, statement)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	if assert.Len(t, statements, 5) {
+		assert.Equal(t, "INSERT INTO `files` (`id`, `content`, `updated`) VALUES (1,_binary 0x736d616c6c,'2024-01-01 00:00:00');", statements[0])
+		assert.Equal(t, "INSERT INTO `files` (`id`, `content`, `updated`) VALUES (2,'','2024-01-01 00:00:00');", statements[1], "the BLOB is inserted empty")
+		update := regexp.MustCompile("^UPDATE `files` SET `content` = CONCAT\\(`content`, 0x([0-9a-f]+)\\), `updated` = `updated` WHERE `id` = 2;$")
+		content := ""
+		for _, statement := range statements[2:4] {
+			if match := update.FindStringSubmatch(statement); assert.NotNil(t, match, statement[:60]) {
+				content += match[1]
+			}
+		}
+		assert.Equal(t, strings.Repeat("ab", 2500), content, "the chunks are appended to the full BLOB")
+		assert.Equal(t, "INSERT INTO `files` (`id`, `content`, `updated`) VALUES (3,_binary 0x736d616c6c,'2024-01-01 00:00:00');", statements[4])
+	}
+	for _, statement := range statements {
+		assert.LessOrEqual(t, len(statement), data.MaxAllowedPacket)
+	}
+}
+
+func TestOversizedRowsAreCountedWithoutChunking(t *testing.T) {
+	data, mock, err := getMockData()
+	assert.NoError(t, err)
+	defer data.Close()
+	data.MaxAllowedPacket = 4096
+	mockBlobTable(mock, false, 2500)
+
+	table := data.createTable("files")
+	var statements []string
+	for statement := range table.Stream() {
+		statements = append(statements, statement)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, statements, 3, "the oversized row is inserted on its own")
+	assert.Equal(t, int64(1), table.oversizedRows)
+}
+
+func TestValuesBiggerThanThePacketAreNotSplit(t *testing.T) {
+	data, mock, err := getMockData()
+	assert.NoError(t, err)
+	defer data.Close()
+	data.MaxAllowedPacket = 4096
+	data.ChunkBlobs = true
+	// the target can not build a value bigger than max_allowed_packet (CONCAT returns NULL)
+	mockBlobTable(mock, true, 5000)
+
+	table := data.createTable("files")
+	var statements []string
+	for statement := range table.Stream() {
+		statements = append(statements, statement)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, statements, 3, "the oversized row is inserted on its own")
+	assert.NotContains(t, statements[1], "CONCAT")
+	assert.Equal(t, int64(1), table.oversizedRows)
+}
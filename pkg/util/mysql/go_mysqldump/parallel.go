@@ -0,0 +1,131 @@
+package go_mysqldump
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// isParallel returns true if the tables are dumped concurrently into their own streams (see dumpTablesParallel).
+func (data *Data) isParallel() bool {
+	return data.Parallelism > 1 && data.OpenTableOut != nil
+}
+
+// dumpTablesParallel dumps every table into its own stream (opened via OpenTableOut), using the worker
+// transactions from beginSynchronizedSnapshots. Every stream is a self-contained SQL file (with header and footer),
+// so the tables can be imported in parallel.
+func (data *Data) dumpTablesParallel(tables []string, workerTxs []*sql.Tx, meta metaData) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	tableNames := make(chan string)
+	errs := make(chan error, len(workerTxs))
+	var wg sync.WaitGroup
+	for _, tx := range workerTxs {
+		worker := *data
+		worker.tx = tx
+		worker.err = nil
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range tableNames {
+				if err := worker.dumpTableToOwnStream(name, meta); err != nil {
+					errs <- err
+					// drain the remaining tables, so that the producer does not block
+					for range tableNames {
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	for _, name := range tables {
+		tableNames <- name
+	}
+	close(tableNames)
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// beginSynchronizedSnapshots starts count worker transactions sharing the snapshot of the main transaction.
+//
+// Every worker has its own read only transaction. To let all of them (and the main transaction) see the same state,
+// the transactions are started while holding FLUSH TABLES WITH READ LOCK, and their snapshot is established by
+// reading from a table (InnoDB creates the read view on the first consistent read, not on START TRANSACTION). Thus,
+// this must be called before the main transaction reads from any table.
+// If the lock can not be acquired (f.e. missing RELOAD privilege), SnapshotsSynchronized is false afterwards - or the
+// dump fails, if RequireSynchronizedSnapshots is set.
+//
+// NOTE: unlike mysqldump --single-transaction, we do not use START TRANSACTION WITH CONSISTENT SNAPSHOT; the read
+// views are only identical because no writes can happen while the lock is held.
+func (data *Data) beginSynchronizedSnapshots(count int, snapshotTable string) ([]*sql.Tx, error) {
+	ctx := context.Background()
+	lockConn, err := data.Connection.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer lockConn.Close()
+
+	_, err = lockConn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK")
+	data.SnapshotsSynchronized = err == nil
+	if !data.SnapshotsSynchronized && data.RequireSynchronizedSnapshots {
+		return nil, fmt.Errorf("could not synchronize the snapshots of the parallel connections via FLUSH TABLES WITH READ LOCK (RELOAD privilege missing?): %w", err)
+	}
+	if data.SnapshotsSynchronized {
+		defer lockConn.ExecContext(ctx, "UNLOCK TABLES")
+	}
+
+	establishSnapshot := func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT 1 FROM `" + snapshotTable + "` LIMIT 1")
+		if err != nil {
+			return err
+		}
+		return rows.Close()
+	}
+
+	if err := establishSnapshot(data.tx); err != nil {
+		return nil, err
+	}
+	txs := make([]*sql.Tx, 0, count)
+	for i := 0; i < count; i++ {
+		tx, err := data.Connection.BeginTx(ctx, &sql.TxOptions{
+			Isolation: sql.LevelRepeatableRead,
+			ReadOnly:  true,
+		})
+		if err != nil {
+			return txs, err
+		}
+		txs = append(txs, tx)
+		if err := establishSnapshot(tx); err != nil {
+			return txs, err
+		}
+	}
+	return txs, nil
+}
+
+func (data *Data) dumpTableToOwnStream(name string, meta metaData) error {
+	if unchanged, err := data.checkUnchanged(name); err != nil || unchanged {
+		return err
+	}
+	out, err := data.OpenTableOut(name)
+	if err != nil {
+		return err
+	}
+	data.Out = out
+
+	err = data.headerTmpl.Execute(out, meta)
+	if err == nil {
+		err = data.dumpTable(name)
+	}
+	if err == nil {
+		meta.Tables = data.tableStatsFor([]string{name})
+		meta.CompleteTime = time.Now().String()
+		err = data.footerTmpl.Execute(out, meta)
+	}
+	return errors.Join(err, out.Close())
+}
@@ -0,0 +1,179 @@
+package go_mysqldump
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TableStats describes what was dumped of a table. They are written to the footer of the dump, so that an import
+// of the dump can be verified (see ReadTableStats).
+type TableStats struct {
+	Name string
+	Mode TableMode
+	// Rows is the number of rows in the dump
+	Rows int64
+	// Bytes is the size of the SQL of the table (uncompressed)
+	Bytes int64
+	// Filter describes the where clause and limit of the table; empty if the table is dumped completely
+	Filter string
+	// OversizedRows is the number of rows bigger than MaxAllowedPacket (which could not be split, see ChunkBlobs)
+	OversizedRows int64
+	// RowHash is the order independent hash of the dumped rows (see checksumQuery); only computed with Checksums
+	RowHash string
+}
+
+// dumpResults is shared by all workers of a parallel dump
+type dumpResults struct {
+	mutex     sync.Mutex
+	checksums map[string]string
+	unchanged []string
+	stats     map[string]*TableStats
+}
+
+func newDumpResults() *dumpResults {
+	return &dumpResults{
+		checksums: make(map[string]string),
+		stats:     make(map[string]*TableStats),
+	}
+}
+
+// tableStats returns the stats of the table; the caller must hold the mutex
+func (results *dumpResults) tableStats(name string) *TableStats {
+	if _, ok := results.stats[name]; !ok {
+		results.stats[name] = &TableStats{Name: name}
+	}
+	return results.stats[name]
+}
+
+func (results *dumpResults) recordTable(table *table, bytes int64) {
+	if results == nil {
+		return
+	}
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+	stats := results.tableStats(table.Name)
+	stats.Mode = table.Mode
+	stats.Rows = table.rowCount
+	stats.Bytes = bytes
+	stats.Filter = table.filterDescription()
+	stats.OversizedRows = table.oversizedRows
+}
+
+func (results *dumpResults) setRowHash(name string, rowHash string) {
+	if results == nil {
+		return
+	}
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+	results.tableStats(name).RowHash = rowHash
+}
+
+// filterDescription describes which rows of the table are dumped, f.e. "WHERE deleted = 0, at most 1000 rows"
+func (table *table) filterDescription() string {
+	parts := make([]string, 0)
+	if _, isSubset := table.data.subsetWhereClauses[table.Name]; isSubset {
+		// the where clause lists all selected keys
+		parts = append(parts, "subset (following foreign keys)")
+	} else if table.WhereClause != "TRUE" {
+		whereClause := table.WhereClause
+		if len(whereClause) > 100 {
+			whereClause = whereClause[:100] + "..."
+		}
+		parts = append(parts, "WHERE "+whereClause)
+	}
+	if table.Limit.IsRestricted() {
+		parts = append(parts, table.Limit.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// TableStats returns the stats of all dumped tables after Dump() (sorted by name)
+func (data *Data) TableStats() []TableStats {
+	if data.results == nil {
+		return nil
+	}
+	return data.tableStatsFor(nil)
+}
+
+// tableStatsFor returns the stats of the given tables (nil: all dumped tables); only tables which were written to the
+// dump are included (no unchanged or skipped tables).
+func (data *Data) tableStatsFor(names []string) []TableStats {
+	data.results.mutex.Lock()
+	defer data.results.mutex.Unlock()
+	result := make([]TableStats, 0)
+	for name, stats := range data.results.stats {
+		if names == nil || containsString(names, name) {
+			result = append(result, *stats)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+var tableStatsLine = regexp.MustCompile("^-- verify `(.+)` rows=(\\d+)(?: hash=(\\d+))?$")
+
+// ReadTableStats reads the table stats from the footer of a dump (by table name)
+func ReadTableStats(r io.Reader) (map[string]TableStats, error) {
+	result := make(map[string]TableStats)
+	scanner := bufio.NewScanner(r)
+	// the lines of a dump can be as long as max_allowed_packet
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024*1024)
+	for scanner.Scan() {
+		match := tableStatsLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		rows, err := strconv.ParseInt(match[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		result[match[1]] = TableStats{Name: match[1], Rows: rows, RowHash: match[3]}
+	}
+	return result, scanner.Err()
+}
+
+// ComputeTableStats counts the rows of the table and computes their hash like a dump with Checksums does - to compare
+// an imported dump with the TableStats recorded in the dump.
+func ComputeTableStats(db *sql.DB, name string) (TableStats, error) {
+	stats := TableStats{Name: name}
+	data := &Data{Connection: db}
+	if err := data.begin(); err != nil {
+		return stats, err
+	}
+	defer data.rollback()
+
+	table := data.createTable(name)
+	if err := table.initColumnData(); err != nil {
+		return stats, err
+	}
+	var count, rowHash uint64
+	if len(table.cols) > 0 {
+		if err := data.tx.QueryRow(table.checksumQuery()).Scan(&count, &rowHash); err != nil {
+			return stats, fmt.Errorf("could not compute checksum of %s: %w", name, err)
+		}
+	}
+	stats.Rows = int64(count)
+	stats.RowHash = strconv.FormatUint(rowHash, 10)
+	return stats, nil
+}
@@ -0,0 +1,25 @@
+package go_mysqldump
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTableStats(t *testing.T) {
+	footer := "/*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;\n" +
+		"\n" +
+		"-- Rows and checksums of the dumped tables, see \"synco verify-dump\":\n" +
+		"-- verify `orders` rows=2 hash=12345\n" +
+		"-- verify `my table` rows=0\n" +
+		"\n" +
+		"-- Dump completed on 2024-01-01\n"
+
+	stats, err := ReadTableStats(strings.NewReader(footer))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]TableStats{
+		"orders":   {Name: "orders", Rows: 2, RowHash: "12345"},
+		"my table": {Name: "my table", Rows: 0},
+	}, stats)
+}
@@ -0,0 +1,448 @@
+package go_mysqldump
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SubsetRoot selects the rows a subset starts with, f.e. the last 1000 orders:
+//
+//	SubsetRoot{Table: "orders", OrderBy: "id DESC", Limit: 1000}
+type SubsetRoot struct {
+	Table string
+	// Where is a SQL condition; empty for all rows
+	Where   string
+	OrderBy string
+	// Limit is the maximum number of root rows; 0 for no limit
+	Limit int
+}
+
+// subsetChunkSize is the maximum number of key tuples in one IN (...) condition while following foreign keys
+const subsetChunkSize = 500
+
+// foreignKey is a (possibly multi column) foreign key from Table.Columns to ReferencedTable.ReferencedColumns
+type foreignKey struct {
+	Name              string
+	Table             string
+	Columns           []string
+	ReferencedTable   string
+	ReferencedColumns []string
+}
+
+type subsetRow struct {
+	// values are aligned with subsetTable.fetchCols; nil for NULL
+	values           [][]byte
+	childrenFollowed bool
+}
+
+type subsetTable struct {
+	name string
+	// keyCols identify a row: the primary key, or (for tables without one) all foreign key columns
+	keyCols   []string
+	fetchCols []string
+	rows      map[string]*subsetRow
+	// rowOrder contains the row keys in the order they were found, so that the dump is deterministic
+	rowOrder []string
+}
+
+type subsetQuery struct {
+	table          string
+	where          string
+	suffix         string
+	followChildren bool
+}
+
+// computeSubset selects the rows of the Subset roots, and follows the foreign keys to include exactly the rows they
+// depend on (recursively), and the rows depending on them (f.e. the order_items of the selected orders). Rows which
+// are only included as dependency (f.e. the customer of an order) do not pull in their dependent rows - otherwise, the
+// subset would grow to the full database quickly.
+//
+// It returns a where clause for every table which is connected to a root table via foreign keys (unreached tables
+// get FALSE), and the tables ordered so that referenced tables come first. Tables which are not connected to any root
+// table are dumped as usual.
+func (data *Data) computeSubset(tables []string) (map[string]string, []string, error) {
+	foreignKeys, err := data.getForeignKeys(tables)
+	if err != nil {
+		return nil, nil, err
+	}
+	primaryKeys, err := data.getPrimaryKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outgoing := make(map[string][]foreignKey)
+	incoming := make(map[string][]foreignKey)
+	for _, fk := range foreignKeys {
+		outgoing[fk.Table] = append(outgoing[fk.Table], fk)
+		incoming[fk.ReferencedTable] = append(incoming[fk.ReferencedTable], fk)
+	}
+
+	subsetTables := make(map[string]*subsetTable)
+	getTable := func(name string) *subsetTable {
+		if st, found := subsetTables[name]; found {
+			return st
+		}
+		st := newSubsetTable(name, primaryKeys[name], outgoing[name], incoming[name])
+		subsetTables[name] = st
+		return st
+	}
+
+	queue := make([]subsetQuery, 0)
+	for _, root := range data.Subset {
+		if !containsString(tables, root.Table) {
+			return nil, nil, fmt.Errorf("subset root table %s does not exist (or is ignored)", root.Table)
+		}
+		query := subsetQuery{table: root.Table, where: root.Where, followChildren: true}
+		if len(query.where) == 0 {
+			query.where = "TRUE"
+		}
+		if len(root.OrderBy) > 0 {
+			query.suffix += " ORDER BY " + root.OrderBy
+		}
+		if root.Limit > 0 {
+			query.suffix += fmt.Sprintf(" LIMIT %d", root.Limit)
+		}
+		queue = append(queue, query)
+	}
+
+	for len(queue) > 0 {
+		query := queue[0]
+		queue = queue[1:]
+
+		st := getTable(query.table)
+		newRows, rowsToFollow, err := data.fetchSubsetRows(st, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, fk := range outgoing[st.name] {
+			for _, where := range inConditions(fk.ReferencedColumns, st.tuples(newRows, fk.Columns, false)) {
+				queue = append(queue, subsetQuery{table: fk.ReferencedTable, where: where})
+			}
+		}
+		for _, fk := range incoming[st.name] {
+			for _, where := range inConditions(fk.Columns, st.tuples(rowsToFollow, fk.ReferencedColumns, false)) {
+				queue = append(queue, subsetQuery{table: fk.Table, where: where, followChildren: true})
+			}
+		}
+	}
+
+	whereClauses := make(map[string]string)
+	for _, name := range connectedTables(data.Subset, foreignKeys) {
+		st, found := subsetTables[name]
+		if !found || len(st.rows) == 0 {
+			whereClauses[name] = "FALSE"
+			continue
+		}
+		rows := make([]*subsetRow, 0, len(st.rowOrder))
+		for _, key := range st.rowOrder {
+			rows = append(rows, st.rows[key])
+		}
+		// key columns of tables without primary key are foreign key columns, which can be NULL
+		whereClauses[name] = strings.Join(keyConditions(st.keyCols, st.tuples(rows, st.keyCols, true)), " OR ")
+	}
+	return whereClauses, sortTablesByForeignKeys(tables, foreignKeys), nil
+}
+
+func newSubsetTable(name string, primaryKey []string, outgoing []foreignKey, incoming []foreignKey) *subsetTable {
+	st := &subsetTable{
+		name: name,
+		rows: make(map[string]*subsetRow),
+	}
+	fkCols := make([]string, 0)
+	for _, fk := range outgoing {
+		fkCols = appendMissing(fkCols, fk.Columns...)
+	}
+	for _, fk := range incoming {
+		fkCols = appendMissing(fkCols, fk.ReferencedColumns...)
+	}
+	sort.Strings(fkCols)
+
+	st.keyCols = primaryKey
+	if len(st.keyCols) == 0 {
+		st.keyCols = fkCols
+	}
+	st.fetchCols = appendMissing(append([]string{}, st.keyCols...), fkCols...)
+	return st
+}
+
+// fetchSubsetRows runs the query and returns the rows which were not selected before, and the rows whose dependent
+// rows must be followed now.
+func (data *Data) fetchSubsetRows(st *subsetTable, query subsetQuery) (newRows []*subsetRow, rowsToFollow []*subsetRow, err error) {
+	rows, err := data.tx.Query("SELECT `" + strings.Join(st.fetchCols, "`, `") + "` FROM `" + st.name + "` WHERE " + query.where + query.suffix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not select subset of %s: %w", st.name, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		values := make([][]byte, len(st.fetchCols))
+		scans := make([]interface{}, len(values))
+		for i := range values {
+			scans[i] = &values[i]
+		}
+		if err := rows.Scan(scans...); err != nil {
+			return nil, nil, err
+		}
+
+		row := &subsetRow{values: values}
+		key := string(encodeTuple(st.tuple(row, st.keyCols)))
+		if existing, found := st.rows[key]; found {
+			row = existing
+		} else {
+			st.rows[key] = row
+			st.rowOrder = append(st.rowOrder, key)
+			newRows = append(newRows, row)
+		}
+		if query.followChildren && !row.childrenFollowed {
+			row.childrenFollowed = true
+			rowsToFollow = append(rowsToFollow, row)
+		}
+	}
+	return newRows, rowsToFollow, rows.Err()
+}
+
+// tuple returns the values of the given columns of the row
+func (st *subsetTable) tuple(row *subsetRow, cols []string) [][]byte {
+	result := make([][]byte, len(cols))
+	for i, col := range cols {
+		for j, fetchCol := range st.fetchCols {
+			if fetchCol == col {
+				result[i] = row.values[j]
+			}
+		}
+	}
+	return result
+}
+
+// tuples returns the distinct values of the given columns of the rows; unless withNull is set, tuples containing
+// NULL are skipped, as they do not reference anything.
+func (st *subsetTable) tuples(rows []*subsetRow, cols []string, withNull bool) [][][]byte {
+	seen := make(map[string]bool)
+	result := make([][][]byte, 0)
+	for _, row := range rows {
+		tuple := st.tuple(row, cols)
+		if !withNull && containsNull(tuple) {
+			continue
+		}
+		key := string(encodeTuple(tuple))
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, tuple)
+		}
+	}
+	return result
+}
+
+// inConditions returns "(`a`, `b`) IN (('1', '2'), ...)" conditions with at most subsetChunkSize tuples each
+func inConditions(cols []string, tuples [][][]byte) []string {
+	result := make([]string, 0)
+	for start := 0; start < len(tuples); start += subsetChunkSize {
+		end := start + subsetChunkSize
+		if end > len(tuples) {
+			end = len(tuples)
+		}
+		literals := make([]string, 0, end-start)
+		for _, tuple := range tuples[start:end] {
+			values := make([]string, len(tuple))
+			for i, value := range tuple {
+				values[i] = sqlLiteral(value)
+			}
+			literals = append(literals, "("+strings.Join(values, ", ")+")")
+		}
+		result = append(result, "(`"+strings.Join(cols, "`, `")+"`) IN ("+strings.Join(literals, ", ")+")")
+	}
+	return result
+}
+
+// keyConditions are like inConditions, but also match tuples containing NULL (which IN never matches), via
+// "(`a` = '1' AND `b` IS NULL)" conditions.
+func keyConditions(cols []string, tuples [][][]byte) []string {
+	withoutNull := make([][][]byte, 0, len(tuples))
+	nullConditions := make([]string, 0)
+	for _, tuple := range tuples {
+		if !containsNull(tuple) {
+			withoutNull = append(withoutNull, tuple)
+			continue
+		}
+		conditions := make([]string, len(tuple))
+		for i, value := range tuple {
+			if value == nil {
+				conditions[i] = "`" + cols[i] + "` IS NULL"
+			} else {
+				conditions[i] = "`" + cols[i] + "` = " + sqlLiteral(value)
+			}
+		}
+		nullConditions = append(nullConditions, "("+strings.Join(conditions, " AND ")+")")
+	}
+	return append(inConditions(cols, withoutNull), nullConditions...)
+}
+
+func containsNull(tuple [][]byte) bool {
+	for _, value := range tuple {
+		if value == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlLiteral quotes the value as string (so that the column collation is used for comparing), or as hex literal
+// for binary values which are not valid UTF-8.
+func sqlLiteral(value []byte) string {
+	if value == nil {
+		return nullType
+	}
+	if !utf8.Valid(value) {
+		return "0x" + hex.EncodeToString(value)
+	}
+	return "'" + sanitize(string(value)) + "'"
+}
+
+// encodeTuple encodes the values into an unambiguous map key
+func encodeTuple(values [][]byte) []byte {
+	result := make([]byte, 0)
+	for _, value := range values {
+		if value == nil {
+			result = append(result, 'N')
+			continue
+		}
+		result = append(result, fmt.Sprintf("%d:", len(value))...)
+		result = append(result, value...)
+	}
+	return result
+}
+
+// getForeignKeys returns all foreign keys between the given tables
+func (data *Data) getForeignKeys(tables []string) ([]foreignKey, error) {
+	rows, err := data.tx.Query("SELECT CONSTRAINT_NAME, TABLE_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_NAME IS NOT NULL ORDER BY TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]foreignKey, 0)
+	for rows.Next() {
+		var name, tableName, column, referencedTable, referencedColumn string
+		if err := rows.Scan(&name, &tableName, &column, &referencedTable, &referencedColumn); err != nil {
+			return nil, err
+		}
+		if !containsString(tables, tableName) || !containsString(tables, referencedTable) {
+			continue
+		}
+		if last := len(result) - 1; last >= 0 && result[last].Table == tableName && result[last].Name == name {
+			result[last].Columns = append(result[last].Columns, column)
+			result[last].ReferencedColumns = append(result[last].ReferencedColumns, referencedColumn)
+			continue
+		}
+		result = append(result, foreignKey{
+			Name:              name,
+			Table:             tableName,
+			Columns:           []string{column},
+			ReferencedTable:   referencedTable,
+			ReferencedColumns: []string{referencedColumn},
+		})
+	}
+	return result, rows.Err()
+}
+
+// getPrimaryKeys returns the primary key columns by table name
+func (data *Data) getPrimaryKeys() (map[string][]string, error) {
+	rows, err := data.tx.Query("SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = DATABASE() AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY TABLE_NAME, ORDINAL_POSITION")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string][]string)
+	for rows.Next() {
+		var tableName, column string
+		if err := rows.Scan(&tableName, &column); err != nil {
+			return nil, err
+		}
+		result[tableName] = append(result[tableName], column)
+	}
+	return result, rows.Err()
+}
+
+// connectedTables returns all tables which are connected to a root table via foreign keys (in any direction), sorted
+func connectedTables(roots []SubsetRoot, foreignKeys []foreignKey) []string {
+	neighbours := make(map[string][]string)
+	for _, fk := range foreignKeys {
+		neighbours[fk.Table] = append(neighbours[fk.Table], fk.ReferencedTable)
+		neighbours[fk.ReferencedTable] = append(neighbours[fk.ReferencedTable], fk.Table)
+	}
+	visited := make(map[string]bool)
+	stack := make([]string, 0)
+	for _, root := range roots {
+		stack = append(stack, root.Table)
+	}
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		stack = append(stack, neighbours[name]...)
+	}
+
+	result := make([]string, 0, len(visited))
+	for name := range visited {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// sortTablesByForeignKeys orders the tables so that referenced tables come before the tables referencing them
+// (alphabetically otherwise). Self references and cycles can not be resolved; they are ignored.
+func sortTablesByForeignKeys(tables []string, foreignKeys []foreignKey) []string {
+	referenced := make(map[string][]string)
+	for _, fk := range foreignKeys {
+		referenced[fk.Table] = append(referenced[fk.Table], fk.ReferencedTable)
+	}
+	sorted := append([]string{}, tables...)
+	sort.Strings(sorted)
+
+	result := make([]string, 0, len(tables))
+	state := make(map[string]int) // 1 = visiting, 2 = done
+	var visit func(name string)
+	visit = func(name string) {
+		if state[name] != 0 {
+			return
+		}
+		state[name] = 1
+		dependencies := append([]string{}, referenced[name]...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			visit(dependency)
+		}
+		state[name] = 2
+		result = append(result, name)
+	}
+	for _, name := range sorted {
+		visit(name)
+	}
+	return result
+}
+
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !containsString(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
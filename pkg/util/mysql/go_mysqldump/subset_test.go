@@ -0,0 +1,112 @@
+package go_mysqldump
+
+import (
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeSubsetFollowsForeignKeys(t *testing.T) {
+	data, mock, err := getMockData()
+	assert.NoError(t, err)
+	defer data.Close()
+
+	data.Subset = []SubsetRoot{{Table: "orders", OrderBy: "id DESC", Limit: 2}}
+
+	mock.ExpectQuery("^SELECT CONSTRAINT_NAME, TABLE_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE").WillReturnRows(
+		sqlmock.NewRows([]string{"CONSTRAINT_NAME", "TABLE_NAME", "COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME"}).
+			AddRow("fk_item_order", "order_items", "order_id", "orders", "id").
+			AddRow("fk_item_product", "order_items", "product_id", "products", "id").
+			AddRow("fk_order_customer", "orders", "customer_id", "customers", "id"))
+	mock.ExpectQuery("^SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE").WillReturnRows(
+		sqlmock.NewRows([]string{"TABLE_NAME", "COLUMN_NAME"}).
+			AddRow("customers", "id").
+			AddRow("order_items", "id").
+			AddRow("orders", "id").
+			AddRow("products", "id").
+			AddRow("settings", "name"))
+
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id`, `customer_id` FROM `orders` WHERE TRUE ORDER BY id DESC LIMIT 2") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "customer_id"}).AddRow(5, 1).AddRow(4, 1))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id` FROM `customers` WHERE (`id`) IN (('1'))") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id`, `order_id`, `product_id` FROM `order_items` WHERE (`order_id`) IN (('5'), ('4'))") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "order_id", "product_id"}).AddRow(10, 5, 100).AddRow(11, 4, nil))
+	// the orders of the items are already selected
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id`, `customer_id` FROM `orders` WHERE (`id`) IN (('5'), ('4'))") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "customer_id"}).AddRow(5, 1).AddRow(4, 1))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id` FROM `products` WHERE (`id`) IN (('100'))") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(100))
+
+	whereClauses, tables, err := data.computeSubset([]string{"customers", "order_items", "orders", "products", "reviews", "settings"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, map[string]string{
+		"customers":   "(`id`) IN (('1'))",
+		"order_items": "(`id`) IN (('10'), ('11'))",
+		"orders":      "(`id`) IN (('5'), ('4'))",
+		"products":    "(`id`) IN (('100'))",
+	}, whereClauses, "settings and reviews are not connected to orders, so they are dumped as usual")
+	assert.Equal(t, []string{"customers", "orders", "products", "order_items", "reviews", "settings"}, tables)
+}
+
+func TestComputeSubsetWithoutReachedRows(t *testing.T) {
+	data, mock, err := getMockData()
+	assert.NoError(t, err)
+	defer data.Close()
+
+	data.Subset = []SubsetRoot{{Table: "orders", Where: "tenant_id = 42"}}
+
+	mock.ExpectQuery("^SELECT CONSTRAINT_NAME").WillReturnRows(
+		sqlmock.NewRows([]string{"CONSTRAINT_NAME", "TABLE_NAME", "COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME"}).
+			AddRow("fk_item_order", "order_items", "order_id", "orders", "id"))
+	mock.ExpectQuery("^SELECT TABLE_NAME, COLUMN_NAME").WillReturnRows(sqlmock.NewRows([]string{"TABLE_NAME", "COLUMN_NAME"}))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id` FROM `orders` WHERE tenant_id = 42") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"id"}))
+
+	whereClauses, _, err := data.computeSubset([]string{"order_items", "orders"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, map[string]string{"order_items": "FALSE", "orders": "FALSE"}, whereClauses)
+}
+
+func TestSqlLiteral(t *testing.T) {
+	assert.Equal(t, "NULL", sqlLiteral(nil))
+	assert.Equal(t, "''", sqlLiteral([]byte{}))
+	assert.Equal(t, "'it\\'s'", sqlLiteral([]byte("it's")))
+	assert.Equal(t, "0xff00", sqlLiteral([]byte{0xff, 0x00}))
+}
+
+func TestComputeSubsetKeepsRowsWithNullKeysOfTablesWithoutPrimaryKey(t *testing.T) {
+	data, mock, err := getMockData()
+	assert.NoError(t, err)
+	defer data.Close()
+
+	data.Subset = []SubsetRoot{{Table: "orders"}}
+
+	mock.ExpectQuery("^SELECT CONSTRAINT_NAME").WillReturnRows(
+		sqlmock.NewRows([]string{"CONSTRAINT_NAME", "TABLE_NAME", "COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME"}).
+			AddRow("fk_tag_order", "order_tags", "order_id", "orders", "id").
+			AddRow("fk_tag_tag", "order_tags", "tag_id", "tags", "id"))
+	// order_tags has no primary key, so its foreign key columns identify the rows
+	mock.ExpectQuery("^SELECT TABLE_NAME, COLUMN_NAME").WillReturnRows(
+		sqlmock.NewRows([]string{"TABLE_NAME", "COLUMN_NAME"}).
+			AddRow("orders", "id").
+			AddRow("tags", "id"))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id` FROM `orders` WHERE TRUE") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `order_id`, `tag_id` FROM `order_tags` WHERE (`order_id`) IN (('5'))") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"order_id", "tag_id"}).AddRow(5, 7).AddRow(5, nil))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id` FROM `orders` WHERE (`id`) IN (('5'))") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("^" + regexp.QuoteMeta("SELECT `id` FROM `tags` WHERE (`id`) IN (('7'))") + "$").WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	whereClauses, _, err := data.computeSubset([]string{"order_tags", "orders", "tags"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "(`order_id`, `tag_id`) IN (('5', '7')) OR (`order_id` = '5' AND `tag_id` IS NULL)", whereClauses["order_tags"])
+}
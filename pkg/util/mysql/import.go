@@ -0,0 +1,201 @@
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pterm/pterm"
+)
+
+// ImportFiles imports the SQL dumps into the database with the given DSN (f.e. user:password@tcp(127.0.0.1:3306)/db),
+// in the given order. All statements are run on the same connection, as the dumps rely on session variables
+// (f.e. FOREIGN_KEY_CHECKS=0).
+func ImportFiles(dsn string, fileNames []string) error {
+	db, err := openDsn(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer conn.Close()
+
+	for _, fileName := range fileNames {
+		pterm.Info.Printfln("Importing %s", fileName)
+		if err := importFile(ctx, conn, fileName); err != nil {
+			return fmt.Errorf("%s: %w", fileName, err)
+		}
+	}
+	return nil
+}
+
+// ImportParallelDump imports the tables of a parallel dump concurrently (every worker with its own connection, as
+// the table files are self-contained), and afterwards the main file with the triggers, views, routines and events.
+func ImportParallelDump(dsn string, tableFileNames []string, mainFileName string, parallelism int) error {
+	db, err := openDsn(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	workerCount := min(max(parallelism, 1), len(tableFileNames))
+	ctx := context.Background()
+	fileNames := make(chan string)
+	errs := make(chan error, len(tableFileNames)+workerCount)
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := db.Conn(ctx)
+			if err != nil {
+				errs <- fmt.Errorf("error connecting to database: %w", err)
+				// drain the remaining files, so that the producer does not block
+				for range fileNames {
+				}
+				return
+			}
+			defer conn.Close()
+			for fileName := range fileNames {
+				pterm.Info.Printfln("Importing %s", fileName)
+				if err := importFile(ctx, conn, fileName); err != nil {
+					errs <- fmt.Errorf("%s: %w", fileName, err)
+				}
+			}
+		}()
+	}
+	for _, fileName := range tableFileNames {
+		fileNames <- fileName
+	}
+	close(fileNames)
+	wg.Wait()
+	close(errs)
+
+	for importErr := range errs {
+		err = errors.Join(err, importErr)
+	}
+	if err != nil {
+		return err
+	}
+	// the triggers must only be created after the tables were imported
+	return ImportFiles(dsn, []string{mainFileName})
+}
+
+func openDsn(dsn string) (*sql.DB, error) {
+	config, err := parseDsn(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("mysql", config.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	return db, nil
+}
+
+// parseDsn parses the DSN, with the same session variables as the connection of the dump
+func parseDsn(dsn string) (*mysql.Config, error) {
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	setSessionVariables(config)
+	return config, nil
+}
+
+func importFile(ctx context.Context, conn *sql.Conn, fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return SplitStatements(f, func(statement string) error {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			if len(statement) > 200 {
+				statement = statement[:200] + "..."
+			}
+			return fmt.Errorf("%w (in statement: %s)", err, statement)
+		}
+		return nil
+	})
+}
+
+// SplitStatements calls fn for every statement of the SQL dump. Like the mysql command line client, it understands
+// DELIMITER commands (used around triggers and routines) and skips "--" comment lines between statements;
+// delimiters inside of quoted strings and identifiers are ignored.
+func SplitStatements(r io.Reader, fn func(statement string) error) error {
+	reader := bufio.NewReader(r)
+	delimiter := ";"
+	var statement strings.Builder
+	var quote byte
+
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		trimmed := strings.TrimSpace(line)
+		betweenStatements := quote == 0 && len(strings.TrimSpace(statement.String())) == 0
+		switch {
+		case betweenStatements && (len(trimmed) == 0 || trimmed == "--" || strings.HasPrefix(trimmed, "-- ")):
+			// empty line or comment
+		case betweenStatements && strings.HasPrefix(strings.ToUpper(trimmed), "DELIMITER "):
+			delimiter = strings.TrimSpace(trimmed[len("DELIMITER "):])
+			statement.Reset()
+		default:
+			start := 0
+			for i := 0; i < len(line); i++ {
+				c := line[i]
+				if quote != 0 {
+					if c == '\\' && quote != '`' {
+						i++
+					} else if c == quote {
+						quote = 0
+					}
+					continue
+				}
+				if c == '\'' || c == '"' || c == '`' {
+					quote = c
+					continue
+				}
+				if strings.HasPrefix(line[i:], delimiter) {
+					statement.WriteString(line[start:i])
+					if err := emitStatement(&statement, fn); err != nil {
+						return err
+					}
+					start = i + len(delimiter)
+					i = start - 1
+				}
+			}
+			statement.WriteString(line[start:])
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+	return emitStatement(&statement, fn)
+}
+
+func emitStatement(statement *strings.Builder, fn func(statement string) error) error {
+	s := strings.TrimSpace(statement.String())
+	statement.Reset()
+	if len(s) == 0 {
+		return nil
+	}
+	return fn(s)
+}
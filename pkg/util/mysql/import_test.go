@@ -0,0 +1,34 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	dump := "-- MySQL dump\n" +
+		"SET NAMES utf8mb4;\n" +
+		"\n" +
+		"INSERT INTO `notes` VALUES (1,'a; b'),(2,'it\\'s -- not a comment;'),(3,\"x\");\n" +
+		"CREATE TABLE `a;b` (\n  `id` int\n);\n" +
+		"DELIMITER ;;\n" +
+		"CREATE TRIGGER t BEFORE INSERT ON notes FOR EACH ROW BEGIN SET NEW.id = 1; END ;;\n" +
+		"DELIMITER ;\n" +
+		"SET FOREIGN_KEY_CHECKS=1"
+
+	var statements []string
+	err := SplitStatements(strings.NewReader(dump), func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"SET NAMES utf8mb4",
+		"INSERT INTO `notes` VALUES (1,'a; b'),(2,'it\\'s -- not a comment;'),(3,\"x\")",
+		"CREATE TABLE `a;b` (\n  `id` int\n)",
+		"CREATE TRIGGER t BEFORE INSERT ON notes FOR EACH ROW BEGIN SET NEW.id = 1; END",
+		"SET FOREIGN_KEY_CHECKS=1",
+	}, statements)
+}
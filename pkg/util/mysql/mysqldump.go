@@ -6,74 +6,217 @@ import (
 	"io"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/pterm/pterm"
 	"github.com/sandstorm/synco/v2/pkg/common"
 	mysqldump "github.com/sandstorm/synco/v2/pkg/util/mysql/go_mysqldump"
 )
 
-func CreateDump(dbCredentials *common.DbCredentials, writer io.WriteCloser, whereClauseForTables map[string]string) (*sql.DB, error) {
-	// Open connection to database
-	config := mysql.NewConfig()
-	config.User = dbCredentials.User
-	config.Passwd = dbCredentials.Password
-	config.DBName = dbCredentials.DbName
-	config.Net = "tcp"
-	config.Addr = fmt.Sprintf("%s:%d", dbCredentials.Host, dbCredentials.Port)
-	// Enable SSL usage but skip verification
-	config.TLSConfig = "skip-verify"
+// DumpResult describes a finished dump
+type DumpResult struct {
+	// Tx is the read only transaction the dump was created in, so that further queries see exactly the same state
+	// as the dump; the caller must roll it back when done.
+	Tx *sql.Tx
+	// TableChecksums are only set if options.Checksums is set
+	TableChecksums map[string]string
+	// UnchangedTables were not dumped, as they match options.KnownChecksums
+	UnchangedTables []string
+	// TableStats describe the dumped tables (rows, size, filter)
+	TableStats []mysqldump.TableStats
+	// ServerVersion of the dumped database, f.e. "8.4.2"
+	ServerVersion string
+	// Warnings which were printed while dumping
+	Warnings []string
+}
 
-	db, err := sql.Open("mysql", config.FormatDSN())
+// CreateDump dumps the database to writer.
+//
+// If options.Parallelism > 1, the tables are dumped concurrently into their own streams from openTableOut;
+// writer then only contains the schema objects.
+//
+// tableModes (full, schema-only, data-only, skip) and tableLimits are merged with options.Tables and
+// options.Limits; the latter win.
+func CreateDump(dbCredentials *common.DbCredentials, writer io.WriteCloser, whereClauseForTables map[string]string, tableModes map[string]string, tableLimits map[string]TableLimit, options DumpOptions, openTableOut func(tableName string) (io.WriteCloser, error)) (*DumpResult, error) {
+	var warnings []string
+	warn := func(format string, args ...any) {
+		message := fmt.Sprintf(format, args...)
+		pterm.Warning.Println(message)
+		warnings = append(warnings, message)
+	}
+
+	db, err := openDatabase(dbCredentials, options.TLS, options.SSH, warn)
 	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
+		return nil, err
 	}
+	// on success, the connection stays open for the returned transaction
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			_ = db.Close()
+		}
+	}()
 
 	// Register database with mysqldump
 
 	dumper := mysqldump.NewDumper(db, writer)
 	dumper.WhereClauseForTables = whereClauseForTables
+	dumper.TableModes = mergeTableModes(tableModes, options.Tables)
+	dumper.TableLimits = mergeTableLimits(tableLimits, options.Limits)
+	dumper.KeepTransaction = true
+	dumper.DumpViews = options.Views
+	dumper.DumpTriggers = options.Triggers
+	dumper.DumpRoutines = options.Routines
+	dumper.DumpEvents = options.Events
+	dumper.Parallelism = options.Parallelism
+	dumper.RequireSynchronizedSnapshots = options.RequireSynchronizedSnapshots
+	dumper.OpenTableOut = openTableOut
+	dumper.Subset = subsetRoots(options.Subset)
+	dumper.Checksums = options.Checksums || len(options.KnownChecksums) > 0
+	dumper.KnownChecksums = options.KnownChecksums
+	dumper.ChunkBlobs = options.ChunkBlobs
+	if len(options.MaxAllowedPacket) > 0 {
+		if dumper.MaxAllowedPacket, err = mysqldump.ParsePacketSize(options.MaxAllowedPacket); err != nil {
+			return nil, err
+		}
+	}
 	err = dumper.Dump()
 	if err != nil {
-		// NOTE: this case happens if TLS is not supported in a database -> we fallback to the other version without TLS
-		return createDumpNoTls(dbCredentials, writer, whereClauseForTables)
+		_ = dumper.Rollback()
+		return nil, fmt.Errorf("error dumping database: %w", err)
 	}
 
 	// Close dumper, connected database and file stream.
 	err = writer.Close()
 	if err != nil {
+		_ = dumper.Rollback()
 		return nil, fmt.Errorf("error closing dumper: %w", err)
 	}
+	warnIfSnapshotsNotSynchronized(dumper, warn)
+	warnAboutOversizedRows(dumper, warn)
 
-	return db, nil
+	succeeded = true
+	return &DumpResult{
+		Tx:              dumper.Tx(),
+		TableChecksums:  dumper.TableChecksums(),
+		UnchangedTables: dumper.UnchangedTables(),
+		TableStats:      dumper.TableStats(),
+		ServerVersion:   dumper.ServerVersion,
+		Warnings:        warnings,
+	}, nil
 }
 
-func createDumpNoTls(dbCredentials *common.DbCredentials, writer io.WriteCloser, whereClauseForTables map[string]string) (*sql.DB, error) {
-	// Open connection to database
-	config := mysql.NewConfig()
-	config.User = dbCredentials.User
-	config.Passwd = dbCredentials.Password
-	config.DBName = dbCredentials.DbName
-	config.Net = "tcp"
-	config.Addr = fmt.Sprintf("%s:%d", dbCredentials.Host, dbCredentials.Port)
-	// DISABLE tls here
+// openDatabase connects to the database, before anything is written - so that a failing TLS handshake can be
+// retried without TLS (only for the preferred TLS mode).
+func openDatabase(dbCredentials *common.DbCredentials, tlsConfig TlsOptions, sshConfig SshOptions, warn func(format string, args ...any)) (*sql.DB, error) {
+	mysqlConfig := mysql.NewConfig()
+	mysqlConfig.User = dbCredentials.User
+	mysqlConfig.Passwd = dbCredentials.Password
+	mysqlConfig.DBName = dbCredentials.DbName
+	setNetAndAddr(mysqlConfig, dbCredentials)
+	setSessionVariables(mysqlConfig)
+	if err := configureTls(mysqlConfig, tlsConfig); err != nil {
+		return nil, err
+	}
+	if err := configureSsh(mysqlConfig, sshConfig); err != nil {
+		return nil, err
+	}
 
-	db, err := sql.Open("mysql", config.FormatDSN())
+	db, err := connect(mysqlConfig)
+	if err != nil && tlsMode(tlsConfig) == TlsModePreferred && isTlsError(err) {
+		warn("TLS connection to the database failed (%s) - retrying WITHOUT TLS. Use --db-tls=required to prevent this.", err)
+		mysqlConfig.TLS = nil
+		db, err = connect(mysqlConfig)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
+		return nil, fmt.Errorf("could not connect to database: %w", err)
 	}
+	return db, nil
+}
 
-	// Register database with mysqldump
-
-	dumper := mysqldump.NewDumper(db, writer)
-	dumper.WhereClauseForTables = whereClauseForTables
-	err = dumper.Dump()
+func connect(mysqlConfig *mysql.Config) (*sql.DB, error) {
+	connector, err := mysql.NewConnector(mysqlConfig)
 	if err != nil {
-		return nil, fmt.Errorf("error registering database (with and without TLS): %w", err)
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
 	}
+	return db, nil
+}
 
-	// Close dumper, connected database and file stream.
-	err = writer.Close()
-	if err != nil {
-		return nil, fmt.Errorf("error closing dumper: %w", err)
+// warnAboutOversizedRows warns if rows are bigger than the max_allowed_packet of the target database, as they will
+// fail to import.
+func warnAboutOversizedRows(dumper *mysqldump.Data, warn func(format string, args ...any)) {
+	for _, stats := range dumper.TableStats() {
+		if stats.OversizedRows > 0 && dumper.ChunkBlobs {
+			warn("Table %s: %d rows are bigger than max_allowed_packet (%d bytes) and could not be split (a single value is bigger than max_allowed_packet, or the table has no primary key) - the target database needs a bigger max_allowed_packet to import them.", stats.Name, stats.OversizedRows, dumper.MaxAllowedPacket)
+		} else if stats.OversizedRows > 0 {
+			warn("Table %s: %d rows are bigger than max_allowed_packet (%d bytes) - the target database needs a bigger max_allowed_packet to import them, or dump with --chunk-blobs.", stats.Name, stats.OversizedRows, dumper.MaxAllowedPacket)
+		}
 	}
+}
 
-	return db, nil
+func mergeTableModes(frameworkDefaults map[string]string, configured map[string]string) map[string]mysqldump.TableMode {
+	result := make(map[string]mysqldump.TableMode)
+	for table, mode := range frameworkDefaults {
+		result[table] = mysqldump.TableMode(mode)
+	}
+	for table, mode := range configured {
+		result[table] = mysqldump.TableMode(mode)
+	}
+	return result
+}
+
+func mergeTableLimits(frameworkDefaults map[string]TableLimit, configured map[string]TableLimit) map[string]mysqldump.TableLimit {
+	result := make(map[string]mysqldump.TableLimit)
+	for _, limits := range []map[string]TableLimit{frameworkDefaults, configured} {
+		for table, limit := range limits {
+			result[table] = mysqldump.TableLimit{
+				OrderBy:       limit.OrderBy,
+				Limit:         limit.Limit,
+				SamplePercent: limit.Sample,
+			}
+		}
+	}
+	return result
+}
+
+func subsetRoots(roots []SubsetRoot) []mysqldump.SubsetRoot {
+	result := make([]mysqldump.SubsetRoot, 0, len(roots))
+	for _, root := range roots {
+		result = append(result, mysqldump.SubsetRoot{
+			Table:   root.Table,
+			Where:   root.Where,
+			OrderBy: root.OrderBy,
+			Limit:   root.Limit,
+		})
+	}
+	return result
+}
+
+func warnIfSnapshotsNotSynchronized(dumper *mysqldump.Data, warn func(format string, args ...any)) {
+	if dumper.Parallelism > 1 && dumper.OpenTableOut != nil && !dumper.SnapshotsSynchronized {
+		warn("Could not run FLUSH TABLES WITH READ LOCK (RELOAD privilege missing?) - the tables were dumped in parallel, but might come from slightly different points in time. Use --require-synchronized-snapshots to fail instead.")
+	}
+}
+
+// setSessionVariables reads TIMESTAMP columns in UTC, matching the TIME_ZONE='+00:00' in the header of the dump
+// (otherwise they would be shifted by the server time zone on import). Also used when verifying an import, so that
+// the row hashes are computed from the same values.
+func setSessionVariables(config *mysql.Config) {
+	if config.Params == nil {
+		config.Params = make(map[string]string)
+	}
+	config.Params["time_zone"] = "'+00:00'"
+}
+
+func setNetAndAddr(config *mysql.Config, dbCredentials *common.DbCredentials) {
+	if len(dbCredentials.Socket) > 0 {
+		config.Net = "unix"
+		config.Addr = dbCredentials.Socket
+		return
+	}
+	config.Net = "tcp"
+	config.Addr = fmt.Sprintf("%s:%d", dbCredentials.Host, dbCredentials.Port)
 }
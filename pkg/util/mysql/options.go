@@ -0,0 +1,71 @@
+package mysql
+
+// DumpOptions control how a database dump is created; they are mapped from the dump section of .synco-serve.yml
+// (and the corresponding flags), see go_mysqldump.Data for details.
+type DumpOptions struct {
+	// which schema objects are dumped in addition to the tables
+	Views    bool
+	Triggers bool
+	Routines bool
+	Events   bool
+	// Parallelism > 1 dumps the tables concurrently with this many connections, into one file per table.
+	Parallelism int
+	// RequireSynchronizedSnapshots fails a parallel dump if the connections can not share the same snapshot
+	RequireSynchronizedSnapshots bool
+	// Tables maps table names (or patterns) to their mode: full, schema-only, data-only or skip
+	Tables map[string]string
+	// Limits only dump some rows of the given tables (or patterns)
+	Limits map[string]TableLimit
+	// Checksums computes a checksum of every table, for incremental refreshes
+	Checksums bool
+	// KnownChecksums: tables with the same checksum are not dumped
+	KnownChecksums map[string]string
+	// Subset only dumps the rows selected by these roots, plus the rows connected to them via foreign keys.
+	Subset []SubsetRoot
+	// MaxAllowedPacket of the target database (f.e. 16M); empty for the value of the source database.
+	MaxAllowedPacket string
+	// ChunkBlobs splits rows bigger than MaxAllowedPacket, by appending their BLOBs in chunks.
+	ChunkBlobs bool
+	TLS        TlsOptions
+	SSH        SshOptions
+}
+
+// TableLimit restricts the rows of a table, f.e. {OrderBy: "id DESC", Limit: 10000}
+type TableLimit struct {
+	OrderBy string
+	Limit   int
+	// Sample selects a random sample of roughly this percentage of the rows (0-100)
+	Sample float64
+}
+
+// SubsetRoot selects the rows a database subset starts with
+type SubsetRoot struct {
+	Table string
+	// Where is a SQL condition, f.e. "tenant_id = 42"
+	Where   string
+	OrderBy string
+	Limit   int
+}
+
+// TlsOptions configure TLS for the database connection
+type TlsOptions struct {
+	// Mode is disabled, preferred (default), required, verify-ca or verify-full
+	Mode string
+	// Ca is the CA certificate file (PEM) to verify the server certificate against; the system CAs if empty
+	Ca string
+	// ServerName is the host name to verify the server certificate against (verify-full); the database host if empty
+	ServerName string
+	// Cert and Key are the client certificate files (PEM), if the server requires them
+	Cert string
+	Key  string
+}
+
+// SshOptions tunnel the database connection through an SSH jump host
+type SshOptions struct {
+	// Host is [user@]host[:port]
+	Host string
+	// Key is a private key file; if empty, the SSH agent (SSH_AUTH_SOCK) is used
+	Key string
+	// KnownHosts is the known_hosts file to verify the jump host against (default: ~/.ssh/known_hosts)
+	KnownHosts string
+}
@@ -0,0 +1,147 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pterm/pterm"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTunnels are the open connections to the jump hosts; they stay open until synco exits, as the dump transaction
+// is kept open until then anyway.
+var sshTunnels = map[string]*ssh.Client{}
+var sshTunnelsMutex sync.Mutex
+
+// ParseSshTarget splits [user@]host[:port] into the user (default: the current user) and host:port (default port 22)
+func ParseSshTarget(target string) (string, string, error) {
+	userName := ""
+	if index := strings.LastIndex(target, "@"); index >= 0 {
+		userName, target = target[:index], target[index+1:]
+	}
+	if len(target) == 0 {
+		return "", "", errors.New("the SSH host is missing")
+	}
+	if len(userName) == 0 {
+		currentUser, err := user.Current()
+		if err != nil {
+			return "", "", fmt.Errorf("could not determine the SSH user: %w", err)
+		}
+		userName = currentUser.Username
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(strings.Trim(target, "[]"), "22")
+	}
+	return userName, target, nil
+}
+
+// ValidateSshConfig checks the jump host and that the key and known_hosts files can be read
+func ValidateSshConfig(sshConfig SshOptions) error {
+	if len(sshConfig.Host) == 0 {
+		return nil
+	}
+	_, err := buildSshClientConfig(sshConfig)
+	return err
+}
+
+func buildSshClientConfig(sshConfig SshOptions) (*ssh.ClientConfig, error) {
+	userName, _, err := ParseSshTarget(sshConfig.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	knownHostsFile := sshConfig.KnownHosts
+	if len(knownHostsFile) == 0 {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("could not find the known_hosts file: %w", err)
+		}
+		knownHostsFile = filepath.Join(homeDir, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read known hosts (add the jump host via ssh first): %w", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if len(sshConfig.Key) > 0 {
+		pem, err := os.ReadFile(sshConfig.Key)
+		if err != nil {
+			return nil, fmt.Errorf("could not read SSH key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse SSH key %s (for keys with a passphrase, use the SSH agent): %w", sshConfig.Key, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	} else if socket := os.Getenv("SSH_AUTH_SOCK"); len(socket) > 0 {
+		auth = append(auth, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			conn, err := net.Dial("unix", socket)
+			if err != nil {
+				return nil, err
+			}
+			return agent.NewClient(conn).Signers()
+		}))
+	} else {
+		return nil, errors.New("neither an SSH key nor an SSH agent (SSH_AUTH_SOCK) is available")
+	}
+
+	return &ssh.ClientConfig{
+		User:            userName,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	}, nil
+}
+
+func openSshTunnel(sshConfig SshOptions) (*ssh.Client, error) {
+	sshTunnelsMutex.Lock()
+	defer sshTunnelsMutex.Unlock()
+	if client, ok := sshTunnels[sshConfig.Host]; ok {
+		return client, nil
+	}
+
+	clientConfig, err := buildSshClientConfig(sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	_, address, _ := ParseSshTarget(sshConfig.Host)
+	client, err := ssh.Dial("tcp", address, clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to SSH jump host %s: %w", sshConfig.Host, err)
+	}
+	pterm.Info.Printfln("Connected to SSH jump host %s", sshConfig.Host)
+	sshTunnels[sshConfig.Host] = client
+	return client, nil
+}
+
+// configureSsh makes the driver connect through the SSH jump host; the host (or socket) of the database is resolved
+// on the jump host.
+func configureSsh(mysqlConfig *mysql.Config, sshConfig SshOptions) error {
+	if len(sshConfig.Host) == 0 {
+		return nil
+	}
+	client, err := openSshTunnel(sshConfig)
+	if err != nil {
+		return err
+	}
+
+	network := mysqlConfig.Net
+	// the network is registered globally at the driver, so it must be unique per jump host and network
+	mysqlConfig.Net = "ssh+" + network + "+" + sshConfig.Host
+	mysql.RegisterDialContext(mysqlConfig.Net, func(ctx context.Context, addr string) (net.Conn, error) {
+		return client.DialContext(ctx, network, addr)
+	})
+	return nil
+}
@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSshTarget(t *testing.T) {
+	userName, address, err := ParseSshTarget("deploy@bastion.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "deploy", userName)
+	assert.Equal(t, "bastion.example.com:22", address)
+
+	userName, address, err = ParseSshTarget("deploy@[2001:db8::1]:2222")
+	assert.NoError(t, err)
+	assert.Equal(t, "deploy", userName)
+	assert.Equal(t, "[2001:db8::1]:2222", address)
+
+	_, address, err = ParseSshTarget("bastion")
+	assert.NoError(t, err)
+	assert.Equal(t, "bastion:22", address)
+
+	_, _, err = ParseSshTarget("deploy@")
+	assert.Error(t, err)
+}
+
+func TestValidateSshConfig(t *testing.T) {
+	assert.NoError(t, ValidateSshConfig(SshOptions{}))
+
+	err := ValidateSshConfig(SshOptions{Host: "deploy@bastion", KnownHosts: "does-not-exist"})
+	assert.ErrorContains(t, err, "could not read known hosts")
+}
@@ -0,0 +1,149 @@
+package mysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLS modes for database connections, like the --ssl-mode of the mysql client
+const (
+	TlsModeDisabled = "disabled"
+	// TlsModePreferred uses TLS (without verifying the certificate) if the server supports it; if the TLS handshake
+	// fails, the connection is retried without TLS. This is the default.
+	TlsModePreferred = "preferred"
+	// TlsModeRequired uses TLS, without verifying the certificate
+	TlsModeRequired = "required"
+	// TlsModeVerifyCa verifies the certificate of the server against the CA, but not the host name
+	TlsModeVerifyCa = "verify-ca"
+	// TlsModeVerifyFull verifies the certificate and the host name of the server
+	TlsModeVerifyFull = "verify-full"
+)
+
+// TlsModes returns all valid TLS modes
+func TlsModes() []string {
+	return []string{TlsModeDisabled, TlsModePreferred, TlsModeRequired, TlsModeVerifyCa, TlsModeVerifyFull}
+}
+
+// ValidateTlsConfig checks the TLS mode and that the certificate files can be loaded
+func ValidateTlsConfig(tlsConfig TlsOptions) error {
+	_, err := buildTlsConfig(tlsConfig, "")
+	return err
+}
+
+func tlsMode(tlsConfig TlsOptions) string {
+	if len(tlsConfig.Mode) == 0 {
+		return TlsModePreferred
+	}
+	return tlsConfig.Mode
+}
+
+// buildTlsConfig returns the TLS config for the given host (nil for disabled TLS)
+func buildTlsConfig(tlsConfig TlsOptions, host string) (*tls.Config, error) {
+	mode := tlsMode(tlsConfig)
+	if !slices.Contains(TlsModes(), mode) {
+		return nil, fmt.Errorf("invalid TLS mode %q, must be one of %v", mode, TlsModes())
+	}
+	if mode == TlsModeDisabled {
+		return nil, nil
+	}
+	if (mode == TlsModePreferred || mode == TlsModeRequired) && len(tlsConfig.Ca) > 0 {
+		// the certificate would not be verified, which is easily overlooked
+		return nil, fmt.Errorf("TLS mode %s does not verify the server certificate, so the CA file is not used - use verify-ca or verify-full", mode)
+	}
+	if (len(tlsConfig.Cert) > 0) != (len(tlsConfig.Key) > 0) {
+		return nil, errors.New("the client certificate and its key must be given together")
+	}
+
+	if len(tlsConfig.ServerName) > 0 {
+		host = tlsConfig.ServerName
+	}
+	result := &tls.Config{
+		ServerName: host,
+		// verification is done below, depending on the mode
+		InsecureSkipVerify: true,
+	}
+	if len(tlsConfig.Cert) > 0 {
+		certificate, err := tls.LoadX509KeyPair(tlsConfig.Cert, tlsConfig.Key)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		result.Certificates = []tls.Certificate{certificate}
+	}
+	if mode != TlsModeVerifyCa && mode != TlsModeVerifyFull {
+		return result, nil
+	}
+
+	// without a CA file, the system CAs are used (nil)
+	var rootCAs *x509.CertPool
+	if len(tlsConfig.Ca) > 0 {
+		pem, err := os.ReadFile(tlsConfig.Ca)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %w", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", tlsConfig.Ca)
+		}
+	}
+	if mode == TlsModeVerifyFull {
+		result.RootCAs = rootCAs
+		result.InsecureSkipVerify = false
+	} else {
+		result.VerifyConnection = func(cs tls.ConnectionState) error {
+			// like the default verification, but without checking the host name
+			opts := x509.VerifyOptions{
+				Roots:         rootCAs,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	}
+	return result, nil
+}
+
+// configureTls sets the TLS config of the connection
+func configureTls(mysqlConfig *mysql.Config, tlsConfig TlsOptions) error {
+	host := ""
+	if mysqlConfig.Net == "tcp" {
+		host, _, _ = net.SplitHostPort(mysqlConfig.Addr)
+	}
+	if tlsMode(tlsConfig) == TlsModeVerifyFull && len(host) == 0 && len(tlsConfig.ServerName) == 0 {
+		return fmt.Errorf("TLS mode %s can not verify the host name of a unix socket connection - set the server name of the certificate (--db-tls-server-name), or use verify-ca", TlsModeVerifyFull)
+	}
+	result, err := buildTlsConfig(tlsConfig, host)
+	if err != nil {
+		return err
+	}
+	mysqlConfig.TLS = result
+	// the driver only uses TLS if the server supports it
+	mysqlConfig.AllowFallbackToPlaintext = tlsMode(tlsConfig) == TlsModePreferred
+	return nil
+}
+
+// isTlsError returns true if the connection failed because of the TLS negotiation
+func isTlsError(err error) bool {
+	var recordHeaderError tls.RecordHeaderError
+	var alertError tls.AlertError
+	var certificateVerificationError *tls.CertificateVerificationError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var certificateInvalidError x509.CertificateInvalidError
+	return errors.Is(err, mysql.ErrNoTLS) ||
+		errors.As(err, &recordHeaderError) ||
+		errors.As(err, &alertError) ||
+		errors.As(err, &certificateVerificationError) ||
+		errors.As(err, &unknownAuthorityError) ||
+		errors.As(err, &hostnameError) ||
+		errors.As(err, &certificateInvalidError)
+}
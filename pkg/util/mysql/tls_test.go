@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTlsConfig(t *testing.T) {
+	tlsConfig, err := buildTlsConfig(TlsOptions{Mode: TlsModeDisabled}, "db")
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	tlsConfig, err = buildTlsConfig(TlsOptions{}, "db")
+	assert.NoError(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+
+	_, err = buildTlsConfig(TlsOptions{Mode: "skip-verify"}, "db")
+	assert.ErrorContains(t, err, "invalid TLS mode")
+
+	// without a CA file, the system CAs are used
+	tlsConfig, err = buildTlsConfig(TlsOptions{Mode: TlsModeVerifyFull}, "db")
+	assert.NoError(t, err)
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+	assert.Nil(t, tlsConfig.RootCAs)
+	assert.Equal(t, "db", tlsConfig.ServerName)
+	tlsConfig, err = buildTlsConfig(TlsOptions{Mode: TlsModeVerifyCa}, "db")
+	assert.NoError(t, err)
+	assert.NotNil(t, tlsConfig.VerifyConnection)
+
+	_, err = buildTlsConfig(TlsOptions{Mode: TlsModeRequired, Cert: "client.pem"}, "db")
+	assert.ErrorContains(t, err, "must be given together")
+
+	// a CA is only used for verifying
+	_, err = buildTlsConfig(TlsOptions{Ca: "ca.pem"}, "db")
+	assert.ErrorContains(t, err, "the CA file is not used")
+	_, err = buildTlsConfig(TlsOptions{Mode: TlsModeRequired, Ca: "ca.pem"}, "db")
+	assert.ErrorContains(t, err, "use verify-ca or verify-full")
+}
+
+func TestConfigureTlsForUnixSockets(t *testing.T) {
+	mysqlConfig := mysql.NewConfig()
+	mysqlConfig.Net = "unix"
+	mysqlConfig.Addr = "/run/mysqld/mysqld.sock"
+
+	err := configureTls(mysqlConfig, TlsOptions{Mode: TlsModeVerifyFull})
+	assert.ErrorContains(t, err, "can not verify the host name of a unix socket connection")
+
+	assert.NoError(t, configureTls(mysqlConfig, TlsOptions{Mode: TlsModeVerifyFull, ServerName: "db.example.com"}))
+	assert.Equal(t, "db.example.com", mysqlConfig.TLS.ServerName)
+	assert.False(t, mysqlConfig.TLS.InsecureSkipVerify)
+
+	mysqlConfig.Net = "tcp"
+	mysqlConfig.Addr = "10.0.0.5:3306"
+	assert.NoError(t, configureTls(mysqlConfig, TlsOptions{Mode: TlsModeVerifyFull}))
+	assert.Equal(t, "10.0.0.5", mysqlConfig.TLS.ServerName)
+}
+
+func TestIsTlsError(t *testing.T) {
+	assert.True(t, isTlsError(fmt.Errorf("connecting: %w", mysql.ErrNoTLS)))
+	assert.False(t, isTlsError(errors.New("Access denied for user 'root'@'localhost'")))
+}
@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	mysqldump "github.com/sandstorm/synco/v2/pkg/util/mysql/go_mysqldump"
+)
+
+// ReadTableStats reads the row counts and checksums recorded in the footers of the given dump files
+func ReadTableStats(fileNames []string) (map[string]mysqldump.TableStats, error) {
+	result := make(map[string]mysqldump.TableStats)
+	for _, fileName := range fileNames {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := mysqldump.ReadTableStats(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+		for name, tableStats := range stats {
+			result[name] = tableStats
+		}
+	}
+	return result, nil
+}
+
+// VerifyImport compares the tables in the database with the stats recorded at dump time, and returns the differences
+// (empty if everything matches). The row hash is only compared if it was recorded (dumps with checksums).
+func VerifyImport(dsn string, expected map[string]mysqldump.TableStats) ([]string, error) {
+	if len(expected) == 0 {
+		return nil, errors.New("no row counts were recorded for the dump (created with an older synco version, or the file is incomplete?)")
+	}
+	db, err := openDsn(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	differences := make([]string, 0)
+	for _, name := range names {
+		actual, err := mysqldump.ComputeTableStats(db, name)
+		if err != nil {
+			differences = append(differences, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		if actual.Rows != expected[name].Rows {
+			differences = append(differences, fmt.Sprintf("%s: %d rows in the dump, but %d rows imported", name, expected[name].Rows, actual.Rows))
+		} else if len(expected[name].RowHash) > 0 && actual.RowHash != expected[name].RowHash {
+			differences = append(differences, fmt.Sprintf("%s: the imported rows differ from the dumped rows (checksum %s instead of %s)", name, actual.RowHash, expected[name].RowHash))
+		}
+	}
+	return differences, nil
+}
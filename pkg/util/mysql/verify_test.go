@@ -0,0 +1,19 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDsnSetsSessionVariablesOfTheDump(t *testing.T) {
+	config, err := parseDsn("root:password@tcp(127.0.0.1:3306)/scratch?sql_mode=%27ANSI%27")
+	assert.NoError(t, err)
+	assert.Equal(t, "'+00:00'", config.Params["time_zone"])
+	assert.Equal(t, "'ANSI'", config.Params["sql_mode"])
+}
+
+func TestVerifyImportFailsWithoutExpectedStats(t *testing.T) {
+	_, err := VerifyImport("root:password@tcp(127.0.0.1:1)/scratch", nil)
+	assert.ErrorContains(t, err, "no row counts")
+}
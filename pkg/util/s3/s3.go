@@ -0,0 +1,377 @@
+// Package s3 is a minimal client for S3 compatible object storages (AWS S3, MinIO, Hetzner, DigitalOcean Spaces, ...).
+//
+// We only need listing objects and generating (presigned) download URLs, so we implement AWS Signature Version 4
+// ourselves instead of pulling in the full AWS SDK - this keeps synco-lite small.
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm         = "AWS4-HMAC-SHA256"
+	unsignedPayload   = "UNSIGNED-PAYLOAD"
+	emptyPayloadHash  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	amzDateFormat     = "20060102T150405Z"
+	scopeDateFormat   = "20060102"
+	defaultAwsRegion  = "us-east-1"
+	maxPresignExpires = 7 * 24 * time.Hour
+)
+
+type Client struct {
+	// Endpoint is f.e. https://fsn1.your-objectstorage.com. If empty, the AWS endpoint for Region is used.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// UsePathStyle addresses the bucket as https://endpoint/bucket/key instead of https://bucket.endpoint/key
+	UsePathStyle bool
+
+	HttpClient *http.Client
+	// now is overridable for tests
+	now func() time.Time
+}
+
+type Object struct {
+	Key          string
+	SizeBytes    int64
+	LastModified time.Time
+}
+
+func (c *Client) region() string {
+	if len(c.Region) == 0 {
+		return defaultAwsRegion
+	}
+	return c.Region
+}
+
+func (c *Client) currentTime() time.Time {
+	if c.now != nil {
+		return c.now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HttpClient != nil {
+		return c.HttpClient
+	}
+	return http.DefaultClient
+}
+
+// ObjectUrl returns the (unsigned) URL of the object with the given key.
+func (c *Client) ObjectUrl(key string) (*url.URL, error) {
+	endpoint := c.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = "https://s3." + c.region() + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %s: %w", endpoint, err)
+	}
+
+	if c.UsePathStyle {
+		u.Path = "/" + c.Bucket + "/" + key
+	} else {
+		u.Host = c.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = escapePath(u.Path)
+	return u, nil
+}
+
+// PresignGetObject returns a URL which can be used to download the object without credentials, until expires is over.
+func (c *Client) PresignGetObject(key string, expires time.Duration) (string, error) {
+	if expires > maxPresignExpires {
+		expires = maxPresignExpires
+	}
+	u, err := c.ObjectUrl(key)
+	if err != nil {
+		return "", err
+	}
+
+	now := c.currentTime()
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", c.AccessKey+"/"+c.scope(now))
+	query.Set("X-Amz-Date", now.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	headers := map[string]string{"host": u.Host}
+	signature := c.signature(now, http.MethodGet, u.RawPath, query, headers, unsignedPayload)
+	return u.Scheme + "://" + u.Host + u.RawPath + "?" + canonicalQueryString(query) + "&X-Amz-Signature=" + signature, nil
+}
+
+// ListObjects calls fn for every object below prefix, by calling ListObjectsV2 as often as needed.
+func (c *Client) ListObjects(prefix string, fn func(object Object) error) error {
+	continuationToken := ""
+	for {
+		result, err := c.listObjectsV2(prefix, continuationToken)
+		if err != nil {
+			return err
+		}
+		for _, content := range result.Contents {
+			if strings.HasSuffix(content.Key, "/") {
+				// "folder" placeholder objects
+				continue
+			}
+			err = fn(Object{
+				Key:          content.Key,
+				SizeBytes:    content.Size,
+				LastModified: content.LastModified,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || len(result.NextContinuationToken) == 0 {
+			return nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// GetObject downloads the object with the given key; the caller needs to close the returned body.
+func (c *Client) GetObject(key string) (io.ReadCloser, int64, error) {
+	u, err := c.ObjectUrl(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.signRequest(req, url.Values{})
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("downloading %s from bucket %s: %w", key, c.Bucket, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+		return nil, 0, fmt.Errorf("downloading %s from bucket %s: status code %d: %s", key, c.Bucket, resp.StatusCode, body)
+	}
+	size := resp.ContentLength
+	if size < 0 {
+		// chunked responses (f.e. from some S3 compatible proxies) have no length; the tar header needs one.
+		size, err = c.objectSize(key)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, 0, err
+		}
+	}
+	return resp.Body, size, nil
+}
+
+// objectSize returns the size of the object with the given key, via a HEAD request.
+func (c *Client) objectSize(key string) (int64, error) {
+	u, err := c.ObjectUrl(key)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	c.signRequest(req, url.Values{})
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("determining the size of %s in bucket %s: %w", key, c.Bucket, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("determining the size of %s in bucket %s: status code %d", key, c.Bucket, resp.StatusCode)
+	}
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("determining the size of %s in bucket %s: the server did not send a Content-Length", key, c.Bucket)
+	}
+	return resp.ContentLength, nil
+}
+
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+func (c *Client) listObjectsV2(prefix string, continuationToken string) (*listBucketResult, error) {
+	u, err := c.ObjectUrl("")
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("list-type", "2")
+	if len(prefix) > 0 {
+		query.Set("prefix", prefix)
+	}
+	if len(continuationToken) > 0 {
+		query.Set("continuation-token", continuationToken)
+	}
+	u.RawQuery = canonicalQueryString(query)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.signRequest(req, query)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing objects in bucket %s: %w", c.Bucket, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("listing objects in bucket %s: %w", c.Bucket, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing objects in bucket %s: status code %d: %s", c.Bucket, resp.StatusCode, body)
+	}
+
+	result := &listBucketResult{}
+	if err := xml.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("listing objects in bucket %s: could not parse response: %w", c.Bucket, err)
+	}
+	return result, nil
+}
+
+// signRequest adds the Authorization header to a request without body.
+func (c *Client) signRequest(req *http.Request, query url.Values) {
+	now := c.currentTime()
+	req.Header.Set("x-amz-date", now.Format(amzDateFormat))
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": emptyPayloadHash,
+		"x-amz-date":           now.Format(amzDateFormat),
+	}
+	signature := c.signature(now, req.Method, req.URL.EscapedPath(), query, headers, emptyPayloadHash)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, c.AccessKey, c.scope(now), signedHeaders(headers), signature,
+	))
+}
+
+func (c *Client) scope(now time.Time) string {
+	return now.Format(scopeDateFormat) + "/" + c.region() + "/s3/aws4_request"
+}
+
+// signature implements https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (c *Client) signature(now time.Time, method string, escapedPath string, query url.Values, headers map[string]string, payloadHash string) string {
+	if len(escapedPath) == 0 {
+		escapedPath = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		method,
+		escapedPath,
+		canonicalQueryString(query),
+		canonicalHeaders(headers),
+		signedHeaders(headers),
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		algorithm,
+		now.Format(amzDateFormat),
+		c.scope(now),
+		hexSha256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+c.SecretKey), now.Format(scopeDateFormat))
+	key = hmacSha256(key, c.region())
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	return hex.EncodeToString(hmacSha256(key, stringToSign))
+}
+
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func canonicalHeaders(headers map[string]string) string {
+	var b strings.Builder
+	for _, name := range sortedHeaderNames(headers) {
+		b.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	return b.String()
+}
+
+func signedHeaders(headers map[string]string) string {
+	return strings.Join(sortedHeaderNames(headers), ";")
+}
+
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// escape URI-encodes everything except the unreserved characters, as required by SigV4.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ('0' <= ch && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func hexSha256(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
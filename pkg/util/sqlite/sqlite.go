@@ -0,0 +1,37 @@
+// Package sqlite creates consistent copies of SQLite databases while the application keeps running.
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+
+	// pure-Go SQLite driver, so we do not need CGO (and synco stays a single static binary)
+	_ "modernc.org/sqlite"
+)
+
+// Backup writes a consistent copy of the SQLite database at sourcePath to targetPath, using VACUUM INTO.
+// This works while the database is in use (also in WAL mode), and the copy is compacted on the way.
+// targetPath must not exist yet.
+func Backup(sourcePath string, targetPath string) error {
+	// opening a missing path would silently create an empty database
+	if _, err := os.Stat(sourcePath); err != nil {
+		return fmt.Errorf("SQLite database %s not found: %w", sourcePath, err)
+	}
+	// mode=ro: never modify (or create) the database of the application.
+	// busy_timeout: wait for writers of the application, instead of failing directly with SQLITE_BUSY
+	dsn := "file:" + (&url.URL{Path: sourcePath}).EscapedPath() + "?mode=ro&_pragma=busy_timeout(10000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("opening SQLite database %s: %w", sourcePath, err)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	if _, err := db.Exec("VACUUM INTO ?", targetPath); err != nil {
+		return fmt.Errorf("backing up SQLite database %s: %w", sourcePath, err)
+	}
+	return nil
+}
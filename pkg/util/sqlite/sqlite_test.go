@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupCreatesConsistentCopy(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "data base.sqlite")
+	targetPath := filepath.Join(dir, "backup.sqlite")
+
+	source, err := sql.Open("sqlite", sourcePath)
+	if err != nil {
+		t.Fatalf("opening source: %v", err)
+	}
+	defer source.Close()
+	for _, statement := range []string{
+		"PRAGMA journal_mode=WAL",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO users (name) VALUES ('alice'), ('bob')",
+	} {
+		if _, err := source.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	// the source connection stays open (like a running application) - the committed data lives in the WAL.
+	if err := Backup(sourcePath, targetPath); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	target, err := sql.Open("sqlite", targetPath)
+	if err != nil {
+		t.Fatalf("opening backup: %v", err)
+	}
+	defer target.Close()
+	var count int
+	if err := target.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("querying backup: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 users in backup, got %d", count)
+	}
+}
+
+func TestBackupFailsForMissingDatabase(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "missing.sqlite")
+
+	if err := Backup(sourcePath, filepath.Join(dir, "backup.sqlite")); err == nil {
+		t.Fatalf("expected an error for a missing database")
+	}
+	if _, err := os.Stat(sourcePath); !os.IsNotExist(err) {
+		t.Errorf("the missing database must not be created, stat: %v", err)
+	}
+}
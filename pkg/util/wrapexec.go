@@ -9,8 +9,17 @@ import (
 
 var mocks map[string]string = make(map[string]string)
 
+// mockCalls records all mocked commands which were executed, in order.
+var mockCalls []string
+
 func ResetCommandMocks() {
 	mocks = make(map[string]string)
+	mockCalls = nil
+}
+
+// CommandMockCalls returns all mocked commands which were executed since the last ResetCommandMocks.
+func CommandMockCalls() []string {
+	return mockCalls
 }
 
 func RegisterCommandMock(commandString string, output string) {
@@ -21,6 +30,7 @@ func RunWrappedCommand(cmd *exec.Cmd) (output string, s string, err error) {
 	commandString := strings.Join(cmd.Args, " ")
 	if output, ok := mocks[commandString]; ok {
 		pterm.Debug.Printfln("Mocking command: %s", commandString)
+		mockCalls = append(mockCalls, commandString)
 		return output, s, nil
 	}
 
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+const scratchDatabasePassword = "synco-verify"
+
+// scratchDatabase is a throwaway MySQL/MariaDB Docker container
+type scratchDatabase struct {
+	ContainerId string
+	Dsn         string
+}
+
+// startScratchDatabase starts a container of the image (the official mysql and mariadb images are supported) on a
+// random local port, and waits until it accepts connections.
+func startScratchDatabase(image string) (*scratchDatabase, error) {
+	pterm.Info.Printfln("Starting %s", image)
+	output, err := exec.Command("docker", "run", "--detach", "--rm",
+		"--env", "MYSQL_ROOT_PASSWORD="+scratchDatabasePassword,
+		"--env", "MARIADB_ROOT_PASSWORD="+scratchDatabasePassword,
+		"--env", "MYSQL_DATABASE=verify",
+		"--env", "MARIADB_DATABASE=verify",
+		"--publish", "127.0.0.1::3306",
+		image).Output()
+	if err != nil {
+		return nil, commandError(err)
+	}
+	db := &scratchDatabase{ContainerId: strings.TrimSpace(string(output))}
+
+	output, err = exec.Command("docker", "port", db.ContainerId, "3306/tcp").Output()
+	if err != nil {
+		return db, commandError(err)
+	}
+	// f.e. "127.0.0.1:49153"
+	address := strings.TrimSpace(strings.Split(string(output), "\n")[0])
+	db.Dsn = fmt.Sprintf("root:%s@tcp(%s)/verify", scratchDatabasePassword, address)
+
+	conn, err := sql.Open("mysql", db.Dsn)
+	if err != nil {
+		return db, err
+	}
+	defer conn.Close()
+	// the entrypoint of the images restarts the server after the initialization, so wait until it is really ready
+	for deadline := time.Now().Add(2 * time.Minute); ; {
+		if err = conn.Ping(); err == nil {
+			if _, err = conn.Exec("SELECT 1 FROM DUAL"); err == nil {
+				return db, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return db, fmt.Errorf("database did not start: %w", err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Remove stops and removes the container
+func (db *scratchDatabase) Remove() {
+	if err := exec.Command("docker", "rm", "--force", db.ContainerId).Run(); err != nil {
+		pterm.Warning.Printfln("Could not remove container %s: %s", db.ContainerId, err)
+	}
+}
+
+func commandError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/pterm/pterm"
+	"github.com/sandstorm/synco/v2/pkg/common/dto"
+	"github.com/sandstorm/synco/v2/pkg/util/compression"
+	"github.com/sandstorm/synco/v2/pkg/util/mysql"
+	mysqldump "github.com/sandstorm/synco/v2/pkg/util/mysql/go_mysqldump"
+	"github.com/spf13/cobra"
+)
+
+var dsn string
+var dockerImage string
+var password string
+var codec string
+var tablesFile string
+
+var VerifyDumpCmd = &cobra.Command{
+	Use:   "verify-dump [dump files]",
+	Short: "Import a database dump into a scratch database and compare it with the dumped rows",
+	Long: `Imports the database dump into a throwaway database (--dsn, or a fresh Docker container with --docker),
+and compares the row counts (and checksums, for dumps created with --checksums) of all tables with the numbers
+recorded in the dump. With --tables, the numbers recorded in the meta data of the transfer session are used (as
+written by synco receive to dump/<fileSet>.tables.json) - otherwise, they are read from the footers of the files.
+
+For parallel dumps, pass the table files first and the main file last. Encrypted files (*.enc, as stored on the
+server) are decrypted with --password.
+
+All tables of the dump are DROPPED and re-created in the scratch database!`,
+	Args: cobra.MinimumNArgs(1),
+	Example: `synco verify-dump --dsn 'root:password@tcp(127.0.0.1:3306)/scratch' --tables dump/dbDump.tables.json dump/dbDump.sql
+synco verify-dump --docker mariadb:11 dump/dbDump/*.sql dump/dbDump.sql`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if (len(dsn) == 0) == (len(dockerImage) == 0) {
+			pterm.Fatal.Printfln("Either --dsn or --docker must be given.")
+		}
+
+		tmpDir, err := os.MkdirTemp("", "synco-verify-")
+		if err != nil {
+			pterm.Fatal.Printfln("Could not create temporary directory: %s", err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		fileNames := make([]string, 0, len(args))
+		for i, fileName := range args {
+			if strings.HasSuffix(fileName, ".enc") {
+				decryptedFileName := filepath.Join(tmpDir, fmt.Sprintf("%d.sql", i))
+				if err := decryptFile(fileName, decryptedFileName); err != nil {
+					pterm.Fatal.Printfln("Could not decrypt %s: %s", fileName, err)
+				}
+				fileName = decryptedFileName
+			}
+			fileNames = append(fileNames, fileName)
+		}
+
+		var expected map[string]mysqldump.TableStats
+		if len(tablesFile) > 0 {
+			expected, err = readTablesFile(tablesFile)
+		} else {
+			expected, err = mysql.ReadTableStats(fileNames)
+		}
+		if err != nil {
+			pterm.Fatal.Printfln("Could not read the row counts of the dump: %s", err)
+		}
+		if len(expected) == 0 {
+			pterm.Fatal.Printfln("The dump contains no row counts (created with an older synco version, or the file is incomplete?) - pass them via --tables dump/<fileSet>.tables.json.")
+		}
+
+		ok := importAndVerify(fileNames, expected)
+		_ = os.RemoveAll(tmpDir)
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+// importAndVerify returns false if the import failed or differs from the dump
+func importAndVerify(fileNames []string, expected map[string]mysqldump.TableStats) bool {
+	if len(dockerImage) > 0 {
+		container, err := startScratchDatabase(dockerImage)
+		if container != nil {
+			defer container.Remove()
+		}
+		if err != nil {
+			pterm.Error.Printfln("Could not start %s: %s", dockerImage, err)
+			return false
+		}
+		dsn = container.Dsn
+	}
+
+	if err := mysql.ImportFiles(dsn, fileNames); err != nil {
+		pterm.Error.Printfln("Import FAILED: %s", err)
+		return false
+	}
+	pterm.Success.Printfln("Imported the dump")
+
+	differences, err := mysql.VerifyImport(dsn, expected)
+	if err != nil {
+		pterm.Error.Printfln("Could not verify the import: %s", err)
+		return false
+	}
+	for _, difference := range differences {
+		pterm.Error.Printfln("%s", difference)
+	}
+	if len(differences) > 0 {
+		pterm.Error.Printfln("%d of %d tables differ from the dump", len(differences), len(expected))
+		return false
+	}
+	pterm.Success.Printfln("All %d tables match the dump", len(expected))
+	return true
+}
+
+// readTablesFile reads the row counts of a dump/<fileSet>.tables.json file, as written by synco receive
+func readTablesFile(fileName string) (map[string]mysqldump.TableStats, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var tables []dto.FileSetMysqlDumpTable
+	if err := json.Unmarshal(content, &tables); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	result := make(map[string]mysqldump.TableStats, len(tables))
+	for _, table := range tables {
+		result[table.Name] = mysqldump.TableStats{
+			Name:    table.Name,
+			Mode:    mysqldump.TableMode(table.Mode),
+			Rows:    table.Rows,
+			RowHash: table.RowHash,
+		}
+	}
+	return result, nil
+}
+
+// decryptFile decrypts (and decompresses, see --compression) a file as stored by synco serve
+func decryptFile(fileName string, targetFileName string) error {
+	if len(password) == 0 {
+		return fmt.Errorf("--password is required for encrypted files")
+	}
+	identity, err := age.NewScryptIdentity(password)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	decryptedReader, err := age.Decrypt(in, identity)
+	if err != nil {
+		return fmt.Errorf("most likely, the password was wrong: %w", err)
+	}
+	decompressedReader, err := compression.NewReader(codec, decryptedReader)
+	if err != nil {
+		return err
+	}
+	defer decompressedReader.Close()
+
+	out, err := os.Create(targetFileName)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, decompressedReader)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func init() {
+	VerifyDumpCmd.Flags().StringVar(&dsn, "dsn", "", "scratch database to import into, f.e. root:password@tcp(127.0.0.1:3306)/scratch")
+	VerifyDumpCmd.Flags().StringVar(&dockerImage, "docker", "", "import into a fresh Docker container of this image instead (f.e. in CI), like mariadb:11 or mysql:8.4")
+	VerifyDumpCmd.Flags().StringVar(&password, "password", "", "password of the transfer session, for encrypted (*.enc) files")
+	VerifyDumpCmd.Flags().StringVar(&tablesFile, "tables", "", "row counts of the dump, as written by synco receive to dump/<fileSet>.tables.json (default: read from the footers of the files)")
+	VerifyDumpCmd.Flags().StringVar(&codec, "compression", "", "compression of the encrypted files (gzip or zstd), as chosen with synco serve --compression")
+}
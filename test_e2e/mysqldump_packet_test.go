@@ -0,0 +1,133 @@
+package test_e2e
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/mariadb"
+	syncoMysql "github.com/sandstorm/synco/v2/pkg/util/mysql"
+	mysqldump "github.com/sandstorm/synco/v2/pkg/util/mysql/go_mysqldump"
+)
+
+const smallMaxAllowedPacket = 64 * 1024
+
+const blobTables = `
+	drop table if exists chunked_blobs;
+	drop table if exists huge_blobs;
+	create table chunked_blobs (id int not null primary key, content longblob);
+	create table huge_blobs (id int not null primary key, content longblob);
+	insert into chunked_blobs values (1, repeat('ab', 20000)), (2, 'small');
+	insert into huge_blobs values (1, repeat('ab', 50000));
+`
+
+// startSmallPacketDb starts a database with a max_allowed_packet of 64 KiB, as the import target.
+func startSmallPacketDb(t *testing.T) (string, string) {
+	t.Helper()
+	p := mariadb.Preset(
+		mariadb.WithVersion("11.8"),
+		mariadb.WithUser("admin", "password"),
+		mariadb.WithDatabase("dummy1"),
+	)
+	options := []gnomock.Option{gnomock.WithCommand("mariadbd", "--max-allowed-packet="+strconv.Itoa(smallMaxAllowedPacket))}
+	if reuseDatabaseContainer {
+		options = append(options, gnomock.WithContainerReuse(), gnomock.WithContainerName("synco-test-small-packet"))
+	}
+	container, err := gnomock.Start(p, options...)
+	if err != nil {
+		panic(err)
+	}
+	if !reuseDatabaseContainer {
+		t.Cleanup(func() {
+			_ = gnomock.Stop(container)
+		})
+	}
+	return container.Host, strconv.Itoa(container.DefaultPort())
+}
+
+func dumpBlobTable(t *testing.T, db *sql.DB, table string) (string, mysqldump.TableStats) {
+	t.Helper()
+	var dump bytes.Buffer
+	dumper := mysqldump.NewDumper(db, &dump)
+	dumper.MaxAllowedPacket = smallMaxAllowedPacket
+	dumper.ChunkBlobs = true
+	var err error
+	dumper.IgnoreTables, err = otherTables(db, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dumper.Dump(); err != nil {
+		t.Fatalf("dumping: %s", err)
+	}
+	return dump.String(), dumper.TableStats()[0]
+}
+
+// TestChunkedBlobsCanBeImportedWithSmallMaxAllowedPacket dumps BLOBs from a database with the default
+// max_allowed_packet, and imports them into a database with a max_allowed_packet of 64 KiB.
+func TestChunkedBlobsCanBeImportedWithSmallMaxAllowedPacket(t *testing.T) {
+	sourceHost, sourcePort := startDb(t)
+	source := openTestDb(t, sourceHost+":"+sourcePort)
+	if _, err := source.Exec(blobTables); err != nil {
+		t.Fatalf("creating tables: %s", err)
+	}
+
+	// the row (as hex) is bigger than the packet, but the value itself fits
+	dump, stats := dumpBlobTable(t, source, "chunked_blobs")
+	if stats.OversizedRows != 0 || !strings.Contains(dump, "CONCAT(`content`") {
+		t.Fatalf("expected the BLOB to be split, stats: %+v", stats)
+	}
+	dumpFile := filepath.Join(t.TempDir(), "chunked_blobs.sql")
+	if err := os.WriteFile(dumpFile, []byte(dump), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	targetHost, targetPort := startSmallPacketDb(t)
+	target := openTestDb(t, targetHost+":"+targetPort)
+	dsn := "admin:password@tcp(" + targetHost + ":" + targetPort + ")/dummy1"
+	if err := syncoMysql.ImportFiles(dsn, []string{dumpFile}); err != nil {
+		t.Fatalf("importing dump: %s", err)
+	}
+	for _, query := range []string{"SELECT COUNT(*) FROM chunked_blobs", "SELECT GROUP_CONCAT(MD5(content) ORDER BY id) FROM chunked_blobs", "SELECT COUNT(*) FROM chunked_blobs WHERE content IS NULL"} {
+		var expected, actual string
+		if err := source.QueryRow(query).Scan(&expected); err != nil {
+			t.Fatal(err)
+		}
+		if err := target.QueryRow(query).Scan(&actual); err != nil {
+			t.Fatal(err)
+		}
+		if expected != actual {
+			t.Errorf("%s: %s != %s", query, expected, actual)
+		}
+	}
+
+	// a value bigger than the packet can not be rebuilt on the target, so the row is reported instead of split
+	dump, stats = dumpBlobTable(t, source, "huge_blobs")
+	if stats.OversizedRows != 1 || strings.Contains(dump, "CONCAT(`content`") {
+		t.Errorf("expected the huge BLOB to be reported as oversized, stats: %+v", stats)
+	}
+}
+
+func openTestDb(t *testing.T, addr string) *sql.DB {
+	t.Helper()
+	config := mysql.NewConfig()
+	config.User = "admin"
+	config.Passwd = "password"
+	config.DBName = "dummy1"
+	config.Net = "tcp"
+	config.Addr = addr
+	config.MultiStatements = true
+	db, err := sql.Open("mysql", config.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
@@ -0,0 +1,153 @@
+package test_e2e
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	mysqldump "github.com/sandstorm/synco/v2/pkg/util/mysql/go_mysqldump"
+)
+
+const allTypesTable = `
+	drop table if exists all_types;
+	drop table if exists all_types_orig;
+	create table all_types (
+		id int not null primary key,
+		c_bool bool,
+		c_tinyint tinyint,
+		c_tinyint_u tinyint unsigned,
+		c_smallint smallint,
+		c_mediumint_u mediumint unsigned,
+		c_int int,
+		c_bigint bigint,
+		c_bigint_u bigint unsigned,
+		c_bigint_u_nn bigint unsigned not null default 0,
+		c_decimal decimal(30,10),
+		c_float float,
+		c_double double,
+		c_double_nn double not null default 0,
+		c_bit1 bit(1),
+		c_bit64 bit(64),
+		c_year year,
+		c_date date,
+		c_time time(6),
+		c_datetime datetime(6),
+		c_timestamp timestamp(6) null,
+		c_char char(10),
+		c_varchar varchar(255),
+		c_text text,
+		c_binary binary(4),
+		c_varbinary varbinary(255),
+		c_blob blob,
+		c_enum enum('a','b','c'),
+		c_set set('x','y','z'),
+		c_json json,
+		c_geometry geometry
+	);
+	insert into all_types values (1, true, -128, 255, -32768, 16777215, -2147483648, -9223372036854775808,
+		18446744073709551615, 18446744073709551615, '-12345678901234567890.0123456789', 1.17549e-38, 2.2250738585072014e-308, 1.7976931348623157e308,
+		b'1', b'1111111111111111111111111111111111111111111111111111111111111111', 1901, '1000-01-01', '-838:59:59.000000',
+		'9999-12-31 23:59:59.999999', '2038-01-19 03:14:07.999999', 'umlaut äö', 'quote '' " \\ \n \r \t \Z end', 'emoji 😀',
+		x'00000000', x'00', x'000102fffe', 'c', 'x,z', '{"a": [1, 2.5, "b"]}', ST_GeomFromText('POINT(1.5 -2.25)'));
+	insert into all_types values (2, false, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 1e20,
+		b'0', b'0', 0, '2024-02-29', '00:00:00', '2024-02-29 12:00:00', '1970-01-01 00:00:01', '', '', '',
+		x'', x'', x'', 'a', '', '{}', ST_GeomFromText('POLYGON((0 0,1 0,1 1,0 0))'));
+	insert into all_types (id) values (3);
+`
+
+// TestDumpRoundTripsAllColumnTypes dumps a table containing every MySQL / MariaDB column type, imports the dump
+// again and compares the checksums of both tables.
+func TestDumpRoundTripsAllColumnTypes(t *testing.T) {
+	dbHost, dbPort := startDb(t)
+
+	config := mysql.NewConfig()
+	config.User = "admin"
+	config.Passwd = "password"
+	config.DBName = "dummy1"
+	config.Net = "tcp"
+	config.Addr = dbHost + ":" + dbPort
+	config.MultiStatements = true
+	config.Params = map[string]string{
+		"time_zone": "'+00:00'",
+	}
+	db, err := sql.Open("mysql", config.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(allTypesTable); err != nil {
+		t.Fatalf("creating table: %s", err)
+	}
+
+	var dump bytes.Buffer
+	dumper := mysqldump.NewDumper(db, &dump)
+	dumper.Checksums = true
+	dumper.IgnoreTables, err = otherTables(db, "all_types")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dumper.Dump(); err != nil {
+		t.Fatalf("dumping: %s", err)
+	}
+
+	if _, err := db.Exec("rename table all_types to all_types_orig"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(dump.String()); err != nil {
+		t.Fatalf("importing dump: %s\n%s", err, dump.String())
+	}
+
+	expected, actual := checksum(t, db, "all_types_orig"), checksum(t, db, "all_types")
+	if expected != actual {
+		t.Errorf("checksum of re-imported table differs (%d != %d) - dump was:\n%s", expected, actual, dump.String())
+	}
+
+	// the same comparison as "synco verify-dump" does
+	recorded, err := mysqldump.ReadTableStats(bytes.NewReader(dump.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := mysqldump.ComputeTableStats(db, "all_types")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded["all_types"] != imported {
+		t.Errorf("stats of re-imported table differ: %+v != %+v", recorded["all_types"], imported)
+	}
+}
+
+func otherTables(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query("SHOW TABLES")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if name != table {
+			result = append(result, name)
+		}
+	}
+	return result, rows.Err()
+}
+
+func checksum(t *testing.T, db *sql.DB, table string) int64 {
+	t.Helper()
+	var name string
+	var result sql.NullInt64
+	if err := db.QueryRow(fmt.Sprintf("CHECKSUM TABLE `%s`", table)).Scan(&name, &result); err != nil {
+		t.Fatalf("checksum of %s: %s", table, err)
+	}
+	if !result.Valid {
+		t.Fatalf("table %s does not exist", table)
+	}
+	return result.Int64
+}